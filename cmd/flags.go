@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// addTestFlags registers the flags selecting and testing the servers, shared
+// by the speedtest and monitor commands, and binds them to the viper keys of
+// their names with the prefix.
+func addTestFlags(cmd *cobra.Command, prefix string) {
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+
+	flags.IntSliceP("server", "s", []int{}, "Select server id to run speedtest.")
+	flags.StringArray("custom-url", []string{},
+		"Specify the url of a server instead of fetching from speedtest.net, can be repeated "+
+			"(labels: url;name=..;id=..;upload=..;download=..;latency=..).")
+	flags.String("select", string(speedtest.StrategyLatency),
+		"Strategy to select the server (options: latency/best-of/median/nearest/probe).")
+	flags.Int("select-pings", speedtest.DefaultSelectionPings,
+		"Number of pings per server of the best-of, median and probe strategies.")
+	flags.Int("select-top", speedtest.DefaultSelectionTopK,
+		"Number of lowest median latency servers the probe strategy downloads from.")
+	flags.StringSlice("country", []string{}, "Only select servers in these countries.")
+	flags.StringSlice("sponsor", []string{}, "Only select servers of sponsors containing one of these names.")
+	flags.StringSlice("exclude-id", []string{}, "Never select these server ids.")
+	flags.IntP("thread", "t", 0, "Set the number of concurrent connections.")
+	flags.Bool("no-download", false, "Disable download test.")
+	flags.Bool("no-upload", false, "Disable upload test.")
+	flags.String("ping-mode", "http", "Select a method for Ping (support icmp/tcp/http).")
+	flags.String("transfer-mode", "http", "Select a method for Download/Upload (support tcp/http).")
+	flags.Bool("history", false, "Record the results in the history file, shown by the history command.")
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		_ = viper.BindPFlag(prefix+flag.Name, flag)
+	})
+
+	cmd.Flags().AddFlagSet(flags)
+}
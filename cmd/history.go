@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/app"
+)
+
+// historyCmd represents the history command.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recorded speedtest results",
+	Long: "List, filter and summarize the results recorded by the speedtest runs with --history. " +
+		"Results are stored as JSON lines in the user config directory unless --history-file is set.",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		config := app.Config{
+			HistoryFile:      viper.GetString("history-file"),
+			HistoryServerIDs: viper.GetStringSlice("history-server"),
+			HistorySince:     viper.GetString("since"),
+			HistoryUntil:     viper.GetString("until"),
+			HistorySummary:   viper.GetBool("summary"),
+			JSONOutput:       viper.GetBool("history-json"),
+		}
+
+		return app.RunHistory(config)
+	},
+}
@@ -14,14 +14,39 @@ var listCmd = &cobra.Command{
 	Long:  "Display a list of available speedtest.net servers with their details.",
 	RunE: func(_ *cobra.Command, _ []string) error {
 		config := app.Config{
-			Location:      viper.GetString("location"),
-			City:          viper.GetString("city"),
-			Search:        viper.GetString("search"),
-			Proxy:         viper.GetString("proxy"),
-			Source:        viper.GetString("source"),
-			DNSBindSource: viper.GetBool("dns-bind-source"),
-			UserAgent:     viper.GetString("ua"),
-			Debug:         viper.GetBool("debug"),
+			Location:       viper.GetString("location"),
+			City:           viper.GetString("city"),
+			Search:         viper.GetString("search"),
+			Proxy:          viper.GetString("proxy"),
+			Sources:        viper.GetStringSlice("source"),
+			IPv4:           viper.GetBool("ipv4"),
+			IPv6:           viper.GetBool("ipv6"),
+			HTTPS:          viper.GetString("https"),
+			CACert:         viper.GetString("ca-cert"),
+			ClientCert:     viper.GetString("client-cert"),
+			ClientKey:      viper.GetString("client-key"),
+			TLSServerName:  viper.GetString("tls-server-name"),
+			Insecure:       viper.GetBool("insecure"),
+			DNSBindSource:  viper.GetBool("dns-bind-source"),
+			DNSServer:      viper.GetString("dns-server"),
+			UserAgent:      viper.GetString("ua"),
+			Debug:          viper.GetBool("debug"),
+			CachedServers:  viper.GetBool("cached-servers"),
+			Offline:        viper.GetBool("offline"),
+			Refresh:        viper.GetBool("refresh"),
+			CacheFile:      viper.GetString("cache-file"),
+			CacheTTL:       viper.GetDuration("cache-ttl"),
+			ServersFile:    viper.GetString("servers-file"),
+			Export:         viper.GetString("export"),
+			Countries:      viper.GetStringSlice("list-country"),
+			Sponsors:       viper.GetStringSlice("list-sponsor"),
+			ExcludeIDs:     viper.GetStringSlice("list-exclude-id"),
+			MaxDistance:    viper.GetFloat64("max-distance"),
+			ListMaxLatency: viper.GetDuration("list-max-latency"),
+			Sort:           viper.GetString("sort"),
+			Limit:          viper.GetInt("limit"),
+			JSONOutput:     viper.GetBool("list-json"),
+			CSVOutput:      viper.GetBool("csv"),
 		}
 
 		return app.RunList(config)
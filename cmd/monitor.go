@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/app"
+)
+
+// monitorCmd represents the monitor command.
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Run speedtests periodically and export Prometheus metrics",
+	Long: "Run the speedtest on a fixed interval, optionally aligned to the wall clock, and serve the latest results and the " +
+		"statistics of the recorded history on /metrics in the Prometheus text format.",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		config := app.Config{
+			ServerIDs:       viper.GetIntSlice("monitor-server"),
+			CustomURLs:      viper.GetStringSlice("monitor-custom-url"),
+			Proxy:           viper.GetString("proxy"),
+			Sources:         viper.GetStringSlice("source"),
+			IPv4:            viper.GetBool("ipv4"),
+			IPv6:            viper.GetBool("ipv6"),
+			HTTPS:           viper.GetString("https"),
+			CACert:          viper.GetString("ca-cert"),
+			ClientCert:      viper.GetString("client-cert"),
+			ClientKey:       viper.GetString("client-key"),
+			TLSServerName:   viper.GetString("tls-server-name"),
+			Insecure:        viper.GetBool("insecure"),
+			DNSBindSource:   viper.GetBool("dns-bind-source"),
+			DNSServer:       viper.GetString("dns-server"),
+			Thread:          viper.GetInt("monitor-thread"),
+			UserAgent:       viper.GetString("ua"),
+			NoDownload:      viper.GetBool("monitor-no-download"),
+			NoUpload:        viper.GetBool("monitor-no-upload"),
+			PingMode:        viper.GetString("monitor-ping-mode"),
+			TransferMode:    viper.GetString("monitor-transfer-mode"),
+			Debug:           viper.GetBool("debug"),
+			HistoryFile:     viper.GetString("history-file"),
+			History:         viper.GetBool("monitor-history"),
+			ListenAddr:      viper.GetString("monitor-listen"),
+			MonitorInterval: viper.GetDuration("interval"),
+			MonitorAlign:    viper.GetBool("align"),
+			MonitorWindow:   viper.GetDuration("history-window"),
+			CachedServers:   viper.GetBool("cached-servers"),
+			Offline:         viper.GetBool("offline"),
+			CacheFile:       viper.GetString("cache-file"),
+			CacheTTL:        viper.GetDuration("cache-ttl"),
+			ServersFile:     viper.GetString("servers-file"),
+			Select:          viper.GetString("monitor-select"),
+			SelectPings:     viper.GetInt("monitor-select-pings"),
+			SelectTopK:      viper.GetInt("monitor-select-top"),
+			Countries:       viper.GetStringSlice("monitor-country"),
+			Sponsors:        viper.GetStringSlice("monitor-sponsor"),
+			ExcludeIDs:      viper.GetStringSlice("monitor-exclude-id"),
+		}
+
+		return app.RunMonitor(config)
+	},
+}
@@ -9,7 +9,11 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/nicholas-fedor/speedtest-go/internal/app"
+	"github.com/nicholas-fedor/speedtest-go/internal/cache"
+	"github.com/nicholas-fedor/speedtest-go/internal/exporter"
 	"github.com/nicholas-fedor/speedtest-go/internal/output"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
 )
 
 var cfgFile string
@@ -19,25 +23,67 @@ var rootCmd = &cobra.Command{
 	Use:   "speedtest-go",
 	Short: "Test internet bandwidth using speedtest.net",
 	Long:  "A command-line tool to test internet download and upload speeds using speedtest.net servers.",
-	RunE: func(_ *cobra.Command, _ []string) error {
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		// the flags are valid at this point, failed tests must not print the usage.
+		cmd.SilenceUsage = true
+
 		config := app.Config{
-			ServerIDs:     viper.GetIntSlice("server"),
-			CustomURL:     viper.GetString("custom-url"),
-			SavingMode:    viper.GetBool("saving-mode"),
-			JSONOutput:    viper.GetBool("json"),
-			JSONLOutput:   viper.GetBool("jsonl"),
-			UnixOutput:    viper.GetBool("unix"),
-			Proxy:         viper.GetString("proxy"),
-			Source:        viper.GetString("source"),
-			DNSBindSource: viper.GetBool("dns-bind-source"),
-			Multi:         viper.GetBool("multi"),
-			Thread:        viper.GetInt("thread"),
-			UserAgent:     viper.GetString("ua"),
-			NoDownload:    viper.GetBool("no-download"),
-			NoUpload:      viper.GetBool("no-upload"),
-			PingMode:      viper.GetString("ping-mode"),
-			Unit:          viper.GetString("unit"),
-			Debug:         viper.GetBool("debug"),
+			ServerIDs:       viper.GetIntSlice("server"),
+			CustomURLs:      viper.GetStringSlice("custom-url"),
+			SavingMode:      viper.GetBool("saving-mode"),
+			JSONOutput:      viper.GetBool("json"),
+			JSONLOutput:     viper.GetBool("jsonl"),
+			ProgressJSON:    viper.GetBool("progress-json"),
+			UnixOutput:      viper.GetBool("unix"),
+			Proxy:           viper.GetString("proxy"),
+			Sources:         viper.GetStringSlice("source"),
+			ParallelSources: viper.GetBool("parallel-sources"),
+			IPv4:            viper.GetBool("ipv4"),
+			IPv6:            viper.GetBool("ipv6"),
+			HTTPS:           viper.GetString("https"),
+			CACert:          viper.GetString("ca-cert"),
+			ClientCert:      viper.GetString("client-cert"),
+			ClientKey:       viper.GetString("client-key"),
+			TLSServerName:   viper.GetString("tls-server-name"),
+			Insecure:        viper.GetBool("insecure"),
+			DualStack:       viper.GetBool("dual-stack"),
+			DNSBindSource:   viper.GetBool("dns-bind-source"),
+			DNSServer:       viper.GetString("dns-server"),
+			Multi:           viper.GetBool("multi"),
+			Thread:          viper.GetInt("thread"),
+			UserAgent:       viper.GetString("ua"),
+			NoDownload:      viper.GetBool("no-download"),
+			NoUpload:        viper.GetBool("no-upload"),
+			PingMode:        viper.GetString("ping-mode"),
+			TransferMode:    viper.GetString("transfer-mode"),
+			Unit:            viper.GetString("unit"),
+			Debug:           viper.GetBool("debug"),
+			HistoryFile:     viper.GetString("history-file"),
+			History:         viper.GetBool("history"),
+			Outputs:         viper.GetStringSlice("output"),
+			InfluxToken:     viper.GetString("influx-token"),
+			MinDownload:     viper.GetString("min-download"),
+			MinUpload:       viper.GetString("min-upload"),
+			MaxLatency:      viper.GetDuration("max-latency"),
+			MaxJitter:       viper.GetDuration("max-jitter"),
+			MaxPacketLoss:   viper.GetString("max-packet-loss"),
+			TimeSeries:      viper.GetBool("time-series"),
+			MaxDuration:     viper.GetDuration("max-duration"),
+			MinDuration:     viper.GetDuration("min-duration"),
+			WarmUp:          viper.GetDuration("warm-up"),
+			StabilityCV:     viper.GetFloat64("stability-cv"),
+			MaxBytes:        viper.GetString("max-bytes"),
+			CachedServers:   viper.GetBool("cached-servers"),
+			Offline:         viper.GetBool("offline"),
+			CacheFile:       viper.GetString("cache-file"),
+			CacheTTL:        viper.GetDuration("cache-ttl"),
+			ServersFile:     viper.GetString("servers-file"),
+			Select:          viper.GetString("select"),
+			SelectPings:     viper.GetInt("select-pings"),
+			SelectTopK:      viper.GetInt("select-top"),
+			Countries:       viper.GetStringSlice("country"),
+			Sponsors:        viper.GetStringSlice("sponsor"),
+			ExcludeIDs:      viper.GetStringSlice("exclude-id"),
 		}
 
 		return app.RunSpeedtest(config)
@@ -63,67 +109,217 @@ func Init() {
 	rootCmd.PersistentFlags().
 		StringVar(&cfgFile, "config", "", "config file (default is $HOME/.speedtest-go.yaml)")
 	rootCmd.PersistentFlags().
-		String("proxy", "", "Set a proxy(http[s] or socks) for the speedtest.")
-	rootCmd.PersistentFlags().String("source", "", "Bind a source interface for the speedtest.")
+		String("proxy", "", "Set a proxy (http[s]:// or socks5[h]://, user:password@ for auth) for the speedtest.")
+	rootCmd.PersistentFlags().StringArray("source", []string{},
+		"Bind a source interface or address for the speedtest, can be repeated to test every uplink.")
 	rootCmd.PersistentFlags().
 		Bool("dns-bind-source", false, "DNS request binding source (experimental).")
+	rootCmd.PersistentFlags().
+		String("dns-server", "", "Resolve the server names with a DNS server (host[:port]) instead of the system resolver.")
+	rootCmd.PersistentFlags().Bool("ipv4", false, "Only connect to the servers over IPv4.")
+	rootCmd.PersistentFlags().Bool("ipv6", false, "Only connect to the servers over IPv6.")
+	rootCmd.MarkFlagsMutuallyExclusive("ipv4", "ipv6")
+	rootCmd.PersistentFlags().String("https", "off",
+		"Upgrade the test URLs of the servers to HTTPS (support off/prefer/require).")
+	rootCmd.PersistentFlags().String("ca-cert", "", "Trust the CAs of a PEM bundle in addition to the system roots.")
+	rootCmd.PersistentFlags().String("client-cert", "", "Present the client certificate of a PEM file, with --client-key.")
+	rootCmd.PersistentFlags().String("client-key", "", "PEM private key of the client certificate.")
+	rootCmd.PersistentFlags().String("tls-server-name", "",
+		"Override the server name sent to and verified for the test servers.")
+	rootCmd.PersistentFlags().Bool("insecure", false,
+		"Skip the verification of the TLS certificates, for lab servers only.")
+	rootCmd.MarkFlagsRequiredTogether("client-cert", "client-key")
 	rootCmd.PersistentFlags().String("ua", "", "Set the user-agent header for the speedtest.")
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug mode.")
+	rootCmd.PersistentFlags().String("history-file", "",
+		"Result history file (default is speedtest-go/history.jsonl in the user config directory).")
+	rootCmd.PersistentFlags().Bool("cached-servers", false,
+		"Reuse the cached server list and user information while fresh, and cache them otherwise.")
+	rootCmd.PersistentFlags().Bool("offline", false,
+		"Use the cached server list and user information regardless of age, without contacting speedtest.net.")
+	rootCmd.PersistentFlags().Duration("cache-ttl", cache.DefaultTTL,
+		"Period the cached server list is fresh for with --cached-servers.")
+	rootCmd.PersistentFlags().String("cache-file", "",
+		"Server list cache file (default is speedtest-go/servers.json in the user cache directory).")
+	rootCmd.PersistentFlags().String("servers-file", "",
+		"Use the servers of a YAML or JSON catalogue file instead of the speedtest.net server list.")
 
 	// Root command flags (for speedtest)
-	rootCmd.Flags().IntSliceP("server", "s", []int{}, "Select server id to run speedtest.")
-	rootCmd.Flags().
-		String("custom-url", "", "Specify the url of the server instead of fetching from speedtest.net.")
+	addTestFlags(rootCmd, "")
 	rootCmd.Flags().
 		Bool("saving-mode", false, "Test with few resources, though low accuracy (especially > 30Mbps).")
 	rootCmd.Flags().Bool("json", false, "Output results in json format.")
 	rootCmd.Flags().
 		Bool("jsonl", false, "Output results in jsonl format (one json object per line).")
 	rootCmd.Flags().Bool("unix", false, "Output results in unix like format.")
+	rootCmd.Flags().Bool("progress-json", false,
+		"Output the progress of the tests as json lines, followed by the results in json format "+
+			"(ignored with --json and --jsonl).")
+	rootCmd.Flags().Bool("time-series", false,
+		"Include the per-interval throughput and latency samples in the json/jsonl output.")
+	rootCmd.Flags().StringArrayP("output", "o", []string{},
+		"Write results to type:target (types: json/jsonl/csv/influx/webhook, target: file, url "+
+			"or - for stdout), can be repeated.")
+	rootCmd.Flags().String("influx-token", "",
+		"API token sent with the results posted to influx:<url> outputs.")
 	rootCmd.Flags().BoolP("multi", "m", false, "Enable multi-server mode.")
-	rootCmd.Flags().IntP("thread", "t", 0, "Set the number of concurrent connections.")
-	rootCmd.Flags().Bool("no-download", false, "Disable download test.")
-	rootCmd.Flags().Bool("no-upload", false, "Disable upload test.")
-	rootCmd.Flags().String("ping-mode", "http", "Select a method for Ping (support icmp/tcp/http).")
+	rootCmd.Flags().Bool("dual-stack", false,
+		"Test every server over IPv4 and over IPv6 and compare the results.")
+	rootCmd.MarkFlagsMutuallyExclusive("dual-stack", "ipv4", "ipv6")
+	rootCmd.MarkFlagsMutuallyExclusive("dual-stack", "multi")
+	rootCmd.Flags().Bool("parallel-sources", false,
+		"Test the uplinks of a repeated --source at the same time instead of one after another.")
+	rootCmd.Flags().Duration("max-duration", speedtest.DefaultCaptureTime,
+		"Maximum duration of the download and upload tests.")
+	rootCmd.Flags().Duration("min-duration", 0,
+		"Minimum duration of the download and upload tests, even if the rate is stable "+
+			"(equal to --max-duration for fixed-duration tests).")
+	rootCmd.Flags().Duration("warm-up", 0,
+		"Exclude the samples of this period at the start of a test from the rate (e.g. 2s for TCP slow start).")
+	rootCmd.Flags().Float64("stability-cv", 0,
+		"Stop a test early once the coefficient of variation of the rate is below this value (default 0.03).")
+	rootCmd.Flags().String("max-bytes", "",
+		"Stop the download and upload tests once they used this much data each (e.g. 50MB or 1GiB).")
 	rootCmd.Flags().
 		StringP("unit", "u", "", "Set human-readable and auto-scaled rate units for output "+
 			"(options: decimal-bits/decimal-bytes/binary-bits/binary-bytes).")
+	rootCmd.Flags().String("min-download", "",
+		"Fail with exit code 3 if the download rate is lower (e.g. 500Mbps, 50MBps, bare numbers are Mbps).")
+	rootCmd.Flags().String("min-upload", "",
+		"Fail with exit code 3 if the upload rate is lower (e.g. 100Mbps).")
+	rootCmd.Flags().Duration("max-latency", 0, "Fail with exit code 3 if the latency is higher (e.g. 20ms).")
+	rootCmd.Flags().Duration("max-jitter", 0, "Fail with exit code 3 if the jitter is higher (e.g. 5ms).")
+	rootCmd.Flags().String("max-packet-loss", "",
+		"Fail with exit code 3 if the packet loss is higher (e.g. 1%).")
 
 	// Bind persistent flags to viper
 	_ = viper.BindPFlag("proxy", rootCmd.PersistentFlags().Lookup("proxy"))
 	_ = viper.BindPFlag("source", rootCmd.PersistentFlags().Lookup("source"))
 	_ = viper.BindPFlag("dns-bind-source", rootCmd.PersistentFlags().Lookup("dns-bind-source"))
+	_ = viper.BindPFlag("dns-server", rootCmd.PersistentFlags().Lookup("dns-server"))
+	_ = viper.BindPFlag("ipv4", rootCmd.PersistentFlags().Lookup("ipv4"))
+	_ = viper.BindPFlag("ipv6", rootCmd.PersistentFlags().Lookup("ipv6"))
+	_ = viper.BindPFlag("https", rootCmd.PersistentFlags().Lookup("https"))
+	_ = viper.BindPFlag("ca-cert", rootCmd.PersistentFlags().Lookup("ca-cert"))
+	_ = viper.BindPFlag("client-cert", rootCmd.PersistentFlags().Lookup("client-cert"))
+	_ = viper.BindPFlag("client-key", rootCmd.PersistentFlags().Lookup("client-key"))
+	_ = viper.BindPFlag("tls-server-name", rootCmd.PersistentFlags().Lookup("tls-server-name"))
+	_ = viper.BindPFlag("insecure", rootCmd.PersistentFlags().Lookup("insecure"))
 	_ = viper.BindPFlag("ua", rootCmd.PersistentFlags().Lookup("ua"))
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	_ = viper.BindPFlag("history-file", rootCmd.PersistentFlags().Lookup("history-file"))
+	_ = viper.BindPFlag("cached-servers", rootCmd.PersistentFlags().Lookup("cached-servers"))
+	_ = viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	_ = viper.BindPFlag("cache-ttl", rootCmd.PersistentFlags().Lookup("cache-ttl"))
+	_ = viper.BindPFlag("cache-file", rootCmd.PersistentFlags().Lookup("cache-file"))
+	_ = viper.BindPFlag("servers-file", rootCmd.PersistentFlags().Lookup("servers-file"))
 
 	// Bind root flags to viper
-	_ = viper.BindPFlag("server", rootCmd.Flags().Lookup("server"))
-	_ = viper.BindPFlag("custom-url", rootCmd.Flags().Lookup("custom-url"))
 	_ = viper.BindPFlag("saving-mode", rootCmd.Flags().Lookup("saving-mode"))
 	_ = viper.BindPFlag("json", rootCmd.Flags().Lookup("json"))
 	_ = viper.BindPFlag("jsonl", rootCmd.Flags().Lookup("jsonl"))
+	_ = viper.BindPFlag("progress-json", rootCmd.Flags().Lookup("progress-json"))
 	_ = viper.BindPFlag("unix", rootCmd.Flags().Lookup("unix"))
+	_ = viper.BindPFlag("time-series", rootCmd.Flags().Lookup("time-series"))
+	_ = viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("influx-token", rootCmd.Flags().Lookup("influx-token"))
 	_ = viper.BindPFlag("multi", rootCmd.Flags().Lookup("multi"))
-	_ = viper.BindPFlag("thread", rootCmd.Flags().Lookup("thread"))
-	_ = viper.BindPFlag("no-download", rootCmd.Flags().Lookup("no-download"))
-	_ = viper.BindPFlag("no-upload", rootCmd.Flags().Lookup("no-upload"))
-	_ = viper.BindPFlag("ping-mode", rootCmd.Flags().Lookup("ping-mode"))
+	_ = viper.BindPFlag("dual-stack", rootCmd.Flags().Lookup("dual-stack"))
+	_ = viper.BindPFlag("parallel-sources", rootCmd.Flags().Lookup("parallel-sources"))
+	_ = viper.BindPFlag("max-duration", rootCmd.Flags().Lookup("max-duration"))
+	_ = viper.BindPFlag("min-duration", rootCmd.Flags().Lookup("min-duration"))
+	_ = viper.BindPFlag("warm-up", rootCmd.Flags().Lookup("warm-up"))
+	_ = viper.BindPFlag("stability-cv", rootCmd.Flags().Lookup("stability-cv"))
+	_ = viper.BindPFlag("max-bytes", rootCmd.Flags().Lookup("max-bytes"))
 	_ = viper.BindPFlag("unit", rootCmd.Flags().Lookup("unit"))
+	_ = viper.BindPFlag("min-download", rootCmd.Flags().Lookup("min-download"))
+	_ = viper.BindPFlag("min-upload", rootCmd.Flags().Lookup("min-upload"))
+	_ = viper.BindPFlag("max-latency", rootCmd.Flags().Lookup("max-latency"))
+	_ = viper.BindPFlag("max-jitter", rootCmd.Flags().Lookup("max-jitter"))
+	_ = viper.BindPFlag("max-packet-loss", rootCmd.Flags().Lookup("max-packet-loss"))
 
 	// Add subcommands
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(citiesCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(monitorCmd)
 
 	// List command flags
 	listCmd.Flags().
 		String("location", "", "Change the location with a precise coordinate (format: lat,lon).")
 	listCmd.Flags().String("city", "", "Change the location with a predefined city label.")
 	listCmd.Flags().String("search", "", "Fuzzy search servers by a keyword.")
+	listCmd.Flags().Bool("refresh", false, "Fetch the server list and user information and rebuild the cache.")
+	listCmd.Flags().String("export", "", "Write the server list to a YAML or JSON catalogue file instead.")
+	listCmd.Flags().StringSlice("country", []string{}, "Only list servers in these countries.")
+	listCmd.Flags().StringSlice("sponsor", []string{}, "Only list servers of sponsors containing one of these names.")
+	listCmd.Flags().StringSlice("exclude-id", []string{}, "Do not list these server ids.")
+	listCmd.Flags().Float64("max-distance", 0, "Only list servers at most this many km away.")
+	listCmd.Flags().Duration("max-latency", 0, "Only list reachable servers with at most this latency (e.g. 50ms).")
+	listCmd.Flags().
+		String("sort", string(speedtest.SortByDistance), "Sort the servers by key (options: distance/latency/name).")
+	listCmd.Flags().Int("limit", 0, "List at most this many servers.")
+	listCmd.Flags().Bool("json", false, "Output the server list in json format.")
+	listCmd.Flags().Bool("csv", false, "Output the server list in csv format.")
+	listCmd.MarkFlagsMutuallyExclusive("json", "csv", "export")
 
 	// Bind list flags to viper
 	_ = viper.BindPFlag("location", listCmd.Flags().Lookup("location"))
 	_ = viper.BindPFlag("city", listCmd.Flags().Lookup("city"))
 	_ = viper.BindPFlag("search", listCmd.Flags().Lookup("search"))
+	_ = viper.BindPFlag("refresh", listCmd.Flags().Lookup("refresh"))
+	_ = viper.BindPFlag("export", listCmd.Flags().Lookup("export"))
+	_ = viper.BindPFlag("list-country", listCmd.Flags().Lookup("country"))
+	_ = viper.BindPFlag("list-sponsor", listCmd.Flags().Lookup("sponsor"))
+	_ = viper.BindPFlag("list-exclude-id", listCmd.Flags().Lookup("exclude-id"))
+	_ = viper.BindPFlag("max-distance", listCmd.Flags().Lookup("max-distance"))
+	_ = viper.BindPFlag("list-max-latency", listCmd.Flags().Lookup("max-latency"))
+	_ = viper.BindPFlag("sort", listCmd.Flags().Lookup("sort"))
+	_ = viper.BindPFlag("limit", listCmd.Flags().Lookup("limit"))
+	_ = viper.BindPFlag("list-json", listCmd.Flags().Lookup("json"))
+	_ = viper.BindPFlag("csv", listCmd.Flags().Lookup("csv"))
+
+	// Serve command flags
+	serveCmd.Flags().
+		String("listen", server.DefaultAddr, "Address to serve HTTP, TCP and UDP on.")
+
+	// Bind serve flags to viper
+	_ = viper.BindPFlag("listen", serveCmd.Flags().Lookup("listen"))
+
+	// History command flags
+	historyCmd.Flags().StringSlice("server", []string{}, "Only show results of these server ids.")
+	historyCmd.Flags().
+		String("since", "", "Only show results since a date, RFC3339 time or duration ago (e.g. 7d).")
+	historyCmd.Flags().
+		String("until", "", "Only show results before a date, RFC3339 time or duration ago (e.g. 24h).")
+	historyCmd.Flags().
+		Bool("summary", false, "Summarize min/median/p95 of speed, latency, jitter and packet loss.")
+	historyCmd.Flags().Bool("json", false, "Output results in json format.")
+
+	// Bind history flags to viper
+	_ = viper.BindPFlag("history-server", historyCmd.Flags().Lookup("server"))
+	_ = viper.BindPFlag("since", historyCmd.Flags().Lookup("since"))
+	_ = viper.BindPFlag("until", historyCmd.Flags().Lookup("until"))
+	_ = viper.BindPFlag("summary", historyCmd.Flags().Lookup("summary"))
+	_ = viper.BindPFlag("history-json", historyCmd.Flags().Lookup("json"))
+
+	// Monitor command flags
+	addTestFlags(monitorCmd, "monitor-")
+	monitorCmd.Flags().Duration("interval", app.DefaultMonitorInterval,
+		"Plain interval between the starts of two speedtest runs, counted from the start unless --align is set.")
+	monitorCmd.Flags().Bool("align", false,
+		"Start the runs at wall-clock multiples of --interval from midnight, like cron (e.g. at :00 and :30 for 30m).")
+	monitorCmd.Flags().
+		String("listen", app.DefaultMonitorAddr, "Address to serve the /metrics endpoint on.")
+	monitorCmd.Flags().Duration("history-window", exporter.DefaultHistoryWindow,
+		"Period of recorded results summarized in the history metrics.")
+
+	// Bind monitor flags to viper
+	_ = viper.BindPFlag("interval", monitorCmd.Flags().Lookup("interval"))
+	_ = viper.BindPFlag("align", monitorCmd.Flags().Lookup("align"))
+	_ = viper.BindPFlag("monitor-listen", monitorCmd.Flags().Lookup("listen"))
+	_ = viper.BindPFlag("history-window", monitorCmd.Flags().Lookup("history-window"))
 
 	// Set version
 	rootCmd.Version = output.Version()
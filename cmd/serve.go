@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/app"
+)
+
+// serveCmd represents the serve command.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a local speedtest server",
+	Long: "Run a speedtest.net compatible server that can be used as a target with " +
+		"--custom-url for closed-loop testing.",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		config := app.Config{
+			ListenAddr: viper.GetString("listen"),
+		}
+
+		return app.RunServe(config)
+	},
+}
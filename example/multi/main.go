@@ -20,7 +20,7 @@ func main() {
 		s := targets[0]
 		checkError(s.MultiDownloadTestContext(context.TODO(), targets))
 		checkError(s.MultiUploadTestContext(context.TODO(), targets))
-		_, _ = fmt.Fprintf(os.Stdout, "Download: %s, Upload: %s\n", s.DLSpeed, s.ULSpeed)
+		_, _ = fmt.Fprintf(os.Stdout, "Download: %s, Upload: %s\n", s.Context.FormatRate(s.DLSpeed), s.Context.FormatRate(s.ULSpeed))
 	}
 }
 
@@ -38,8 +38,8 @@ func main() {
 			os.Stdout,
 			"Latency: %s, Download: %s, Upload: %s\n",
 			server.Latency,
-			server.DLSpeed,
-			server.ULSpeed,
+			server.Context.FormatRate(server.DLSpeed),
+			server.Context.FormatRate(server.ULSpeed),
 		)
 		server.Context.Reset()
 	}
@@ -14,8 +14,7 @@ import (
 
 const packetSendingInterval = 100 * time.Millisecond
 
-// Note: The current packet loss analyzer does not support udp over http.
-// This means we cannot get packet loss through a proxy.
+// Note: The packet loss analyzer sends UDP packets, which cannot go through a proxy.
 func main() {
 	// 0. Fetching servers
 	serverList, err := speedtest.FetchServers()
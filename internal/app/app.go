@@ -6,11 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/nicholas-fedor/speedtest-go/internal/echo"
 	"github.com/nicholas-fedor/speedtest-go/internal/output"
 	"github.com/nicholas-fedor/speedtest-go/internal/parser"
 	"github.com/nicholas-fedor/speedtest-go/internal/task"
@@ -18,35 +23,148 @@ import (
 	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
 )
 
+// ErrInvalidDuration indicates inconsistent test duration options.
+var ErrInvalidDuration = errors.New("invalid test duration")
+
 const (
 	bytesToMB                 = 1000 * 1000
 	nanoToMilli               = 1000000
 	packetLossAnalyzerTimeout = 40 * time.Second
-	echoInterval              = 500 * time.Millisecond
 	sleepAfterTests           = 30 * time.Second
 )
 
+// validateDurations checks that the test duration options are consistent.
+func validateDurations(cfg Config) error {
+	maxDuration := cfg.MaxDuration
+	if maxDuration <= 0 {
+		maxDuration = speedtest.DefaultCaptureTime
+	}
+
+	switch {
+	case cfg.MinDuration < 0 || cfg.WarmUp < 0 || cfg.StabilityCV < 0:
+		return fmt.Errorf("%w: durations and --stability-cv must not be negative", ErrInvalidDuration)
+	case cfg.MinDuration > maxDuration:
+		return fmt.Errorf("%w: --min-duration %v exceeds the maximum duration %v",
+			ErrInvalidDuration, cfg.MinDuration, maxDuration)
+	case cfg.WarmUp >= maxDuration:
+		return fmt.Errorf("%w: --warm-up %v must be shorter than the maximum duration %v",
+			ErrInvalidDuration, cfg.WarmUp, maxDuration)
+	default:
+		return nil
+	}
+}
+
+// ipFamily returns the address family forced by the configured flags.
+func ipFamily(cfg Config) speedtest.IPFamily {
+	switch {
+	case cfg.IPv4:
+		return speedtest.IPFamilyIPv4
+	case cfg.IPv6:
+		return speedtest.IPFamilyIPv6
+	default:
+		return speedtest.IPFamilyAny
+	}
+}
+
 // setupSpeedtestClient creates and configures the speedtest client.
 func setupSpeedtestClient(cfg Config) *speedtest.Speedtest {
+	return newSpeedtestClient(cfg, ipFamily(cfg))
+}
+
+// newSpeedtestClient creates a speedtest client connecting over the family.
+func newSpeedtestClient(cfg Config, family speedtest.IPFamily) *speedtest.Speedtest {
 	return speedtest.New(speedtest.WithUserConfig(
 		&speedtest.UserConfig{
-			UserAgent:      cfg.UserAgent,
-			Proxy:          cfg.Proxy,
-			Source:         cfg.Source,
-			DNSBindSource:  cfg.DNSBindSource,
-			Debug:          cfg.Debug,
-			PingMode:       parser.ParseProto(cfg.PingMode),
-			SavingMode:     cfg.SavingMode,
-			MaxConnections: cfg.Thread,
-			CityFlag:       cfg.City,
-			LocationFlag:   cfg.Location,
-			Keyword:        cfg.Search,
+			UserAgent:             cfg.UserAgent,
+			Proxy:                 cfg.Proxy,
+			Source:                cfg.source,
+			DNSBindSource:         cfg.DNSBindSource,
+			DNSServer:             cfg.DNSServer,
+			IPFamily:              family,
+			HTTPS:                 cfg.httpsMode,
+			TLSCAFile:             cfg.CACert,
+			TLSCertFile:           cfg.ClientCert,
+			TLSKeyFile:            cfg.ClientKey,
+			TLSServerName:         cfg.TLSServerName,
+			TLSInsecureSkipVerify: cfg.Insecure,
+			Debug:                 cfg.Debug,
+			Unit:                  parser.ParseUnit(cfg.Unit),
+			PingMode:              parser.ParseProto(cfg.PingMode),
+			TransferMode:          parser.ParseProto(cfg.TransferMode),
+			SavingMode:            cfg.SavingMode,
+			MaxConnections:        cfg.Thread,
+			TimeSeries:            cfg.TimeSeries,
+			MaxDuration:           cfg.MaxDuration,
+			MinDuration:           cfg.MinDuration,
+			WarmUp:                cfg.WarmUp,
+			StabilityCV:           cfg.StabilityCV,
+			MaxDataVolume:         cfg.maxDataVolume,
+			CityFlag:              cfg.City,
+			LocationFlag:          cfg.Location,
+			Keyword:               cfg.Search,
 		}))
 }
 
+// customServers creates the servers of the custom URLs. Servers without an id
+// are numbered if there are several, so that their results are distinguishable.
+func customServers(speedtestClient *speedtest.Speedtest, customURLs []string) (speedtest.Servers, error) {
+	servers := make(speedtest.Servers, 0, len(customURLs))
+	ids := make(map[string]bool, len(customURLs))
+
+	for i, customURL := range customURLs {
+		options, err := parser.ParseCustomServer(customURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse --custom-url: %w", err)
+		}
+
+		if len(options.ID) == 0 && len(customURLs) > 1 {
+			options.ID = "Custom-" + strconv.Itoa(i+1)
+		}
+
+		server, err := speedtestClient.NewCustomServer(options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create custom server: %w", err)
+		}
+
+		if ids[server.ID] {
+			return nil, fmt.Errorf("%w %q: duplicate id %s", parser.ErrInvalidCustomServer, customURL, server.ID)
+		}
+
+		ids[server.ID] = true
+
+		servers = append(servers, server)
+	}
+
+	return servers, nil
+}
+
+// checkError terminates on the error of the task like task.CheckError, unless
+// the run was interrupted: the error is then left to the caller, which keeps
+// the results measured so far.
+func checkError(ctx context.Context, t *task.Task, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	t.CheckError(err)
+}
+
+// testFailed marks the task as failed by the error of a test, unless the run
+// was interrupted: the error is then left to the caller, which keeps the
+// results measured so far.
+func testFailed(ctx context.Context, t *task.Task, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	t.Fail(err)
+
+	return true
+}
+
 // retrieveServers fetches and selects the target servers.
 func retrieveServers(
-	speedtestClient *speedtest.Speedtest, cfg Config, taskManager *task.Manager,
+	ctx context.Context, speedtestClient *speedtest.Speedtest, cfg Config, taskManager *task.Manager,
 ) (speedtest.Servers, speedtest.Servers) {
 	var (
 		err     error
@@ -56,16 +174,33 @@ func retrieveServers(
 
 	taskManager.Run("Retrieving Servers", func(task *task.Task) {
 		switch {
-		case len(cfg.CustomURL) > 0:
-			var target *speedtest.Server
+		case len(cfg.CustomURLs) > 0:
+			targets, err = customServers(speedtestClient, cfg.CustomURLs)
+			checkError(ctx, task, err)
+
+			// like a fetched list, the custom servers share the multi-server test.
+			servers = targets
+			if cfg.Multi {
+				targets = targets[:1]
+			}
 
-			target, err = speedtestClient.CustomServer(cfg.CustomURL)
-			task.CheckError(err)
+			if len(servers) == 1 {
+				task.Println("Skip: Using Custom Server")
+			} else {
+				task.Printf("Skip: Using %d Custom Servers", len(servers))
+			}
+		case len(cfg.ServerIDs) > 0:
+			var cached bool
 
-			targets = []*speedtest.Server{target}
+			targets, cached, err = localServersByID(ctx, speedtestClient, cfg)
+			checkError(ctx, task, err)
+
+			if cached {
+				task.Printf("Found %d Specified Local Server(s)", len(targets))
+
+				break
+			}
 
-			task.Println("Skip: Using Custom Server")
-		case len(cfg.ServerIDs) > 0:
 			type fetchResult struct {
 				server *speedtest.Server
 				err    error
@@ -74,7 +209,7 @@ func retrieveServers(
 			results := make(chan fetchResult, len(cfg.ServerIDs))
 			for _, id := range cfg.ServerIDs {
 				go func(id int) {
-					serverPtr, errFetch := speedtestClient.FetchServerByID(strconv.Itoa(id))
+					serverPtr, errFetch := speedtestClient.FetchServerByIDContext(ctx, strconv.Itoa(id))
 					results <- fetchResult{server: serverPtr, err: errFetch}
 				}(id)
 			}
@@ -88,14 +223,25 @@ func retrieveServers(
 				targets = append(targets, res.server)
 			}
 
-			task.CheckError(err)
+			checkError(ctx, task, err)
 			task.Printf("Found %d Specified Public Server(s)", len(targets))
 		default:
-			servers, err = speedtestClient.FetchServers()
-			task.CheckError(err)
+			servers, err = serverList(ctx, speedtestClient, cfg)
+			checkError(ctx, task, err)
 			task.Printf("Found %d Public Servers", len(servers))
-			targets, err = servers.FindServer(cfg.ServerIDs)
-			task.CheckError(err)
+
+			var target *speedtest.Server
+
+			target, err = speedtestClient.SelectServer(ctx, servers, cfg.selection)
+			checkError(ctx, task, err)
+
+			if target == nil {
+				break // interrupted
+			}
+
+			targets = speedtest.Servers{target}
+			// the filters also apply to the servers of the multi-server test.
+			servers = servers.Filter(cfg.selection)
 		}
 
 		task.Complete()
@@ -104,131 +250,278 @@ func retrieveServers(
 	return servers, targets
 }
 
+// dualStackTargets returns every target twice, tested over IPv4 and over IPv6
+// by clients of their own.
+func dualStackTargets(cfg Config, speedtestClient *speedtest.Speedtest, targets speedtest.Servers) speedtest.Servers {
+	clients := []*speedtest.Speedtest{
+		newSpeedtestClient(cfg, speedtest.IPFamilyIPv4),
+		newSpeedtestClient(cfg, speedtest.IPFamilyIPv6),
+	}
+
+	pairs := make(speedtest.Servers, 0, len(targets)*len(clients))
+
+	for _, server := range targets {
+		for _, client := range clients {
+			client.User = speedtestClient.User
+			pairs = append(pairs, client.CloneServer(server))
+		}
+	}
+
+	return pairs
+}
+
+// sourceTargets returns every target once per source, tested by clients bound
+// to the sources, in the order of the sources. Unless the cache is used, every
+// client fetches the user information of its uplink, which falls back to the
+// one of the default client.
+func sourceTargets(
+	ctx context.Context, cfg Config, speedtestClient *speedtest.Speedtest, targets speedtest.Servers,
+	taskManager *task.Manager,
+) speedtest.Servers {
+	clients := make([]*speedtest.Speedtest, 0, len(cfg.Sources))
+
+	for _, source := range cfg.Sources {
+		sourceCfg := cfg
+		sourceCfg.source = source
+
+		clients = append(clients, newSpeedtestClient(sourceCfg, ipFamily(cfg)))
+	}
+
+	taskManager.Run("Retrieving User Information per Source", func(t *task.Task) {
+		users := make([]string, 0, len(clients))
+
+		for i, client := range clients {
+			client.User = speedtestClient.User
+
+			if !cacheEnabled(cfg) && ctx.Err() == nil {
+				user, err := client.FetchUserInfoContext(ctx)
+				if err == nil {
+					client.User = user
+				}
+			}
+
+			if client.User != nil {
+				users = append(users, fmt.Sprintf("%s %s (%s)", cfg.Sources[i], client.User.IP, client.User.Isp))
+			}
+		}
+
+		t.Printf("ISP: %s", strings.Join(users, ", "))
+		t.Complete()
+	})
+
+	groups := make(speedtest.Servers, 0, len(targets)*len(clients))
+
+	for _, server := range targets {
+		for _, client := range clients {
+			groups = append(groups, client.CloneServer(server))
+		}
+	}
+
+	return groups
+}
+
 // runTest executes the bandwidth test based on configuration.
 func runTest(
+	ctx context.Context,
 	server *speedtest.Server,
 	cfg Config,
-	task *task.Task,
 	isDownload bool,
 	servers speedtest.Servers,
-) {
+) error {
 	switch {
 	case cfg.Multi && isDownload:
-		task.CheckError(server.MultiDownloadTestContext(context.Background(), servers))
+		return server.MultiDownloadTestContext(ctx, servers)
 	case cfg.Multi && !isDownload:
-		task.CheckError(server.MultiUploadTestContext(context.Background(), servers))
+		return server.MultiUploadTestContext(ctx, servers)
 	case isDownload:
-		task.CheckError(server.DownloadTest())
+		return server.DownloadTestContext(ctx)
 	default:
-		task.CheckError(server.UploadTest())
-	}
-}
-
-// setCallback sets the appropriate callback for download or upload.
-func setCallback(
-	client *speedtest.Speedtest,
-	isDownload bool,
-	accEcho *echo.AccompanyEcho,
-	task *task.Task,
-) {
-	if isDownload {
-		client.SetCallbackDownload(func(downRate speedtest.ByteRate) {
-			updateWithLatency(task, downRate, accEcho, "Download")
-		})
-	} else {
-		client.SetCallbackUpload(func(upRate speedtest.ByteRate) {
-			updateWithLatency(task, upRate, accEcho, "Upload")
-		})
+		return server.UploadTestContext(ctx)
 	}
 }
 
-// updateWithLatency updates the task with rate and latency information.
-func updateWithLatency(
-	task *task.Task,
-	rate speedtest.ByteRate,
-	accEcho *echo.AccompanyEcho,
+// rateObserver returns an observer updating the task with the rate samples of
+// the phase of the server and the latest latency under load.
+func rateObserver(
+	t *task.Task,
+	server *speedtest.Server,
+	phase speedtest.Phase,
 	prefix string,
-) {
-	lc := accEcho.CurrentLatency()
-	if lc == 0 {
-		task.Updatef("%s: %s (Latency: --)", prefix, rate)
-	} else {
-		task.Updatef("%s: %s (Latency: %dms)", prefix, rate, lc/nanoToMilli)
-	}
+) speedtest.Observer {
+	var latency atomic.Int64
+
+	return t.Observer(func(event speedtest.Event) string {
+		if event.Phase != phase || event.Server != server {
+			return ""
+		}
+
+		if event.Type == speedtest.EventPingSample {
+			latency.Store(int64(event.Latency))
+
+			return ""
+		}
+
+		if event.Type != speedtest.EventRateSample {
+			return ""
+		}
+
+		rate := server.Context.FormatRate(event.Rate)
+
+		lc := latency.Load()
+		if lc == 0 {
+			return fmt.Sprintf("%s: %s (Latency: --)", prefix, rate)
+		}
+
+		return fmt.Sprintf("%s: %s (Latency: %dms)", prefix, rate, lc/nanoToMilli)
+	})
 }
 
-// runBandwidthTest performs download or upload bandwidth tests.
+// runBandwidthTest performs download or upload bandwidth tests and returns the
+// error of a failed test. Nothing is tested once the run is interrupted.
 func runBandwidthTest(
+	ctx context.Context,
 	isDownload bool, server *speedtest.Server, cfg Config, taskManager *task.Manager,
-	accEcho *echo.AccompanyEcho, speedtestClient *speedtest.Speedtest, servers speedtest.Servers,
-) {
+	speedtestClient *speedtest.Speedtest, servers speedtest.Servers,
+) error {
 	taskName := "Download"
 	trigger := !cfg.NoDownload
+	phase := speedtest.PhaseDownload
 
 	if !isDownload {
 		taskName = "Upload"
 		trigger = !cfg.NoUpload
+		phase = speedtest.PhaseUpload
 	}
 
-	taskManager.RunWithTrigger(trigger, taskName, func(task *task.Task) {
-		accEcho.Run()
+	var failure error
 
-		setCallback(speedtestClient, isDownload, accEcho, task)
+	taskManager.RunWithTrigger(trigger && ctx.Err() == nil, taskName, func(task *task.Task) {
+		unsubscribe := speedtestClient.Subscribe(rateObserver(task, server, phase, taskName))
+		err := runTest(ctx, server, cfg, isDownload, servers)
+		unsubscribe()
 
-		runTest(server, cfg, task, isDownload, servers)
+		if testFailed(ctx, task, err) {
+			failure = fmt.Errorf("%s: %w", phase, err)
 
-		accEcho.Stop()
-		mean, _, std, minL, maxL := speedtest.StandardDeviation(accEcho.Latencies())
+			return
+		}
 
+		direction := speedtest.DirectionDownload
 		speed := server.DLSpeed
+		budgetLimited := server.DLBudgetLimited
 
 		total := float64(server.Context.GetTotalDownload())
 		if !isDownload {
+			direction = speedtest.DirectionUpload
 			speed = server.ULSpeed
+			budgetLimited = server.ULBudgetLimited
 			total = float64(server.Context.GetTotalUpload())
 		}
 
+		loaded := server.Bufferbloat.Loaded(direction)
+		if loaded == nil {
+			loaded = &speedtest.LoadedLatency{}
+		}
+
+		note := ""
+		if budgetLimited {
+			note = " (Budget limited)"
+		}
+
+		if ctx.Err() != nil {
+			note += " (Interrupted)"
+		}
+
 		task.Printf(
-			"%s: %s (Used: %.2fMB) (Latency: %dms Jitter: %dms Min: %dms Max: %dms)",
+			"%s: %s (Used: %.2fMB)%s (Latency: %dms Jitter: %dms Min: %dms Max: %dms)",
 			taskName,
-			speed,
+			speedtestClient.FormatRate(speed),
 			total/bytesToMB,
-			mean/nanoToMilli,
-			std/nanoToMilli,
-			minL/nanoToMilli,
-			maxL/nanoToMilli,
+			note,
+			loaded.Mean.Milliseconds(),
+			loaded.Jitter.Milliseconds(),
+			loaded.Min.Milliseconds(),
+			loaded.Max.Milliseconds(),
 		)
 		task.Complete()
 	})
+
+	return failure
 }
 
-// runServerTests performs tests for a single server.
+// runServerTests performs tests for a single server. A failed test ends the
+// tests of the server, its error is returned and kept in Server.Error.
 func runServerTests(
+	ctx context.Context,
 	server *speedtest.Server, cfg Config, taskManager *task.Manager,
 	speedtestClient *speedtest.Speedtest, servers speedtest.Servers,
-) {
-	if !cfg.JSONOutput && !cfg.JSONLOutput {
+) error {
+	defer func() {
+		taskManager.Reset()
+		speedtestClient.Reset()
+	}()
+
+	if !cfg.machineOutput {
 		log.Println()
 	}
 
-	taskManager.Println("Test Server: " + server.String())
+	title := "Test Server: " + server.String()
+	if server.IPFamily != speedtest.IPFamilyAny {
+		title += " over " + server.IPFamily.String()
+	}
+
+	if len(cfg.Sources) > 1 {
+		title += " via " + server.Source
+	}
+
+	taskManager.Println(title)
+
+	var failure error
+
 	taskManager.Run("Latency: --", func(task *task.Task) {
-		task.CheckError(server.PingTest(func(latency time.Duration) {
-			task.Updatef("Latency: %v", latency)
+		unsubscribe := speedtestClient.Subscribe(task.Observer(func(event speedtest.Event) string {
+			if event.Type != speedtest.EventPingSample || event.Server != server {
+				return ""
+			}
+
+			return fmt.Sprintf("Latency: %v", event.Latency)
 		}))
-		task.Printf("Latency: %v Jitter: %v Min: %v Max: %v",
-			server.Latency, server.Jitter, server.MinLatency, server.MaxLatency)
+		err := server.PingTestContext(ctx, nil)
+		unsubscribe()
+
+		if testFailed(ctx, task, err) {
+			failure = fmt.Errorf("%s: %w", speedtest.PhasePing, err)
+
+			return
+		}
+
+		note := ""
+		if ctx.Err() != nil {
+			note = " (Interrupted)"
+		}
+
+		task.Printf("Latency: %v Jitter: %v Min: %v Max: %v%s",
+			server.Latency, server.Jitter, server.MinLatency, server.MaxLatency, note)
 		task.Complete()
 	})
 
-	// create a packet loss analyzer
-	analyzer := speedtest.NewPacketLossAnalyzer(&speedtest.PacketLossAnalyzerOptions{
-		SourceInterface: cfg.Source,
-	})
+	if failure != nil {
+		server.Error = failure.Error()
+
+		return failure
+	}
+
+	// only HTTP pings are broken down into phases.
+	if server.PingPhases != nil {
+		taskManager.Println("Latency Phases: " + server.PingPhases.String())
+	}
+
+	// create a packet loss analyzer connecting like the client of the server
+	analyzer := server.Context.NewPacketLossAnalyzer(nil)
 
 	blocker := sync.WaitGroup{}
 	packetLossAnalyzerCtx, packetLossAnalyzerCancel := context.WithTimeout(
-		context.Background(),
+		ctx,
 		packetLossAnalyzerTimeout,
 	)
 
@@ -250,57 +543,175 @@ func runServerTests(
 		task.Complete()
 	})
 
-	// create accompany Echo
-	accEcho := echo.New(server, echoInterval)
-
-	runBandwidthTest(true, server, cfg, taskManager, accEcho, speedtestClient, servers)
-	runBandwidthTest(false, server, cfg, taskManager, accEcho, speedtestClient, servers)
+	// the upload is not tested once the download failed.
+	failure = runBandwidthTest(ctx, true, server, cfg, taskManager, speedtestClient, servers)
+	if failure == nil {
+		failure = runBandwidthTest(ctx, false, server, cfg, taskManager, speedtestClient, servers)
+	}
 
 	if cfg.NoUpload && cfg.NoDownload {
-		time.Sleep(sleepAfterTests)
+		select {
+		case <-ctx.Done():
+		case <-time.After(sleepAfterTests):
+		}
 	}
 
 	packetLossAnalyzerCancel()
 	blocker.Wait()
 
-	if !cfg.JSONOutput && !cfg.JSONLOutput {
+	if !cfg.machineOutput {
 		taskManager.Println(server.PacketLoss.String())
+
+		if server.Bufferbloat != nil {
+			taskManager.Println(server.Bufferbloat.String())
+		}
 	}
 
-	taskManager.Reset()
-	speedtestClient.Reset()
+	if failure != nil {
+		server.Error = failure.Error()
+	}
+
+	return failure
 }
 
-// runTests performs the actual speed tests on the selected servers.
+// runParallelSources tests the targets of every source at the same time, the
+// targets of a source one after another. The targets are the servers of
+// sourceTargets. The progress of a source is shown by a task of its own and its
+// tests are only printed by the combined report. It returns the tested
+// servers, in the order of the targets.
+func runParallelSources(
+	ctx context.Context, targets speedtest.Servers, cfg Config, taskManager *task.Manager,
+) speedtest.Servers {
+	tested := make([]bool, len(targets))
+	wg := sync.WaitGroup{}
+
+	for i, source := range cfg.Sources {
+		wg.Go(func() {
+			// the output of the tests of a source would interleave with the others.
+			quiet := task.NewManager(true, false)
+
+			taskManager.Run(source+": Waiting", func(t *task.Task) {
+				for j := i; j < len(targets); j += len(cfg.Sources) {
+					if ctx.Err() != nil {
+						break
+					}
+
+					server := targets[j]
+					t.Updatef("%s: Testing %s", source, server.String())
+
+					err := runServerTests(ctx, server, cfg, quiet, server.Context, nil)
+					tested[j] = true
+
+					// a failed link is reported and the next target tested.
+					if err != nil {
+						t.Printf("%s: Failed: %v", source, err)
+
+						continue
+					}
+
+					t.Printf("%s: Latency: %v Download: %s Upload: %s", source, server.Latency,
+						server.Context.FormatRate(server.DLSpeed), server.Context.FormatRate(server.ULSpeed))
+				}
+
+				t.Complete()
+			})
+		})
+	}
+
+	wg.Wait()
+
+	results := make(speedtest.Servers, 0, len(targets))
+
+	for j, server := range targets {
+		if tested[j] {
+			results = append(results, server)
+		}
+	}
+
+	return results
+}
+
+// targetClients returns the distinct clients testing the targets.
+func targetClients(targets speedtest.Servers) []*speedtest.Speedtest {
+	var clients []*speedtest.Speedtest
+
+	for _, server := range targets {
+		if !slices.Contains(clients, server.Context) {
+			clients = append(clients, server.Context)
+		}
+	}
+
+	return clients
+}
+
+// runTests performs the actual speed tests on the selected servers. Once the
+// run is interrupted, the results of the server under test are still written
+// and the remaining servers are skipped.
 func runTests(
-	speedtestClient *speedtest.Speedtest, targets, servers speedtest.Servers,
-	cfg Config, taskManager *task.Manager,
+	ctx context.Context,
+	targets, servers speedtest.Servers,
+	cfg Config, taskManager *task.Manager, sinks speedtest.ResultSink,
 ) error {
-	// 3. test each selected server with ping, download and upload.
-	for _, server := range targets {
-		runServerTests(server, cfg, taskManager, speedtestClient, servers)
+	// the results are written to the sinks through the events of the clients,
+	// like the progress lines.
+	results := speedtest.NewSinkObserver(sinks)
+	if progressOutput(cfg) {
+		cfg.progress = newProgressObserver(os.Stdout)
 	}
 
-	taskManager.Stop()
+	for _, client := range targetClients(targets) {
+		unsubscribe := client.Subscribe(results)
+		defer unsubscribe()
 
-	if cfg.JSONOutput {
-		json, errMarshal := speedtestClient.JSON(targets)
-		if errMarshal != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", errMarshal)
+		if cfg.progress != nil {
+			unsubscribe := client.Subscribe(cfg.progress)
+			defer unsubscribe()
 		}
+	}
 
-		log.Print(string(json))
-	} else if cfg.JSONLOutput {
+	// 3. test each selected server with ping, download and upload, by its own
+	// client, which differs per address family in the dual-stack mode and per
+	// source with several sources.
+	if cfg.ParallelSources {
+		for _, server := range runParallelSources(ctx, targets, cfg, taskManager) {
+			server.Context.EmitResult(server)
+		}
+	} else {
 		for _, server := range targets {
-			json, errMarshal := speedtestClient.JSONL(server)
-			if errMarshal != nil {
-				return fmt.Errorf("failed to marshal JSONL: %w", errMarshal)
+			if ctx.Err() != nil {
+				break
 			}
 
-			log.Println(string(json))
+			// a failed server is kept in the results with its error.
+			_ = runServerTests(ctx, server, cfg, taskManager, server.Context, servers)
+			server.Context.EmitResult(server)
 		}
 	}
 
+	taskManager.Stop()
+
+	switch {
+	case cfg.machineOutput || ctx.Err() != nil:
+	case cfg.DualStack:
+		output.ShowDualStack(targets, parser.ParseUnit(cfg.Unit))
+	case len(cfg.Sources) > 1:
+		output.ShowSources(targets, len(cfg.Sources), parser.ParseUnit(cfg.Unit))
+	}
+
+	if cfg.progress != nil {
+		err := cfg.progress.Err()
+		if err != nil {
+			_ = sinks.Close()
+
+			return fmt.Errorf("failed to write progress: %w", err)
+		}
+	}
+
+	err := errors.Join(results.Err(), sinks.Close())
+	if err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
 	return nil
 }
 
@@ -308,22 +719,110 @@ func runTests(
 func RunSpeedtest(cfg Config) error {
 	setupConfig(cfg)
 
+	thresholds, err := parseThresholds(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = validateDurations(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.selection, err = serverSelection(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.httpsMode, err = connectionOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.source, err = sourceOptions(cfg, true)
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.MaxBytes) > 0 {
+		cfg.maxDataVolume, err = parser.ParseSize(cfg.MaxBytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse --max-bytes: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	postCtx, cancelPosts := postContext(ctx)
+	defer cancelPosts()
+
+	sinks, stdout, err := openSinks(postCtx, cfg)
+	if err != nil {
+		return err
+	}
+
+	// keep stdout clean for the machine readable outputs.
+	cfg.machineOutput = stdout
+
 	speedtestClient := setupSpeedtestClient(cfg)
 
-	output.AppInfo(cfg.JSONOutput, cfg.JSONLOutput)
+	output.AppInfo(cfg.machineOutput, false)
 
 	// retrieving user information
-	taskManager := task.NewManager(cfg.JSONOutput || cfg.JSONLOutput, cfg.UnixOutput)
-	taskManager.AsyncRun("Retrieving User Information", func(t *task.Task) {
-		u, err := speedtestClient.FetchUserInfo()
-		t.CheckError(err)
+	taskManager := task.NewManager(cfg.machineOutput, cfg.UnixOutput)
+	retrieveUserTask := func(t *task.Task) {
+		u, err := retrieveUser(ctx, speedtestClient, cfg)
+		if err != nil && len(cfg.CustomURLs) > 0 && ctx.Err() == nil {
+			// the ISP is only displayed, so custom servers remain testable without it.
+			t.Printf("Warning: retrieving user information, err: %v", err)
+			t.Complete()
+
+			return
+		}
+
+		checkError(ctx, t, err)
+
+		if u == nil {
+			return // interrupted
+		}
+
 		t.Printf("ISP: %s", u.String())
 		t.Complete()
-	})
+	}
 
-	servers, targets := retrieveServers(speedtestClient, cfg, taskManager)
+	// the cached server list includes the user and the distances to catalogue
+	// servers are computed from it, so it must be known first.
+	if cacheEnabled(cfg) || len(cfg.ServersFile) > 0 {
+		taskManager.Run("Retrieving User Information", retrieveUserTask)
+	} else {
+		taskManager.AsyncRun("Retrieving User Information", retrieveUserTask)
+	}
+
+	servers, targets := retrieveServers(ctx, speedtestClient, cfg, taskManager)
+	switch {
+	case ctx.Err() != nil:
+	case cfg.DualStack:
+		targets = dualStackTargets(cfg, speedtestClient, targets)
+	case len(cfg.Sources) > 1:
+		targets = sourceTargets(ctx, cfg, speedtestClient, targets, taskManager)
+	}
 
 	taskManager.Reset()
 
-	return runTests(speedtestClient, targets, servers, cfg, taskManager)
+	err = runTests(ctx, targets, servers, cfg, taskManager, sinks)
+
+	// the partial results of an interrupted run are printed, but neither
+	// recorded nor checked against the thresholds.
+	if ctx.Err() != nil {
+		return errors.Join(err, &ExitError{Code: ExitCodeInterrupted, Err: ErrInterrupted})
+	}
+
+	recordHistory(cfg, speedtestClient, targets)
+
+	if err != nil {
+		return err
+	}
+
+	return checkResults(cfg, thresholds, targets)
 }
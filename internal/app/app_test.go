@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
+)
+
+// startLocalServer serves a local speedtest server and returns its address.
+func startLocalServer(t *testing.T) string {
+	t.Helper()
+
+	lc := &net.ListenConfig{}
+
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	packetConn, err := lc.ListenPacket(context.Background(), "udp", listener.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- server.New(nil).Serve(ctx, listener, packetConn) }()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return listener.Addr().String()
+}
+
+// closedUDPAddr returns a local UDP address nothing listens on, so that a DNS
+// server configured with it refuses every query.
+func closedUDPAddr(t *testing.T) string {
+	t.Helper()
+
+	packetConn, err := (&net.ListenConfig{}).ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := packetConn.LocalAddr().String()
+	require.NoError(t, packetConn.Close())
+
+	return addr
+}
+
+func TestRunSpeedtest_customServerWithoutUserInfo(t *testing.T) {
+	t.Parallel()
+
+	output := filepath.Join(t.TempDir(), "results.json")
+
+	// the user information is fetched from speedtest.net by name, which the
+	// refused DNS server cannot resolve.
+	err := RunSpeedtest(Config{
+		CustomURLs:   []string{"http://" + startLocalServer(t)},
+		DNSServer:    closedUDPAddr(t),
+		PingMode:     "tcp",
+		TransferMode: "tcp",
+		Thread:       1,
+		MaxDuration:  time.Second,
+		UnixOutput:   true,
+		Outputs:      []string{"json:" + output},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	var results struct {
+		UserInfo *speedtest.User `json:"userInfo"`
+		Servers  []struct {
+			DLSpeed float64 `json:"dlSpeed"`
+			ULSpeed float64 `json:"ulSpeed"`
+			Error   string  `json:"error"`
+		} `json:"servers"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &results))
+	assert.Nil(t, results.UserInfo)
+	require.Len(t, results.Servers, 1)
+	assert.Empty(t, results.Servers[0].Error)
+	assert.Positive(t, results.Servers[0].DLSpeed)
+	assert.Positive(t, results.Servers[0].ULSpeed)
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/cache"
+	"github.com/nicholas-fedor/speedtest-go/internal/catalog"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// ErrOfflineNoCache indicates that offline mode was requested without a cached server list.
+var ErrOfflineNoCache = errors.New("offline mode requires a cached server list, run `speedtest-go list --refresh` first")
+
+// cacheEnabled reports whether the server list cache is read or written.
+func cacheEnabled(cfg Config) bool {
+	return cfg.CachedServers || cfg.Offline || cfg.Refresh
+}
+
+// cacheKey identifies the server query, so that lists of other queries are not reused.
+func cacheKey(cfg Config) string {
+	return strings.Join([]string{
+		"search=" + cfg.Search,
+		"city=" + cfg.City,
+		"location=" + cfg.Location,
+	}, "&")
+}
+
+// openServerCache opens the server list cache at the configured or default path.
+func openServerCache(cfg Config) (*cache.Cache, error) {
+	path := cfg.CacheFile
+	if len(path) == 0 {
+		var err error
+
+		path, err = cache.DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve cache file: %w", err)
+		}
+	}
+
+	return cache.New(path, cfg.CacheTTL), nil
+}
+
+// cachedEntry returns the cached server list if it may be used: while it is
+// fresh, and at any age in offline mode. It returns nil when the list must be
+// fetched instead.
+func cachedEntry(cfg Config) (*cache.Entry, error) {
+	if cfg.Refresh || (!cfg.CachedServers && !cfg.Offline) {
+		return nil, nil
+	}
+
+	serverCache, err := openServerCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := serverCache.Load(cacheKey(cfg))
+
+	switch {
+	case err == nil:
+		return entry, nil
+	case errors.Is(err, cache.ErrExpired) && cfg.Offline:
+		return entry, nil
+	case cfg.Offline:
+		return nil, fmt.Errorf("%w: %w", ErrOfflineNoCache, err)
+	case errors.Is(err, cache.ErrMiss), errors.Is(err, cache.ErrExpired):
+		return nil, nil
+	default:
+		// an unreadable cache must not break online runs.
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+
+		return nil, nil
+	}
+}
+
+// restoreEntry attaches the cached servers and user to the client. The servers
+// are not pinged, their latency is unknown until they are.
+func restoreEntry(speedtestClient *speedtest.Speedtest, entry *cache.Entry) speedtest.Servers {
+	for _, server := range entry.Servers {
+		server.Context = speedtestClient
+	}
+
+	speedtestClient.User = entry.User
+
+	return entry.Servers
+}
+
+// retrieveUser returns the user information, from the cache when it is used.
+func retrieveUser(ctx context.Context, speedtestClient *speedtest.Speedtest, cfg Config) (*speedtest.User, error) {
+	entry, err := cachedEntry(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && entry.User != nil {
+		speedtestClient.User = entry.User
+
+		return entry.User, nil
+	}
+
+	if cfg.Offline {
+		return nil, fmt.Errorf("%w: the cached server list has no user information", ErrOfflineNoCache)
+	}
+
+	user, err := speedtestClient.FetchUserInfoContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user info: %w", err)
+	}
+
+	return user, nil
+}
+
+// serverList returns the servers of the catalogue file if one is configured,
+// and otherwise the server list from the cache when it may be used, or fetched.
+// A fetched list is cached when caching is enabled.
+func serverList(ctx context.Context, speedtestClient *speedtest.Speedtest, cfg Config) (speedtest.Servers, error) {
+	if len(cfg.ServersFile) > 0 {
+		servers, err := catalog.Load(cfg.ServersFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return speedtestClient.PrepareServers(ctx, servers)
+	}
+
+	entry, err := cachedEntry(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		// the cached servers are ranked by their current latency.
+		return speedtestClient.PrepareServers(ctx, restoreEntry(speedtestClient, entry))
+	}
+
+	servers, err := speedtestClient.FetchServerListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch servers: %w", err)
+	}
+
+	if cacheEnabled(cfg) {
+		err = saveServerList(cfg, speedtestClient, servers)
+		if err != nil {
+			if cfg.Refresh {
+				return nil, err
+			}
+
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	return servers, nil
+}
+
+// saveServerList replaces the cached server list. The servers are stored as
+// listed, without the latency measured now or the HTTPS upgrade of a test.
+func saveServerList(cfg Config, speedtestClient *speedtest.Speedtest, servers speedtest.Servers) error {
+	serverCache, err := openServerCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	listings := make(speedtest.Servers, 0, len(servers))
+	for _, server := range servers {
+		listings = append(listings, server.Listing())
+	}
+
+	err = serverCache.Save(&cache.Entry{
+		FetchedAt: time.Now(),
+		Key:       cacheKey(cfg),
+		User:      speedtestClient.User,
+		Servers:   listings,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache server list: %w", err)
+	}
+
+	return nil
+}
+
+// localServersByID looks the server ids up in the catalogue file or the cached
+// server list. It reports false if there is no such list or the cached list
+// misses any of the servers; a catalogue and the cache in offline mode are
+// authoritative, so the servers found are all there is.
+func localServersByID(
+	ctx context.Context,
+	speedtestClient *speedtest.Speedtest,
+	cfg Config,
+) (speedtest.Servers, bool, error) {
+	var servers speedtest.Servers
+
+	if len(cfg.ServersFile) > 0 {
+		var err error
+
+		servers, err = catalog.Load(cfg.ServersFile)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		entry, err := cachedEntry(cfg)
+		if err != nil || entry == nil {
+			return nil, false, err
+		}
+
+		servers = restoreEntry(speedtestClient, entry)
+	}
+
+	var targets speedtest.Servers
+
+	for _, server := range servers {
+		id, errID := strconv.Atoi(server.ID)
+		if errID == nil && slices.Contains(cfg.ServerIDs, id) {
+			targets = append(targets, server)
+		}
+	}
+
+	if len(targets) != len(cfg.ServerIDs) && !cfg.Offline && len(cfg.ServersFile) == 0 {
+		return nil, false, nil
+	}
+
+	if len(targets) > 0 {
+		// only the selected servers are pinged.
+		_, err := speedtestClient.PrepareServers(ctx, targets)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return targets, true, nil
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/cache"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func TestSaveServerList(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{CachedServers: true, CacheFile: filepath.Join(t.TempDir(), "servers.json")}
+	client := speedtest.New()
+
+	target, err := client.CustomServer("http://" + startLocalServer(t))
+	require.NoError(t, err)
+	require.NoError(t, target.PingTest(nil))
+	require.Positive(t, target.Latency)
+
+	require.NoError(t, saveServerList(cfg, client, speedtest.Servers{target}))
+
+	// the latency measured now is not stored with the list.
+	entry, err := cache.New(cfg.CacheFile, 0).Load(cacheKey(cfg))
+	require.NoError(t, err)
+	require.Len(t, entry.Servers, 1)
+	assert.Equal(t, target.ID, entry.Servers[0].ID)
+	assert.Equal(t, target.URL, entry.Servers[0].URL)
+	assert.Zero(t, entry.Servers[0].Latency)
+}
+
+func TestServerList_cached(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{CachedServers: true, CacheFile: filepath.Join(t.TempDir(), "servers.json")}
+	client := speedtest.New()
+
+	target, err := client.CustomServer("http://" + startLocalServer(t))
+	require.NoError(t, err)
+
+	// a stale latency of the cached list must not rank the servers.
+	target.Latency = time.Hour
+
+	require.NoError(t, cache.New(cfg.CacheFile, 0).Save(&cache.Entry{
+		FetchedAt: time.Now(),
+		Key:       cacheKey(cfg),
+		Servers:   speedtest.Servers{target},
+	}))
+
+	servers, err := serverList(context.Background(), client, cfg)
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+	assert.Positive(t, servers[0].Latency)
+	assert.Less(t, servers[0].Latency, time.Hour)
+}
@@ -3,41 +3,108 @@ package app
 import (
 	"io"
 	"log"
+	"time"
 
-	"github.com/nicholas-fedor/speedtest-go/internal/parser"
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	ShowList      bool
-	ServerIDs     []int
-	CustomURL     string
-	SavingMode    bool
-	JSONOutput    bool
-	JSONLOutput   bool
-	UnixOutput    bool
-	Location      string
-	City          string
-	ShowCityList  bool
-	Proxy         string
-	Source        string
-	DNSBindSource bool
-	Multi         bool
-	Thread        int
-	Search        string
-	UserAgent     string
-	NoDownload    bool
-	NoUpload      bool
-	PingMode      string
-	Unit          string
-	Debug         bool
+	ShowList         bool
+	ServerIDs        []int
+	CustomURLs       []string
+	SavingMode       bool
+	JSONOutput       bool
+	JSONLOutput      bool
+	ProgressJSON     bool
+	UnixOutput       bool
+	Location         string
+	City             string
+	ShowCityList     bool
+	Proxy            string
+	Sources          []string
+	ParallelSources  bool
+	DNSBindSource    bool
+	DNSServer        string
+	Multi            bool
+	Thread           int
+	Search           string
+	UserAgent        string
+	NoDownload       bool
+	NoUpload         bool
+	PingMode         string
+	TransferMode     string
+	Unit             string
+	Debug            bool
+	ListenAddr       string
+	HistoryFile      string
+	History          bool
+	HistoryServerIDs []string
+	HistorySince     string
+	HistoryUntil     string
+	HistorySummary   bool
+	MonitorInterval  time.Duration
+	MonitorWindow    time.Duration
+	MonitorAlign     bool
+	Outputs          []string
+	InfluxToken      string
+	MinDownload      string
+	MinUpload        string
+	MaxLatency       time.Duration
+	MaxJitter        time.Duration
+	MaxPacketLoss    string
+	TimeSeries       bool
+	MaxDuration      time.Duration
+	MinDuration      time.Duration
+	WarmUp           time.Duration
+	StabilityCV      float64
+	MaxBytes         string
+	CachedServers    bool
+	Offline          bool
+	Refresh          bool
+	CacheFile        string
+	CacheTTL         time.Duration
+	ServersFile      string
+	Export           string
+	Select           string
+	SelectPings      int
+	SelectTopK       int
+	Countries        []string
+	Sponsors         []string
+	ExcludeIDs       []string
+	MaxDistance      float64
+	ListMaxLatency   time.Duration
+	Sort             string
+	Limit            int
+	CSVOutput        bool
+	IPv4             bool
+	IPv6             bool
+	DualStack        bool
+	HTTPS            string
+	CACert           string
+	ClientCert       string
+	ClientKey        string
+	TLSServerName    string
+	Insecure         bool
+
+	// machineOutput is set when results are written to stdout in a machine
+	// readable format, which disables the human readable output.
+	machineOutput bool
+	// maxDataVolume is the parsed MaxBytes.
+	maxDataVolume int64
+	// selection is the parsed server selection.
+	selection speedtest.Selection
+	// httpsMode is the parsed HTTPS mode.
+	httpsMode speedtest.HTTPSMode
+	// source is the source interface or address of the client, the first one
+	// of Sources.
+	source string
+	// progress writes the progress lines of ProgressJSON.
+	progress *progressObserver
 }
 
 // setupConfig sets up global configuration based on flags.
 func setupConfig(cfg Config) {
-	speedtest.SetUnit(parser.ParseUnit(cfg.Unit))
-
 	// discard standard log.
 	log.SetOutput(io.Discard)
 	log.SetFlags(0)
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/internal/task"
+	"github.com/nicholas-fedor/speedtest-go/internal/threshold"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// Exit codes of the speedtest command.
+const (
+	ExitCodeError       = 1                  // generic failure, e.g. invalid flags
+	ExitCodeTestFailed  = task.FatalExitCode // the test could not run or measure a result
+	ExitCodeThreshold   = 3                  // a threshold was violated
+	ExitCodeInterrupted = 130                // the run was stopped by SIGINT or SIGTERM, like shells report it
+)
+
+var (
+	// ErrTestIncomplete indicates a test that finished without a measurement.
+	ErrTestIncomplete = errors.New("test could not measure a result")
+	// ErrThresholdViolated indicates a result that does not satisfy the thresholds.
+	ErrThresholdViolated = errors.New("threshold violated")
+	// ErrInterrupted indicates a run stopped by a signal before its end.
+	ErrInterrupted = errors.New("speedtest interrupted")
+)
+
+// ExitError is an error with the exit code the process should terminate with.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code for an error returned by the commands.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return ExitCodeError
+}
+
+// parseThresholds parses the threshold flags. The rate thresholds of skipped
+// directions are dropped, since their rates are never measured.
+func parseThresholds(cfg Config) (threshold.Thresholds, error) {
+	thresholds := threshold.Thresholds{
+		MaxLatency: cfg.MaxLatency,
+		MaxJitter:  cfg.MaxJitter,
+		Unit:       parser.ParseUnit(cfg.Unit),
+	}
+
+	var err error
+
+	if len(cfg.MinDownload) > 0 {
+		thresholds.MinDownload, err = parser.ParseRate(cfg.MinDownload)
+		if err != nil {
+			return thresholds, fmt.Errorf("failed to parse --min-download: %w", err)
+		}
+	}
+
+	if len(cfg.MinUpload) > 0 {
+		thresholds.MinUpload, err = parser.ParseRate(cfg.MinUpload)
+		if err != nil {
+			return thresholds, fmt.Errorf("failed to parse --min-upload: %w", err)
+		}
+	}
+
+	if len(cfg.MaxPacketLoss) > 0 {
+		maxPacketLoss, errParse := parser.ParsePercent(cfg.MaxPacketLoss)
+		if errParse != nil {
+			return thresholds, fmt.Errorf("failed to parse --max-packet-loss: %w", errParse)
+		}
+
+		thresholds.MaxPacketLoss = &maxPacketLoss
+	}
+
+	if cfg.NoDownload {
+		thresholds.MinDownload = 0
+	}
+
+	if cfg.NoUpload {
+		thresholds.MinUpload = 0
+	}
+
+	return thresholds, nil
+}
+
+// checkResults reports failed tests, tests that could not measure a result and
+// threshold violations on stderr and returns the matching exit error. The
+// results of failed servers are not checked against the thresholds.
+func checkResults(cfg Config, thresholds threshold.Thresholds, targets speedtest.Servers) error {
+	incomplete, violated := false, false
+
+	for _, server := range targets {
+		if len(server.Error) > 0 {
+			incomplete = true
+
+			_, _ = fmt.Fprintf(os.Stderr, "Failed: %s: %s\n", serverLabel(server), server.Error)
+
+			continue
+		}
+
+		if (!cfg.NoDownload && server.DLSpeed < 0) || (!cfg.NoUpload && server.ULSpeed < 0) {
+			incomplete = true
+
+			_, _ = fmt.Fprintf(os.Stderr, "Incomplete: %s: download %s, upload %s\n",
+				server.String(), server.Context.FormatRate(server.DLSpeed), server.Context.FormatRate(server.ULSpeed))
+		}
+
+		for _, violation := range thresholds.Check(server) {
+			violated = true
+
+			_, _ = fmt.Fprintf(os.Stderr, "Threshold violated: %s: %s\n", server.String(), violation)
+		}
+	}
+
+	switch {
+	case incomplete:
+		return &ExitError{Code: ExitCodeTestFailed, Err: ErrTestIncomplete}
+	case violated:
+		return &ExitError{Code: ExitCodeThreshold, Err: ErrThresholdViolated}
+	default:
+		return nil
+	}
+}
+
+// serverLabel names the server with the address family and the source it was
+// tested over, like the titles of the tests.
+func serverLabel(server *speedtest.Server) string {
+	label := server.String()
+	if server.IPFamily != speedtest.IPFamilyAny {
+		label += " over " + server.IPFamily.String()
+	}
+
+	if len(server.Source) > 0 {
+		label += " via " + server.Source
+	}
+
+	return label
+}
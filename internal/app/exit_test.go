@@ -0,0 +1,36 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func TestParseThresholds_skippedDirections(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{MinDownload: "500Mbps", MinUpload: "50Mbps", NoUpload: true}
+
+	thresholds, err := parseThresholds(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, speedtest.ByteRate(62500000), thresholds.MinDownload)
+	assert.Zero(t, thresholds.MinUpload)
+
+	// an upload that was never measured does not violate its threshold.
+	server := &speedtest.Server{DLSpeed: 62500000}
+	assert.Empty(t, thresholds.Check(server))
+
+	cfg.NoDownload, cfg.NoUpload = true, false
+
+	thresholds, err = parseThresholds(cfg)
+	require.NoError(t, err)
+	assert.Zero(t, thresholds.MinDownload)
+	assert.Equal(t, speedtest.ByteRate(6250000), thresholds.MinUpload)
+
+	// the flags are still validated.
+	_, err = parseThresholds(Config{MinUpload: "fast", NoUpload: true})
+	require.Error(t, err)
+}
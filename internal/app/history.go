@@ -0,0 +1,128 @@
+package app
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
+	"github.com/nicholas-fedor/speedtest-go/internal/output"
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// RunHistory lists or summarizes the recorded speedtest results.
+func RunHistory(cfg Config) error {
+	setupConfig(cfg)
+
+	store, err := openHistory(cfg)
+	if err != nil {
+		return err
+	}
+
+	filter, err := historyFilter(cfg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	records, err := store.Load(filter)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	switch {
+	case cfg.HistorySummary && cfg.JSONOutput:
+		data, errMarshal := json.Marshal(history.Summarize(records))
+		if errMarshal != nil {
+			return fmt.Errorf("failed to marshal history summary: %w", errMarshal)
+		}
+
+		_, _ = fmt.Fprintln(os.Stdout, string(data))
+	case cfg.HistorySummary:
+		output.ShowHistorySummary(history.Summarize(records), parser.ParseUnit(cfg.Unit))
+	case cfg.JSONOutput:
+		for _, record := range records {
+			data, errMarshal := json.Marshal(record)
+			if errMarshal != nil {
+				return fmt.Errorf("failed to marshal history record: %w", errMarshal)
+			}
+
+			_, _ = fmt.Fprintln(os.Stdout, string(data))
+		}
+	default:
+		output.ShowHistory(records, parser.ParseUnit(cfg.Unit))
+	}
+
+	return nil
+}
+
+// recordHistory appends the results of the tested servers to the history store
+// if --history is set. Failures are reported on stderr so that they never discard a finished test.
+// The user of the client of a server is recorded, which differs per source,
+// and otherwise the one of the default client. Failed servers are skipped.
+func recordHistory(cfg Config, speedtestClient *speedtest.Speedtest, targets speedtest.Servers) {
+	if !cfg.History {
+		return
+	}
+
+	store, err := openHistory(cfg)
+	if err == nil {
+		now := time.Now()
+		records := make([]history.Record, 0, len(targets))
+
+		for _, server := range targets {
+			// a failed test has no result to compare with later runs.
+			if len(server.Error) > 0 {
+				continue
+			}
+
+			records = append(records, history.NewRecord(cmp.Or(server.Context.User, speedtestClient.User), server, now))
+		}
+
+		err = store.Append(records...)
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", err)
+	}
+}
+
+// openHistory opens the configured history store or the default one.
+func openHistory(cfg Config) (*history.Store, error) {
+	path := cfg.HistoryFile
+	if len(path) == 0 {
+		var err error
+
+		path, err = history.DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve history file: %w", err)
+		}
+	}
+
+	return history.New(path), nil
+}
+
+// historyFilter builds the history filter from the configured flags.
+func historyFilter(cfg Config, now time.Time) (history.Filter, error) {
+	filter := history.Filter{ServerIDs: cfg.HistoryServerIDs}
+
+	var err error
+
+	if len(cfg.HistorySince) > 0 {
+		filter.Since, err = parser.ParseTime(cfg.HistorySince, now)
+		if err != nil {
+			return filter, fmt.Errorf("failed to parse --since: %w", err)
+		}
+	}
+
+	if len(cfg.HistoryUntil) > 0 {
+		filter.Until, err = parser.ParseTime(cfg.HistoryUntil, now)
+		if err != nil {
+			return filter, fmt.Errorf("failed to parse --until: %w", err)
+		}
+	}
+
+	return filter, nil
+}
@@ -1,42 +1,119 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/nicholas-fedor/speedtest-go/internal/catalog"
 	"github.com/nicholas-fedor/speedtest-go/internal/output"
 	"github.com/nicholas-fedor/speedtest-go/internal/parser"
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
+// ErrInvalidListFilter indicates a server list filter out of range.
+var ErrInvalidListFilter = errors.New("invalid server list filter")
+
 // RunList lists available speedtest servers.
 func RunList(cfg Config) error {
 	setupConfig(cfg)
 
+	selection, sortKey, err := listFilter(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.httpsMode, err = connectionOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.source, err = sourceOptions(cfg, false)
+	if err != nil {
+		return err
+	}
+
 	// 0. speed test setting
-	speedtestClient := speedtest.New(speedtest.WithUserConfig(
-		&speedtest.UserConfig{
-			UserAgent:      cfg.UserAgent,
-			Proxy:          cfg.Proxy,
-			Source:         cfg.Source,
-			DNSBindSource:  cfg.DNSBindSource,
-			Debug:          cfg.Debug,
-			PingMode:       parser.ParseProto(cfg.PingMode),
-			SavingMode:     cfg.SavingMode,
-			MaxConnections: cfg.Thread,
-			CityFlag:       cfg.City,
-			LocationFlag:   cfg.Location,
-			Keyword:        cfg.Search,
-		}))
+	speedtestClient := setupSpeedtestClient(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the cache keeps the user, so a refreshed list remains sortable by distance
+	// offline, and the distances to catalogue servers are computed from it.
+	if cfg.Refresh || len(cfg.ServersFile) > 0 {
+		_, err = retrieveUser(ctx, speedtestClient, cfg)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
 
 	// retrieving servers
-	servers, err := speedtestClient.FetchServers()
+	servers, err := serverList(ctx, speedtestClient, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to fetch servers: %w", err)
+		return err
 	}
 
 	log.Printf("Found %d Public Servers\n", len(servers))
-	output.ShowServerList(servers)
 
-	return nil
+	servers = servers.Filter(selection)
+
+	err = servers.Sort(sortKey)
+	if err != nil {
+		return fmt.Errorf("failed to sort servers: %w", err)
+	}
+
+	if cfg.Limit > 0 && len(servers) > cfg.Limit {
+		servers = servers[:cfg.Limit]
+	}
+
+	if len(cfg.Export) > 0 {
+		err = catalog.Save(cfg.Export, servers)
+		if err != nil {
+			return fmt.Errorf("failed to export servers: %w", err)
+		}
+
+		_, _ = fmt.Fprintf(os.Stdout, "Exported %d servers to %s\n", len(servers), cfg.Export)
+
+		return nil
+	}
+
+	switch {
+	case cfg.JSONOutput:
+		err = output.WriteServerListJSON(os.Stdout, servers)
+	case cfg.CSVOutput:
+		err = output.WriteServerListCSV(os.Stdout, servers)
+	default:
+		output.ShowServerList(servers)
+	}
+
+	return err
+}
+
+// listFilter builds the filters and the sort key of the server list from the
+// configured flags.
+func listFilter(cfg Config) (speedtest.Selection, speedtest.SortKey, error) {
+	sortKey, err := parser.ParseSortKey(cfg.Sort)
+	if err != nil {
+		return speedtest.Selection{}, "", fmt.Errorf("failed to parse --sort: %w", err)
+	}
+
+	if cfg.MaxDistance < 0 || cfg.ListMaxLatency < 0 || cfg.Limit < 0 {
+		return speedtest.Selection{}, "", fmt.Errorf(
+			"%w: --max-distance, --max-latency and --limit must not be negative",
+			ErrInvalidListFilter,
+		)
+	}
+
+	return speedtest.Selection{
+		Countries:   cfg.Countries,
+		Sponsors:    cfg.Sponsors,
+		ExcludeIDs:  cfg.ExcludeIDs,
+		MaxDistance: cfg.MaxDistance,
+		MaxLatency:  cfg.ListMaxLatency,
+	}, sortKey, nil
 }
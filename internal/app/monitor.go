@@ -0,0 +1,344 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/exporter"
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+const (
+	// DefaultMonitorAddr is the default listen address of the metrics endpoint.
+	DefaultMonitorAddr = ":9798"
+	// DefaultMonitorInterval is the default period between two monitoring runs.
+	DefaultMonitorInterval = time.Hour
+
+	metricsReadHeaderTimeout = 10 * time.Second
+	metricsShutdownTimeout   = 5 * time.Second
+)
+
+// ErrNoServers indicates that no server could be selected for a monitoring run.
+var ErrNoServers = errors.New("no servers available")
+
+// RunMonitor runs the speedtest periodically and serves the results as
+// Prometheus metrics until it is interrupted.
+func RunMonitor(cfg Config) error {
+	setupConfig(cfg)
+
+	var err error
+
+	cfg.selection, err = serverSelection(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.httpsMode, err = connectionOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.source, err = sourceOptions(cfg, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	interval := cfg.MonitorInterval
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	var store *history.Store
+
+	if cfg.History {
+		store, err = openHistory(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	exp := exporter.New(store, cfg.MonitorWindow)
+	speedtestClient := setupSpeedtestClient(cfg)
+
+	listenConfig := &net.ListenConfig{}
+
+	listener, err := listenConfig.Listen(ctx, "tcp", cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.ListenAddr, err)
+	}
+
+	httpServer := &http.Server{
+		Handler:           exp.Handler(),
+		ReadHeaderTimeout: metricsReadHeaderTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- httpServer.Serve(listener)
+	}()
+
+	_, _ = fmt.Fprintf(
+		os.Stdout,
+		"Serving metrics on http://%s/metrics, testing every %v, press Ctrl-C to stop\n",
+		listener.Addr(),
+		interval,
+	)
+
+	// the runs start every interval from now, or from midnight with --align,
+	// which waits for the first slot like cron.
+	first := time.Now()
+	run := !cfg.MonitorAlign
+
+	if cfg.MonitorAlign {
+		first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, first.Location())
+	}
+
+	for {
+		if run {
+			monitorOnce(ctx, speedtestClient, cfg, exp, store)
+		}
+
+		run = true
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+			defer cancel()
+
+			_ = httpServer.Shutdown(shutdownCtx)
+
+			return nil
+		case err = <-serveErr:
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		case <-time.After(time.Until(nextMonitorRun(first, time.Now(), interval))):
+		}
+	}
+}
+
+// nextMonitorRun returns the start of the first run after now, the runs
+// starting every interval from first. Runs missed by a longer run are skipped.
+func nextMonitorRun(first, now time.Time, interval time.Duration) time.Time {
+	return first.Add((now.Sub(first)/interval + 1) * interval)
+}
+
+// monitorOnce tests the selected servers and records the results.
+// Failures are reported on stderr and counted, the next run is attempted anyway.
+func monitorOnce(
+	ctx context.Context,
+	speedtestClient *speedtest.Speedtest,
+	cfg Config,
+	exp *exporter.Exporter,
+	store *history.Store,
+) {
+	err := monitorServers(ctx, speedtestClient, cfg, exp, store)
+	if err != nil && ctx.Err() == nil {
+		exp.ObserveFailure(time.Now())
+		reportMonitorFailure(err)
+	}
+}
+
+// reportMonitorFailure prints a failure on stderr.
+func reportMonitorFailure(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s speedtest failed: %v\n", time.Now().Format(time.DateTime), err)
+}
+
+func monitorServers(
+	ctx context.Context,
+	speedtestClient *speedtest.Speedtest,
+	cfg Config,
+	exp *exporter.Exporter,
+	store *history.Store,
+) error {
+	// the ISP is only used as a label, so custom servers remain testable without it.
+	user, err := retrieveUser(ctx, speedtestClient, cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	targets, err := selectServers(ctx, speedtestClient, cfg)
+	if err != nil {
+		return err
+	}
+
+	// a failed server is counted and the others are still tested.
+	for _, server := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result, errMeasure := measureServer(ctx, speedtestClient, server, cfg)
+		if errMeasure != nil {
+			if ctx.Err() == nil {
+				exp.ObserveServerFailure(user, server, time.Now())
+				reportMonitorFailure(fmt.Errorf("failed to test server %s: %w", server.ID, errMeasure))
+			}
+
+			continue
+		}
+
+		result.User = user
+		exp.Observe(result)
+
+		_, _ = fmt.Fprintf(
+			os.Stdout,
+			"%s %s Download: %s Upload: %s Latency: %v Jitter: %v %s\n",
+			result.Timestamp.Format(time.DateTime),
+			server.String(),
+			speedtestClient.FormatRate(server.DLSpeed),
+			speedtestClient.FormatRate(server.ULSpeed),
+			server.Latency,
+			server.Jitter,
+			server.PacketLoss.String(),
+		)
+
+		if store != nil {
+			errAppend := store.Append(history.NewRecord(user, server, result.Timestamp))
+			if errAppend != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", errAppend)
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectServers returns the servers to test without terminating the process on
+// failures like retrieveServers does.
+func selectServers(
+	ctx context.Context,
+	speedtestClient *speedtest.Speedtest,
+	cfg Config,
+) (speedtest.Servers, error) {
+	switch {
+	case len(cfg.CustomURLs) > 0:
+		return customServers(speedtestClient, cfg.CustomURLs)
+	case len(cfg.ServerIDs) > 0:
+		targets, cached, err := localServersByID(ctx, speedtestClient, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if cached {
+			if len(targets) == 0 {
+				return nil, ErrNoServers
+			}
+
+			return targets, nil
+		}
+
+		for _, id := range cfg.ServerIDs {
+			target, err := speedtestClient.FetchServerByIDContext(ctx, strconv.Itoa(id))
+			if err != nil {
+				continue // skip servers that are gone, like retrieveServers does.
+			}
+
+			targets = append(targets, target)
+		}
+
+		if len(targets) == 0 {
+			return nil, ErrNoServers
+		}
+
+		return targets, nil
+	default:
+		servers, err := serverList(ctx, speedtestClient, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		target, err := speedtestClient.SelectServer(ctx, servers, cfg.selection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select server: %w", err)
+		}
+
+		return speedtest.Servers{target}, nil
+	}
+}
+
+// measureServer runs the ping, download, upload and packet loss tests against a server.
+func measureServer(
+	ctx context.Context,
+	speedtestClient *speedtest.Speedtest,
+	server *speedtest.Server,
+	cfg Config,
+) (exporter.Result, error) {
+	defer speedtestClient.Reset()
+
+	// the results of the previous run must not be reported again.
+	server.ResetResults()
+
+	err := server.PingTestContext(ctx, nil)
+	if err != nil {
+		return exporter.Result{}, fmt.Errorf("failed to ping: %w", err)
+	}
+
+	analyzer := server.Context.NewPacketLossAnalyzer(nil)
+
+	lossCtx, lossCancel := context.WithTimeout(ctx, packetLossAnalyzerTimeout)
+	defer lossCancel()
+
+	var (
+		blocker    sync.WaitGroup
+		packetLoss transport.PLoss
+	)
+
+	blocker.Go(func() {
+		_ = analyzer.RunWithContext(lossCtx, server.Host, func(loss *transport.PLoss) {
+			packetLoss = *loss
+		})
+	})
+
+	err = runMonitorTransfers(ctx, server, cfg)
+
+	// without any transfer, the packet loss is analyzed for its own timeout.
+	if !cfg.NoDownload || !cfg.NoUpload {
+		lossCancel()
+	}
+
+	blocker.Wait()
+
+	if err != nil {
+		return exporter.Result{}, err
+	}
+
+	server.PacketLoss = packetLoss
+
+	return exporter.Result{
+		Result:        *speedtestClient.NewResult(server),
+		DownloadBytes: speedtestClient.GetTotalDownload(),
+		UploadBytes:   speedtestClient.GetTotalUpload(),
+	}, nil
+}
+
+func runMonitorTransfers(ctx context.Context, server *speedtest.Server, cfg Config) error {
+	if !cfg.NoDownload {
+		err := server.DownloadTestContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to test download: %w", err)
+		}
+	}
+
+	if !cfg.NoUpload {
+		err := server.UploadTestContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to test upload: %w", err)
+		}
+	}
+
+	return nil
+}
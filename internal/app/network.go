@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+var (
+	// ErrInvalidSources indicates source options that cannot be combined.
+	ErrInvalidSources = errors.New("invalid source options")
+	// ErrProxyFamily indicates an address family forced together with a proxy,
+	// which connects to the servers over a family of its own choosing.
+	ErrProxyFamily = errors.New("--ipv4, --ipv6 and --dual-stack cannot be combined with --proxy")
+)
+
+// connectionOptions parses the HTTPS mode of the configured flags and checks
+// the source, proxy and TLS options, which the client would only warn about in
+// debug mode.
+func connectionOptions(cfg Config) (speedtest.HTTPSMode, error) {
+	mode, err := parser.ParseHTTPSMode(cfg.HTTPS)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse --https: %w", err)
+	}
+
+	if len(cfg.Proxy) > 0 && (cfg.IPv4 || cfg.IPv6 || cfg.DualStack) {
+		return "", fmt.Errorf("invalid connection options: %w", ErrProxyFamily)
+	}
+
+	// a client without any source is checked for the other options.
+	sources := cfg.Sources
+	if len(sources) == 0 {
+		sources = []string{""}
+	}
+
+	for _, source := range sources {
+		err = (&speedtest.UserConfig{
+			Source:      source,
+			Proxy:       cfg.Proxy,
+			TLSCAFile:   cfg.CACert,
+			TLSCertFile: cfg.ClientCert,
+			TLSKeyFile:  cfg.ClientKey,
+		}).Validate()
+		if err != nil {
+			return "", fmt.Errorf("invalid connection options: %w", err)
+		}
+	}
+
+	return mode, nil
+}
+
+// sourceOptions checks the repeated --source flags and returns the source of
+// the client, the first one. Several sources are only tested by the speedtest
+// command, each by a client of its own, if several is set.
+func sourceOptions(cfg Config, several bool) (string, error) {
+	// the servers of the multi-server test are bound to the client that
+	// fetched them, not to the clients of the address families.
+	if cfg.DualStack && cfg.Multi {
+		return "", fmt.Errorf("%w: --dual-stack cannot be combined with --multi", ErrInvalidSources)
+	}
+
+	if len(cfg.Sources) == 0 {
+		if cfg.ParallelSources {
+			return "", fmt.Errorf("%w: --parallel-sources requires several --source", ErrInvalidSources)
+		}
+
+		return "", nil
+	}
+
+	for i, source := range cfg.Sources {
+		if len(source) == 0 {
+			return "", fmt.Errorf("%w: empty --source", ErrInvalidSources)
+		}
+
+		if slices.Contains(cfg.Sources[:i], source) {
+			return "", fmt.Errorf("%w: duplicate --source %s", ErrInvalidSources, source)
+		}
+	}
+
+	switch {
+	case len(cfg.Sources) == 1 && cfg.ParallelSources:
+		return "", fmt.Errorf("%w: --parallel-sources requires several --source", ErrInvalidSources)
+	case len(cfg.Sources) == 1:
+		return cfg.Sources[0], nil
+	case !several:
+		return "", fmt.Errorf("%w: --source cannot be repeated for this command", ErrInvalidSources)
+	case cfg.Multi:
+		return "", fmt.Errorf("%w: --source cannot be repeated with --multi", ErrInvalidSources)
+	case cfg.DualStack:
+		return "", fmt.Errorf("%w: --source cannot be repeated with --dual-stack", ErrInvalidSources)
+	default:
+		return cfg.Sources[0], nil
+	}
+}
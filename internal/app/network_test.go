@@ -0,0 +1,69 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		several bool
+		want    string
+		wantErr bool
+	}{
+		{name: "no source", cfg: Config{}, want: ""},
+		{name: "single source", cfg: Config{Sources: []string{"10.0.0.2"}}, want: "10.0.0.2"},
+		{
+			name:    "several sources",
+			cfg:     Config{Sources: []string{"10.0.0.2", "10.0.1.2"}},
+			several: true,
+			want:    "10.0.0.2",
+		},
+		{name: "several sources not allowed", cfg: Config{Sources: []string{"10.0.0.2", "10.0.1.2"}}, wantErr: true},
+		{name: "dual-stack with multi", cfg: Config{DualStack: true, Multi: true}, several: true, wantErr: true},
+		{name: "dual-stack", cfg: Config{DualStack: true}, several: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			source, err := sourceOptions(tt.cfg, tt.several)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSources)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, source)
+		})
+	}
+}
+
+func TestConnectionOptions_proxyFamily(t *testing.T) {
+	t.Parallel()
+
+	proxy := "http://127.0.0.1:3128"
+
+	for _, cfg := range []Config{
+		{Proxy: proxy, IPv4: true},
+		{Proxy: proxy, IPv6: true},
+		{Proxy: proxy, DualStack: true},
+	} {
+		_, err := connectionOptions(cfg)
+		require.ErrorIs(t, err, ErrProxyFamily)
+	}
+
+	_, err := connectionOptions(Config{Proxy: proxy})
+	require.NoError(t, err)
+
+	_, err = connectionOptions(Config{IPv6: true})
+	require.NoError(t, err)
+}
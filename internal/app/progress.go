@@ -0,0 +1,87 @@
+package app
+
+import (
+	"cmp"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/output"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// progressObserver writes the events of the tests as progress lines, with the
+// latest latency under load of the phase for rate samples. The results are
+// left to the sinks.
+type progressObserver struct {
+	writer *output.ProgressWriter
+
+	mu        sync.Mutex
+	latencies map[*speedtest.Server]time.Duration
+	err       error
+}
+
+// newProgressObserver creates an observer writing progress lines to w.
+func newProgressObserver(w io.Writer) *progressObserver {
+	return &progressObserver{
+		writer:    output.NewProgressWriter(w),
+		latencies: make(map[*speedtest.Server]time.Duration),
+	}
+}
+
+// progressOutput reports whether the progress is written to stdout, which the
+// json outputs suppress until their results at the end.
+func progressOutput(cfg Config) bool {
+	return cfg.ProgressJSON && !cfg.JSONOutput && !cfg.JSONLOutput
+}
+
+// OnEvent writes every event but the results. Nothing is written after a
+// failed write.
+func (p *progressObserver) OnEvent(event speedtest.Event) {
+	if event.Type == speedtest.EventResult {
+		return
+	}
+
+	p.mu.Lock()
+	latency := p.loadedLatency(event)
+	failed := p.err != nil
+	p.mu.Unlock()
+
+	if failed {
+		return
+	}
+
+	err := p.writer.WriteEvent(event, latency)
+	if err != nil {
+		p.mu.Lock()
+		p.err = cmp.Or(p.err, err)
+		p.mu.Unlock()
+	}
+}
+
+// loadedLatency tracks the ping samples of the transfer phases and returns the
+// latest latency under load of the server of the event. It must be called with
+// the lock held.
+func (p *progressObserver) loadedLatency(event speedtest.Event) time.Duration {
+	if event.Phase != speedtest.PhaseDownload && event.Phase != speedtest.PhaseUpload {
+		return 0
+	}
+
+	switch event.Type {
+	case speedtest.EventPhaseStarted:
+		delete(p.latencies, event.Server)
+	case speedtest.EventPingSample:
+		p.latencies[event.Server] = event.Latency
+	default:
+	}
+
+	return p.latencies[event.Server]
+}
+
+// Err returns the error of the first failed write.
+func (p *progressObserver) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// serverSelection builds the server selection from the configured flags.
+func serverSelection(cfg Config) (speedtest.Selection, error) {
+	strategy, err := parser.ParseStrategy(cfg.Select)
+	if err != nil {
+		return speedtest.Selection{}, fmt.Errorf("failed to parse --select: %w", err)
+	}
+
+	if cfg.SelectPings < 0 || cfg.SelectTopK < 0 {
+		return speedtest.Selection{}, fmt.Errorf(
+			"%w: --select-pings and --select-top must not be negative",
+			parser.ErrInvalidStrategy,
+		)
+	}
+
+	return speedtest.Selection{
+		Strategy:   strategy,
+		Pings:      cfg.SelectPings,
+		TopK:       cfg.SelectTopK,
+		Countries:  cfg.Countries,
+		Sponsors:   cfg.Sponsors,
+		ExcludeIDs: cfg.ExcludeIDs,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
+)
+
+// RunServe runs a local speedtest server until it is interrupted.
+func RunServe(cfg Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(&server.Options{Addr: cfg.ListenAddr})
+
+	_, _ = fmt.Fprintf(
+		os.Stdout,
+		"Serving speedtest on %s (HTTP, TCP and UDP), press Ctrl-C to stop\n",
+		cfg.ListenAddr,
+	)
+
+	err := srv.ListenAndServe(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to serve speedtest: %w", err)
+	}
+
+	return nil
+}
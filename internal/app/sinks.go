@@ -0,0 +1,212 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const (
+	outputFilePerm = 0o644
+	postTimeout    = 30 * time.Second
+	// postGracePeriod is how long the results of an interrupted run may still
+	// be posted.
+	postGracePeriod = 5 * time.Second
+)
+
+// errPostStatus is returned when an output endpoint responds with a non-2xx status.
+var errPostStatus = errors.New("unexpected response status")
+
+// postContext returns the context of the results posted to URLs. It is done a
+// grace period after ctx, so that the results of an interrupted run are still
+// posted, but a hanging endpoint cannot block the exit.
+func postContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	postCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(postGracePeriod, cancel)
+	})
+
+	return postCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// openSinks creates the result sinks selected by --json, --jsonl,
+// --progress-json, whose results are a json document after the progress, and
+// --output. It also reports whether any of them writes to stdout. The results
+// posted to URLs are canceled once ctx is done.
+func openSinks(ctx context.Context, cfg Config) (speedtest.MultiSink, bool, error) {
+	var (
+		sinks  speedtest.MultiSink
+		stdout bool
+	)
+
+	if cfg.JSONOutput || progressOutput(cfg) {
+		sinks = append(sinks, speedtest.NewJSONSink(os.Stdout))
+		stdout = true
+	} else if cfg.JSONLOutput {
+		sinks = append(sinks, speedtest.NewJSONLSink(os.Stdout))
+		stdout = true
+	}
+
+	for _, spec := range cfg.Outputs {
+		outputType, target, err := parser.ParseOutput(spec)
+		if err != nil {
+			_ = sinks.Close()
+
+			return nil, false, fmt.Errorf("failed to parse --output: %w", err)
+		}
+
+		sink, err := openSink(ctx, outputType, target, cfg.InfluxToken)
+		if err != nil {
+			_ = sinks.Close()
+
+			return nil, false, err
+		}
+
+		sinks = append(sinks, sink)
+		stdout = stdout || (len(target) == 0 && outputType != parser.OutputWebhook)
+	}
+
+	return sinks, stdout, nil
+}
+
+// openSink creates a sink of the given type writing to stdout, a file or a URL.
+// The influx token is only sent to influx URLs.
+func openSink(ctx context.Context, outputType, target, influxToken string) (speedtest.ResultSink, error) {
+	if outputType == parser.OutputWebhook {
+		return speedtest.NewWebhookSinkContext(ctx, target, nil), nil
+	}
+
+	if parser.IsHTTPURL(target) {
+		if outputType != parser.OutputInflux {
+			return nil, fmt.Errorf("%w: only influx and webhook outputs accept urls", parser.ErrInvalidOutput)
+		}
+
+		return speedtest.NewInfluxSink(newPostWriter(ctx, target, "text/plain; charset=utf-8", influxToken), ""), nil
+	}
+
+	writer := io.Writer(os.Stdout)
+	header := true
+
+	var file *os.File
+
+	if len(target) > 0 {
+		var err error
+
+		// a json document is rewritten, as a second one appended would make the
+		// file invalid; the other formats are appended to.
+		flag := os.O_APPEND
+		if outputType == parser.OutputJSON {
+			flag = os.O_TRUNC
+		}
+
+		file, err = os.OpenFile(target, flag|os.O_CREATE|os.O_WRONLY, outputFilePerm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open output file: %w", err)
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+
+			return nil, fmt.Errorf("failed to stat output file: %w", err)
+		}
+
+		// appending to an existing csv file must not repeat the header.
+		writer, header = file, info.Size() == 0
+	}
+
+	var sink speedtest.ResultSink
+
+	switch outputType {
+	case parser.OutputJSON:
+		sink = speedtest.NewJSONSink(writer)
+	case parser.OutputJSONL:
+		sink = speedtest.NewJSONLSink(writer)
+	case parser.OutputCSV:
+		sink = speedtest.NewCSVSink(writer, header)
+	default:
+		sink = speedtest.NewInfluxSink(writer, "")
+	}
+
+	if file == nil {
+		return sink, nil
+	}
+
+	return &fileSink{ResultSink: sink, file: file}, nil
+}
+
+// fileSink is a result sink that owns the file it writes to.
+type fileSink struct {
+	speedtest.ResultSink
+
+	file *os.File
+}
+
+// Close closes the sink and the file.
+func (f *fileSink) Close() error {
+	return errors.Join(f.ResultSink.Close(), f.file.Close())
+}
+
+// postWriter posts every write as the body of a request, e.g. to the InfluxDB
+// write API. A non-empty token is sent as the API token.
+type postWriter struct {
+	ctx         context.Context //nolint:containedctx // writes have no context of their own
+	url         string
+	contentType string
+	token       string
+	client      *http.Client
+}
+
+func newPostWriter(ctx context.Context, url, contentType, token string) *postWriter {
+	return &postWriter{
+		ctx:         ctx,
+		url:         url,
+		contentType: contentType,
+		token:       token,
+		client:      &http.Client{Timeout: postTimeout},
+	}
+}
+
+func (p *postWriter) Write(data []byte) (int, error) {
+	req, err := http.NewRequestWithContext(
+		p.ctx,
+		http.MethodPost,
+		p.url,
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", p.contentType)
+
+	if len(p.token) > 0 {
+		req.Header.Set("Authorization", "Token "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post output: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, fmt.Errorf("%w: %s", errPostStatus, resp.Status)
+	}
+
+	return len(data), nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/parser"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func TestOpenSink_existingFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		outputType string
+		wantLines  int
+	}{
+		{name: "json is overwritten", outputType: parser.OutputJSON, wantLines: 1},
+		{name: "jsonl is appended to", outputType: parser.OutputJSONL, wantLines: 2},
+		{name: "csv is appended to without a header", outputType: parser.OutputCSV, wantLines: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			target := filepath.Join(t.TempDir(), "results")
+
+			for range 2 {
+				sink, err := openSink(context.Background(), tt.outputType, target, "")
+				require.NoError(t, err)
+				require.NoError(t, sink.WriteResult(&speedtest.Result{Server: &speedtest.Server{ID: "1"}}))
+				require.NoError(t, sink.Close())
+			}
+
+			data, err := os.ReadFile(target)
+			require.NoError(t, err)
+			assert.Len(t, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), tt.wantLines)
+		})
+	}
+}
+
+func TestPostContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	postCtx, cancelPosts := postContext(ctx)
+
+	// the results of an interrupted run are still posted for a grace period.
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, postCtx.Err())
+
+	cancelPosts()
+	require.ErrorIs(t, postCtx.Err(), context.Canceled)
+}
+
+func TestOpenSink_interruptedPost(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, outputType := range []string{parser.OutputInflux, parser.OutputWebhook} {
+		sink, err := openSink(ctx, outputType, server.URL, "")
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+
+		go func() { done <- sink.WriteResult(&speedtest.Result{Server: &speedtest.Server{ID: "1"}}) }()
+
+		// a hanging endpoint does not block once the context is done.
+		time.AfterFunc(10*time.Millisecond, cancel)
+		require.ErrorIs(t, <-done, context.Canceled)
+	}
+}
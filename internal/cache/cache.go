@@ -0,0 +1,149 @@
+// Package cache provides an on-disk cache of the speedtest.net server list.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const (
+	// DefaultTTL is the default period a cached server list is fresh for.
+	DefaultTTL = 24 * time.Hour
+
+	appDirName = "speedtest-go"
+	fileName   = "servers.json"
+	dirPerm    = 0o755
+	filePerm   = 0o600
+)
+
+var (
+	// ErrMiss indicates that no usable server list is cached.
+	ErrMiss = errors.New("no cached server list")
+	// ErrExpired indicates that the cached server list is older than the TTL.
+	ErrExpired = errors.New("cached server list expired")
+)
+
+// Entry is a server list together with the user it was fetched for.
+type Entry struct {
+	FetchedAt time.Time         `json:"fetchedAt"`
+	Key       string            `json:"key"` // identifies the query the list was fetched with
+	User      *speedtest.User   `json:"user,omitempty"`
+	Servers   speedtest.Servers `json:"servers"`
+}
+
+// Cache is a single cached server list stored as a JSON file.
+type Cache struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// DefaultPath returns the cache file location under the user cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, appDirName, fileName), nil
+}
+
+// New creates a cache backed by the file at path. A ttl <= 0 defaults to DefaultTTL.
+func New(path string, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Cache{path: path, ttl: ttl, now: time.Now}
+}
+
+// Path returns the location of the backing file.
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Load returns the cached entry fetched with the given key. It returns ErrMiss
+// if there is none, and the entry together with ErrExpired if it is older than
+// the TTL, so that it can still be used without network access.
+func (c *Cache) Load(key string) (*Entry, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrMiss
+		}
+
+		return nil, fmt.Errorf("failed to read server cache: %w", err)
+	}
+
+	var entry Entry
+
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		// a damaged cache is rebuilt like a missing one.
+		return nil, fmt.Errorf("%w: failed to decode %s: %w", ErrMiss, c.path, err)
+	}
+
+	if entry.Key != key || len(entry.Servers) == 0 {
+		return nil, ErrMiss
+	}
+
+	if c.now().Sub(entry.FetchedAt) > c.ttl {
+		return &entry, ErrExpired
+	}
+
+	return &entry, nil
+}
+
+// Save replaces the cached entry. The file is replaced atomically, so a
+// concurrent Load never sees a partial entry.
+func (c *Cache) Save(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode server cache: %w", err)
+	}
+
+	dir := filepath.Dir(c.path)
+
+	err = os.MkdirAll(dir, dirPerm)
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, fileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create server cache: %w", err)
+	}
+
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	_, err = file.Write(data)
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write server cache: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close server cache: %w", err)
+	}
+
+	err = os.Chmod(file.Name(), filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to set server cache permissions: %w", err)
+	}
+
+	err = os.Rename(file.Name(), c.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace server cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func testEntry(fetchedAt time.Time) *Entry {
+	return &Entry{
+		FetchedAt: fetchedAt,
+		Key:       "search=tokyo",
+		User:      &speedtest.User{IP: "127.0.0.1", Lat: "35.6", Lon: "139.7", Isp: "ISP"},
+		Servers: speedtest.Servers{
+			{ID: "1", Name: "Tokyo", Host: "example.com:8080", Distance: 12.5, Latency: 10 * time.Millisecond},
+			{ID: "2", Name: "Osaka", Host: "example.org:8080", Distance: 400, Latency: 20 * time.Millisecond},
+		},
+	}
+}
+
+func TestCache_SaveLoad(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "nested", fileName)
+
+	cache := New(path, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	entry := testEntry(now.Add(-30 * time.Minute))
+	require.NoError(t, cache.Save(entry))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(filePerm), info.Mode().Perm())
+
+	loaded, err := cache.Load(entry.Key)
+	require.NoError(t, err)
+	assert.True(t, entry.FetchedAt.Equal(loaded.FetchedAt))
+	assert.Equal(t, entry.User, loaded.User)
+	assert.Equal(t, entry.Servers, loaded.Servers)
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), fileName+".*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "temporary files must be removed")
+}
+
+func TestCache_Load(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		entry   *Entry
+		key     string
+		wantErr error
+		want    bool
+	}{
+		{name: "fresh", entry: testEntry(now.Add(-time.Hour)), key: "search=tokyo", want: true},
+		{name: "expired", entry: testEntry(now.Add(-25 * time.Hour)), key: "search=tokyo", wantErr: ErrExpired, want: true},
+		{name: "other query", entry: testEntry(now), key: "search=osaka", wantErr: ErrMiss},
+		{name: "missing", key: "search=tokyo", wantErr: ErrMiss},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := New(filepath.Join(t.TempDir(), fileName), 0)
+			cache.now = func() time.Time { return now }
+
+			if tt.entry != nil {
+				require.NoError(t, cache.Save(tt.entry))
+			}
+
+			entry, err := cache.Load(tt.key)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, entry != nil)
+		})
+	}
+}
+
+func TestCache_LoadDamaged(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), fileName)
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), filePerm))
+
+	entry, err := New(path, time.Hour).Load("")
+	require.ErrorIs(t, err, ErrMiss)
+	assert.Nil(t, entry)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"key":"","servers":[]}`), filePerm))
+
+	_, err = New(path, time.Hour).Load("")
+	require.ErrorIs(t, err, ErrMiss, "an empty list is not cached")
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+	t.Setenv("HOME", "/tmp/home")
+
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, appDirName, filepath.Base(filepath.Dir(path)))
+	assert.Equal(t, fileName, filepath.Base(path))
+}
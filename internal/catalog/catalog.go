@@ -0,0 +1,282 @@
+// Package catalog reads and writes static server catalogue files, lists of
+// servers in YAML or JSON used instead of the speedtest.net server list.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+// Format is the encoding of a catalogue file.
+type Format string
+
+const (
+	// FormatJSON is a JSON catalogue.
+	FormatJSON Format = "json"
+	// FormatYAML is a YAML catalogue.
+	FormatYAML Format = "yaml"
+)
+
+const (
+	uploadPath = "/speedtest/upload.php"
+	unknown    = "?"
+	filePerm   = 0o600
+	jsonIndent = "  "
+)
+
+var (
+	// ErrUnsupportedFormat indicates a catalogue file extension other than .json, .yaml or .yml.
+	ErrUnsupportedFormat = errors.New("unsupported catalogue format")
+	// ErrInvalidServer indicates a catalogue server that cannot be tested.
+	ErrInvalidServer = errors.New("invalid catalogue server")
+)
+
+// Server is a server of a catalogue file. Either the URL of the upload
+// endpoint or the host is required, the other one is derived from it.
+type Server struct {
+	ID      string   `json:"id"                yaml:"id"`
+	Name    string   `json:"name,omitempty"    yaml:"name,omitempty"`
+	URL     string   `json:"url,omitempty"     yaml:"url,omitempty"`
+	Host    string   `json:"host,omitempty"    yaml:"host,omitempty"`
+	Lat     *float64 `json:"lat,omitempty"     yaml:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"     yaml:"lon,omitempty"`
+	Country string   `json:"country,omitempty" yaml:"country,omitempty"`
+	Sponsor string   `json:"sponsor,omitempty" yaml:"sponsor,omitempty"`
+}
+
+// File is the content of a catalogue file.
+type File struct {
+	Servers []Server `json:"servers" yaml:"servers"`
+}
+
+// FormatOf returns the format of a catalogue file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("%w: %q, use .json, .yaml or .yml", ErrUnsupportedFormat, path)
+	}
+}
+
+// Load reads the servers of the catalogue file at path.
+func Load(path string) (speedtest.Servers, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalogue: %w", err)
+	}
+
+	defer func() { _ = file.Close() }()
+
+	servers, err := Read(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	return servers, nil
+}
+
+// Save writes the servers to the catalogue file at path, replacing it.
+func Save(path string, servers speedtest.Servers) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create catalogue: %w", err)
+	}
+
+	err = Write(file, format, servers)
+	if err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close catalogue: %w", err)
+	}
+
+	return nil
+}
+
+// Read decodes a catalogue in the given format.
+func Read(r io.Reader, format Format) (speedtest.Servers, error) {
+	var (
+		catalogue File
+		err       error
+	)
+
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&catalogue)
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		err = decoder.Decode(&catalogue)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode catalogue: %w", err)
+	}
+
+	servers := make(speedtest.Servers, 0, len(catalogue.Servers))
+	seen := make(map[string]bool, len(catalogue.Servers))
+
+	for i, entry := range catalogue.Servers {
+		server, errServer := entry.toServer()
+		if errServer != nil {
+			return nil, fmt.Errorf("server %d: %w", i+1, errServer)
+		}
+
+		if seen[server.ID] {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrInvalidServer, server.ID)
+		}
+
+		seen[server.ID] = true
+
+		servers = append(servers, server)
+	}
+
+	return servers, nil
+}
+
+// Write encodes the servers as a catalogue in the given format.
+func Write(w io.Writer, format Format, servers speedtest.Servers) error {
+	catalogue := File{Servers: make([]Server, 0, len(servers))}
+	for _, server := range servers {
+		catalogue.Servers = append(catalogue.Servers, fromServer(server))
+	}
+
+	var err error
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", jsonIndent)
+		err = encoder.Encode(catalogue)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		err = encoder.Encode(catalogue)
+		if err == nil {
+			err = encoder.Close()
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode catalogue: %w", err)
+	}
+
+	return nil
+}
+
+// toServer converts the catalogue entry to a server, filling in what is missing
+// like CustomServer does.
+func (entry Server) toServer() (*speedtest.Server, error) {
+	if len(entry.ID) == 0 {
+		return nil, fmt.Errorf("%w: missing id", ErrInvalidServer)
+	}
+
+	server := &speedtest.Server{
+		ID:      entry.ID,
+		Name:    entry.Name,
+		URL:     entry.URL,
+		Host:    entry.Host,
+		Lat:     unknown,
+		Lon:     unknown,
+		Country: orUnknown(entry.Country),
+		Sponsor: orUnknown(entry.Sponsor),
+	}
+
+	switch {
+	case len(server.URL) > 0:
+		parsedURL, err := url.Parse(server.URL)
+		if err != nil || len(parsedURL.Host) == 0 {
+			return nil, fmt.Errorf("%w: %s has an invalid url %q", ErrInvalidServer, entry.ID, entry.URL)
+		}
+
+		if len(server.Host) == 0 {
+			server.Host = parsedURL.Host
+		}
+	case len(server.Host) > 0:
+		server.URL = (&url.URL{Scheme: "http", Host: server.Host, Path: uploadPath}).String()
+	default:
+		return nil, fmt.Errorf("%w: %s needs a url or host", ErrInvalidServer, entry.ID)
+	}
+
+	if len(server.Name) == 0 {
+		server.Name = server.Host
+	}
+
+	if entry.Lat != nil && entry.Lon != nil {
+		server.Lat = strconv.FormatFloat(*entry.Lat, 'f', -1, 64)
+		server.Lon = strconv.FormatFloat(*entry.Lon, 'f', -1, 64)
+	}
+
+	return server, nil
+}
+
+// fromServer converts a server to a catalogue entry.
+func fromServer(server *speedtest.Server) Server {
+	entry := Server{
+		ID:      server.ID,
+		Name:    server.Name,
+		URL:     server.URL,
+		Host:    server.Host,
+		Country: fromUnknown(server.Country),
+		Sponsor: fromUnknown(server.Sponsor),
+	}
+
+	lat, errLat := strconv.ParseFloat(server.Lat, 64)
+	lon, errLon := strconv.ParseFloat(server.Lon, 64)
+
+	if errLat == nil && errLon == nil {
+		entry.Lat = &lat
+		entry.Lon = &lon
+	}
+
+	return entry
+}
+
+func orUnknown(value string) string {
+	if len(value) == 0 {
+		return unknown
+	}
+
+	return value
+}
+
+func fromUnknown(value string) string {
+	if value == unknown {
+		return ""
+	}
+
+	return value
+}
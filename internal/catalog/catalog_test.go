@@ -0,0 +1,180 @@
+package catalog
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const yamlCatalogue = `servers:
+  - id: "100"
+    name: Office
+    host: speedtest.example.com:8080
+    lat: 35.68
+    lon: 139.76
+    sponsor: Example
+    country: Japan
+  - id: lab
+    url: https://lab.internal/speedtest/upload.php
+`
+
+func TestFormatOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path    string
+		want    Format
+		wantErr bool
+	}{
+		{path: "servers.json", want: FormatJSON},
+		{path: "servers.YAML", want: FormatYAML},
+		{path: "/etc/servers.yml", want: FormatYAML},
+		{path: "servers.txt", wantErr: true},
+		{path: "servers", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := FormatOf(tt.path)
+		if tt.wantErr {
+			require.ErrorIs(t, err, ErrUnsupportedFormat, tt.path)
+
+			continue
+		}
+
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	servers, err := Read(strings.NewReader(yamlCatalogue), FormatYAML)
+	require.NoError(t, err)
+	require.Len(t, servers, 2)
+
+	assert.Equal(t, &speedtest.Server{
+		ID:      "100",
+		Name:    "Office",
+		URL:     "http://speedtest.example.com:8080/speedtest/upload.php",
+		Host:    "speedtest.example.com:8080",
+		Lat:     "35.68",
+		Lon:     "139.76",
+		Country: "Japan",
+		Sponsor: "Example",
+	}, servers[0])
+
+	assert.Equal(t, &speedtest.Server{
+		ID:      "lab",
+		Name:    "lab.internal",
+		URL:     "https://lab.internal/speedtest/upload.php",
+		Host:    "lab.internal",
+		Lat:     "?",
+		Lon:     "?",
+		Country: "?",
+		Sponsor: "?",
+	}, servers[1])
+
+	servers, err = Read(strings.NewReader(`{"servers":[{"id":"1","host":"a:8080","lat":1.5,"lon":-2}]}`), FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+	assert.Equal(t, "1.5", servers[0].Lat)
+	assert.Equal(t, "-2", servers[0].Lon)
+
+	servers, err = Read(strings.NewReader(""), FormatYAML)
+	require.NoError(t, err)
+	assert.Empty(t, servers)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		format  Format
+		wantErr error
+	}{
+		{name: "missing id", content: `{"servers":[{"host":"a:8080"}]}`, format: FormatJSON, wantErr: ErrInvalidServer},
+		{name: "missing endpoint", content: `{"servers":[{"id":"1"}]}`, format: FormatJSON, wantErr: ErrInvalidServer},
+		{name: "bad url", content: `{"servers":[{"id":"1","url":"upload.php"}]}`, format: FormatJSON, wantErr: ErrInvalidServer},
+		{
+			name:    "duplicate id",
+			content: "servers:\n  - {id: \"1\", host: a}\n  - {id: \"1\", host: b}\n",
+			format:  FormatYAML,
+			wantErr: ErrInvalidServer,
+		},
+		{name: "unknown field", content: "servers:\n  - {id: \"1\", hots: a}\n", format: FormatYAML},
+		{name: "unsupported format", content: "", format: "toml", wantErr: ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			servers, err := Read(strings.NewReader(tt.content), tt.format)
+			require.Error(t, err)
+
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+
+			assert.Nil(t, servers)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	servers := speedtest.Servers{{
+		ID:       "1",
+		Name:     "Tokyo",
+		URL:      "http://a:8080/speedtest/upload.php",
+		Host:     "a:8080",
+		Lat:      "35.68",
+		Lon:      "139.76",
+		Country:  "Japan",
+		Sponsor:  "?",
+		Distance: 12,
+	}}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, Write(&buf, FormatYAML, servers))
+	assert.Equal(t, `servers:
+    - id: "1"
+      name: Tokyo
+      url: http://a:8080/speedtest/upload.php
+      host: a:8080
+      lat: 35.68
+      lon: 139.76
+      country: Japan
+`, buf.String())
+
+	require.ErrorIs(t, Write(&buf, "toml", servers), ErrUnsupportedFormat)
+}
+
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	servers, err := Read(strings.NewReader(yamlCatalogue), FormatYAML)
+	require.NoError(t, err)
+
+	for _, name := range []string{"servers.json", "servers.yaml"} {
+		path := filepath.Join(t.TempDir(), name)
+
+		require.NoError(t, Save(path, servers))
+
+		loaded, err := Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, servers, loaded, name)
+	}
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
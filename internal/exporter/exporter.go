@@ -0,0 +1,359 @@
+// Package exporter exposes speedtest results in the Prometheus text exposition format.
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const (
+	namespace   = "speedtest"
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// DefaultHistoryWindow is the period covered by the history statistics.
+	DefaultHistoryWindow = 24 * time.Hour
+)
+
+// Result is a speedtest result along with the data volume its tests used.
+type Result struct {
+	speedtest.Result
+
+	DownloadBytes int64 // bytes used by the download test
+	UploadBytes   int64 // bytes used by the upload test
+}
+
+// labels identify the server and the ISP a result was measured with.
+type labels struct {
+	serverID string
+	sponsor  string
+	isp      string
+}
+
+func (l labels) String() string {
+	return fmt.Sprintf(
+		`server_id="%s",sponsor="%s",isp="%s"`,
+		escapeLabel(l.serverID),
+		escapeLabel(l.sponsor),
+		escapeLabel(l.isp),
+	)
+}
+
+// sample is the latest observation of a server.
+type sample struct {
+	labels        labels
+	timestamp     time.Time
+	dlSpeed       float64
+	ulSpeed       float64
+	latency       time.Duration
+	jitter        time.Duration
+	minLatency    time.Duration
+	maxLatency    time.Duration
+	packetLoss    float64
+	downloadBytes int64
+	uploadBytes   int64
+	runs          int
+}
+
+// serverFailure counts the failed tests of a server.
+type serverFailure struct {
+	labels labels
+	count  int
+}
+
+// Exporter keeps the latest results per server and renders them as metrics.
+type Exporter struct {
+	store  *history.Store
+	window time.Duration
+	now    func() time.Time
+
+	mu             sync.Mutex
+	samples        map[string]*sample
+	serverFailures map[string]*serverFailure
+	failures       int
+	lastSuccess    time.Time
+	lastFailure    time.Time
+}
+
+// New creates an exporter. When store is not nil, min/median/p95 of the results
+// recorded within window are exported as well.
+func New(store *history.Store, window time.Duration) *Exporter {
+	if window <= 0 {
+		window = DefaultHistoryWindow
+	}
+
+	return &Exporter{
+		store:          store,
+		window:         window,
+		now:            time.Now,
+		samples:        make(map[string]*sample),
+		serverFailures: make(map[string]*serverFailure),
+	}
+}
+
+// Observe records the result of a tested server.
+func (e *Exporter) Observe(result Result) {
+	server := result.Server
+
+	isp := ""
+	if result.User != nil {
+		isp = result.User.Isp
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current, ok := e.samples[server.ID]
+	if !ok {
+		current = &sample{}
+		e.samples[server.ID] = current
+	}
+
+	*current = sample{
+		labels:        labels{serverID: server.ID, sponsor: server.Sponsor, isp: isp},
+		timestamp:     result.Timestamp,
+		dlSpeed:       float64(server.DLSpeed),
+		ulSpeed:       float64(server.ULSpeed),
+		latency:       server.Latency,
+		jitter:        server.Jitter,
+		minLatency:    server.MinLatency,
+		maxLatency:    server.MaxLatency,
+		packetLoss:    server.PacketLoss.Loss(),
+		downloadBytes: result.DownloadBytes,
+		uploadBytes:   result.UploadBytes,
+		runs:          current.runs + 1,
+	}
+
+	if result.Timestamp.After(e.lastSuccess) {
+		e.lastSuccess = result.Timestamp
+	}
+}
+
+// ObserveFailure records a run that could not be completed.
+func (e *Exporter) ObserveFailure(timestamp time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+	e.lastFailure = timestamp
+}
+
+// ObserveServerFailure records a server whose test could not be completed,
+// which also counts as a failed run.
+func (e *Exporter) ObserveServerFailure(user *speedtest.User, server *speedtest.Server, timestamp time.Time) {
+	isp := ""
+	if user != nil {
+		isp = user.Isp
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current, ok := e.serverFailures[server.ID]
+	if !ok {
+		current = &serverFailure{}
+		e.serverFailures[server.ID] = current
+	}
+
+	current.labels = labels{serverID: server.ID, sponsor: server.Sponsor, isp: isp}
+	current.count++
+
+	e.failures++
+	e.lastFailure = timestamp
+}
+
+// Handler returns an HTTP handler serving /metrics and, with a history store, /history.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		err := e.WriteMetrics(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	if e.store != nil {
+		mux.HandleFunc("/history", e.serveHistory)
+	}
+
+	return mux
+}
+
+// WriteMetrics writes all metrics in the Prometheus text format.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	var summaries []serverSummary
+
+	if e.store != nil {
+		var err error
+
+		summaries, err = e.historySummaries()
+		if err != nil {
+			return err
+		}
+	}
+
+	buffer := bufio.NewWriter(w)
+
+	e.mu.Lock()
+	e.writeLatest(buffer)
+	e.mu.Unlock()
+
+	writeHistory(buffer, summaries)
+
+	err := buffer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+
+	return nil
+}
+
+// writeLatest writes the latest results, the caller must hold the lock.
+func (e *Exporter) writeLatest(w io.Writer) {
+	samples := make([]*sample, 0, len(e.samples))
+	for _, current := range e.samples {
+		samples = append(samples, current)
+	}
+
+	slices.SortFunc(samples, func(a, b *sample) int {
+		return strings.Compare(a.labels.serverID, b.labels.serverID)
+	})
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(*sample) (float64, bool)
+	}{
+		{"download_bytes_per_second", "Download rate of the latest run.", func(s *sample) (float64, bool) {
+			return s.dlSpeed, s.dlSpeed > 0
+		}},
+		{"upload_bytes_per_second", "Upload rate of the latest run.", func(s *sample) (float64, bool) {
+			return s.ulSpeed, s.ulSpeed > 0
+		}},
+		{"latency_seconds", "Average latency of the latest run.", func(s *sample) (float64, bool) {
+			return s.latency.Seconds(), s.latency > 0
+		}},
+		{"jitter_seconds", "Latency jitter of the latest run.", func(s *sample) (float64, bool) {
+			return s.jitter.Seconds(), s.latency > 0
+		}},
+		{"min_latency_seconds", "Minimum latency of the latest run.", func(s *sample) (float64, bool) {
+			return s.minLatency.Seconds(), s.latency > 0
+		}},
+		{"max_latency_seconds", "Maximum latency of the latest run.", func(s *sample) (float64, bool) {
+			return s.maxLatency.Seconds(), s.latency > 0
+		}},
+		{"packet_loss_ratio", "Packet loss ratio of the latest run.", func(s *sample) (float64, bool) {
+			return s.packetLoss, s.packetLoss >= 0
+		}},
+		{"download_used_bytes", "Bytes used by the download test of the latest run.", func(s *sample) (float64, bool) {
+			return float64(s.downloadBytes), true
+		}},
+		{"upload_used_bytes", "Bytes used by the upload test of the latest run.", func(s *sample) (float64, bool) {
+			return float64(s.uploadBytes), true
+		}},
+		{"last_run_timestamp_seconds", "Unix time of the latest run.", func(s *sample) (float64, bool) {
+			return unixSeconds(s.timestamp), true
+		}},
+	}
+
+	for _, gauge := range gauges {
+		writeHeader(w, gauge.name, gauge.help, "gauge")
+
+		for _, current := range samples {
+			value, ok := gauge.value(current)
+			if ok {
+				writeSample(w, gauge.name, current.labels.String(), value)
+			}
+		}
+	}
+
+	writeHeader(w, "runs_total", "Completed runs per server.", "counter")
+
+	for _, current := range samples {
+		writeSample(w, "runs_total", current.labels.String(), float64(current.runs))
+	}
+
+	writeHeader(w, "failures_total", "Runs that could not be completed.", "counter")
+	writeSample(w, "failures_total", "", float64(e.failures))
+
+	failures := make([]*serverFailure, 0, len(e.serverFailures))
+	for _, current := range e.serverFailures {
+		failures = append(failures, current)
+	}
+
+	slices.SortFunc(failures, func(a, b *serverFailure) int {
+		return strings.Compare(a.labels.serverID, b.labels.serverID)
+	})
+
+	writeHeader(w, "server_failures_total", "Failed tests per server.", "counter")
+
+	for _, current := range failures {
+		writeSample(w, "server_failures_total", current.labels.String(), float64(current.count))
+	}
+
+	writeHeader(w, "last_success_timestamp_seconds", "Unix time of the latest completed run.", "gauge")
+	writeSample(w, "last_success_timestamp_seconds", "", unixSeconds(e.lastSuccess))
+
+	writeHeader(w, "last_failure_timestamp_seconds", "Unix time of the latest failed run.", "gauge")
+	writeSample(w, "last_failure_timestamp_seconds", "", unixSeconds(e.lastFailure))
+}
+
+func (e *Exporter) serveHistory(w http.ResponseWriter, r *http.Request) {
+	filter := history.Filter{Since: e.now().Add(-e.window)}
+
+	if ids := r.URL.Query()["server"]; len(ids) > 0 {
+		filter.ServerIDs = ids
+	}
+
+	records, err := e.store.Load(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		_ = encoder.Encode(record)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, metricType string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s_%s %s\n", namespace, name, help)
+	_, _ = fmt.Fprintf(w, "# TYPE %s_%s %s\n", namespace, name, metricType)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if len(labels) > 0 {
+		labels = "{" + labels + "}"
+	}
+
+	_, _ = fmt.Fprintf(w, "%s_%s%s %g\n", namespace, name, labels, value)
+}
+
+func unixSeconds(timestamp time.Time) float64 {
+	if timestamp.IsZero() {
+		return 0
+	}
+
+	return float64(timestamp.UnixMilli()) / float64(time.Second/time.Millisecond)
+}
+
+// escapeLabel escapes a label value as required by the text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
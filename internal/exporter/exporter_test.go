@@ -0,0 +1,159 @@
+package exporter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+var testTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func testServer() *speedtest.Server {
+	return &speedtest.Server{
+		ID:         "1234",
+		Sponsor:    `Acme "Fiber"`,
+		DLSpeed:    12500000,
+		ULSpeed:    2500000,
+		Latency:    20 * time.Millisecond,
+		Jitter:     2 * time.Millisecond,
+		MinLatency: 15 * time.Millisecond,
+		MaxLatency: 30 * time.Millisecond,
+		PacketLoss: transport.PLoss{Sent: 10, Dup: 0, Max: 19},
+	}
+}
+
+func TestExporter_WriteMetrics(t *testing.T) {
+	t.Parallel()
+
+	exp := New(nil, 0)
+	exp.Observe(Result{
+		Result:        speedtest.Result{Timestamp: testTime, User: &speedtest.User{Isp: "ISP"}, Server: testServer()},
+		DownloadBytes: 1000,
+		UploadBytes:   500,
+	})
+	exp.Observe(Result{Result: speedtest.Result{Timestamp: testTime, Server: testServer()}})
+	exp.ObserveFailure(testTime)
+
+	buffer := &bytes.Buffer{}
+	require.NoError(t, exp.WriteMetrics(buffer))
+
+	labels := `{server_id="1234",sponsor="Acme \"Fiber\"",isp=""}`
+	metrics := buffer.String()
+
+	for _, line := range []string{
+		"# TYPE speedtest_download_bytes_per_second gauge",
+		"speedtest_download_bytes_per_second" + labels + " 1.25e+07",
+		"speedtest_upload_bytes_per_second" + labels + " 2.5e+06",
+		"speedtest_latency_seconds" + labels + " 0.02",
+		"speedtest_jitter_seconds" + labels + " 0.002",
+		"speedtest_min_latency_seconds" + labels + " 0.015",
+		"speedtest_max_latency_seconds" + labels + " 0.03",
+		"speedtest_packet_loss_ratio" + labels + " 0.5",
+		"speedtest_download_used_bytes" + labels + " 0",
+		"speedtest_last_run_timestamp_seconds" + labels + " 1.767323045e+09",
+		"# TYPE speedtest_runs_total counter",
+		"speedtest_runs_total" + labels + " 2",
+		"speedtest_failures_total 1",
+		"speedtest_last_failure_timestamp_seconds 1.767323045e+09",
+	} {
+		assert.Contains(t, metrics, line+"\n")
+	}
+
+	assert.NotContains(t, metrics, "speedtest_history_")
+}
+
+func TestExporter_WriteMetricsSkipsUnmeasured(t *testing.T) {
+	t.Parallel()
+
+	exp := New(nil, 0)
+	exp.Observe(Result{Result: speedtest.Result{Timestamp: testTime, Server: &speedtest.Server{ID: "1"}}})
+
+	buffer := &bytes.Buffer{}
+	require.NoError(t, exp.WriteMetrics(buffer))
+
+	metrics := buffer.String()
+	assert.NotContains(t, metrics, "speedtest_download_bytes_per_second{")
+	assert.NotContains(t, metrics, "speedtest_packet_loss_ratio{")
+	assert.Contains(t, metrics, `speedtest_runs_total{server_id="1",sponsor="",isp=""} 1`)
+}
+
+func TestExporter_ObserveServerFailure(t *testing.T) {
+	t.Parallel()
+
+	exp := New(nil, 0)
+	exp.ObserveServerFailure(&speedtest.User{Isp: "ISP"}, &speedtest.Server{ID: "2", Sponsor: "Down"}, testTime)
+	exp.ObserveServerFailure(nil, &speedtest.Server{ID: "2", Sponsor: "Down"}, testTime)
+	exp.Observe(Result{Result: speedtest.Result{Timestamp: testTime, Server: testServer()}})
+
+	buffer := &bytes.Buffer{}
+	require.NoError(t, exp.WriteMetrics(buffer))
+
+	metrics := buffer.String()
+	assert.Contains(t, metrics, "speedtest_failures_total 2\n")
+	assert.Contains(t, metrics, `speedtest_server_failures_total{server_id="2",sponsor="Down",isp=""} 2`+"\n")
+	assert.Contains(t, metrics, `speedtest_runs_total{server_id="1234",sponsor="Acme \"Fiber\"",isp=""} 1`)
+	assert.NotContains(t, metrics, `speedtest_runs_total{server_id="2"`)
+}
+
+func TestExporter_History(t *testing.T) {
+	t.Parallel()
+
+	store := history.New(filepath.Join(t.TempDir(), "history.jsonl"))
+	require.NoError(t, store.Append(
+		history.NewRecord(&speedtest.User{Isp: "ISP"}, testServer(), testTime.Add(-48*time.Hour)),
+		history.NewRecord(&speedtest.User{Isp: "ISP"}, testServer(), testTime.Add(-time.Hour)),
+		history.NewRecord(&speedtest.User{Isp: "ISP"}, testServer(), testTime),
+	))
+
+	exp := New(store, DefaultHistoryWindow)
+	exp.now = func() time.Time { return testTime }
+
+	buffer := &bytes.Buffer{}
+	require.NoError(t, exp.WriteMetrics(buffer))
+
+	labels := `server_id="1234",sponsor="Acme \"Fiber\"",isp="ISP"`
+	metrics := buffer.String()
+
+	assert.Contains(t, metrics, "speedtest_history_runs{"+labels+"} 2\n")
+	assert.Contains(t, metrics,
+		"speedtest_history_download_bytes_per_second{"+labels+`,stat="median"} 1.25e+07`+"\n")
+	assert.Contains(t, metrics, "speedtest_history_latency_seconds{"+labels+`,stat="p95"} 0.02`+"\n")
+	assert.Contains(t, metrics, "speedtest_history_packet_loss_ratio{"+labels+`,stat="min"} 0.5`+"\n")
+
+	recorder := httptest.NewRecorder()
+	exp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/history?server=1234", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, bytes.Count(recorder.Body.Bytes(), []byte("\n")))
+}
+
+func TestExporter_Handler(t *testing.T) {
+	t.Parallel()
+
+	handler := New(nil, 0).Handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "speedtest_failures_total 0")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/history", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func Test_escapeLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabel("a\\b\"c\nd"))
+}
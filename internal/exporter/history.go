@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
+)
+
+const percentToRatio = 100
+
+// serverSummary aggregates the recorded results of a single server.
+type serverSummary struct {
+	labels  labels
+	summary history.Summary
+}
+
+// historySummaries summarizes the results recorded within the history window per server.
+func (e *Exporter) historySummaries() ([]serverSummary, error) {
+	records, err := e.store.Load(history.Filter{Since: e.now().Add(-e.window)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+
+	grouped := make(map[string][]history.Record)
+	for _, record := range records {
+		grouped[record.ServerID] = append(grouped[record.ServerID], record)
+	}
+
+	summaries := make([]serverSummary, 0, len(grouped))
+
+	for serverID, serverRecords := range grouped {
+		// label with the latest record, the sponsor or ISP may change over time.
+		latest := serverRecords[len(serverRecords)-1]
+		summaries = append(summaries, serverSummary{
+			labels:  labels{serverID: serverID, sponsor: latest.Sponsor, isp: latest.ISP},
+			summary: history.Summarize(serverRecords),
+		})
+	}
+
+	slices.SortFunc(summaries, func(a, b serverSummary) int {
+		return strings.Compare(a.labels.serverID, b.labels.serverID)
+	})
+
+	return summaries, nil
+}
+
+// writeHistory writes min, median and p95 of the recorded results per server.
+func writeHistory(w io.Writer, summaries []serverSummary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	writeHeader(w, "history_runs", "Recorded runs within the history window.", "gauge")
+
+	for _, current := range summaries {
+		writeSample(w, "history_runs", current.labels.String(), float64(current.summary.Runs))
+	}
+
+	metrics := []struct {
+		name  string
+		help  string
+		stats func(history.Summary) history.Stats
+		scale float64
+	}{
+		{"history_download_bytes_per_second", "Recorded download rates.",
+			func(s history.Summary) history.Stats { return s.DLSpeed }, 1},
+		{"history_upload_bytes_per_second", "Recorded upload rates.",
+			func(s history.Summary) history.Stats { return s.ULSpeed }, 1},
+		{"history_latency_seconds", "Recorded latencies.",
+			func(s history.Summary) history.Stats { return s.Latency }, float64(time.Second)},
+		{"history_jitter_seconds", "Recorded jitters.",
+			func(s history.Summary) history.Stats { return s.Jitter }, float64(time.Second)},
+		{"history_packet_loss_ratio", "Recorded packet loss ratios.",
+			func(s history.Summary) history.Stats { return s.PacketLoss }, percentToRatio},
+	}
+
+	for _, metric := range metrics {
+		writeHeader(w, metric.name, metric.help+" The stat label is min, median or p95.", "gauge")
+
+		for _, current := range summaries {
+			stats := metric.stats(current.summary)
+			if stats.Count == 0 {
+				continue
+			}
+
+			base := current.labels.String()
+			writeSample(w, metric.name, base+`,stat="min"`, stats.Min/metric.scale)
+			writeSample(w, metric.name, base+`,stat="median"`, stats.Median/metric.scale)
+			writeSample(w, metric.name, base+`,stat="p95"`, stats.P95/metric.scale)
+		}
+	}
+}
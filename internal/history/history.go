@@ -0,0 +1,209 @@
+// Package history provides a persistent, append-only store of speedtest results.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const (
+	appDirName  = "speedtest-go"
+	fileName    = "history.jsonl"
+	dirPerm     = 0o755
+	filePerm    = 0o600
+	maxLineSize = 1 << 20 // upper bound of a single encoded record
+)
+
+// Record is a single speedtest result kept in the history store.
+type Record struct {
+	Timestamp  time.Time          `json:"timestamp"`
+	ServerID   string             `json:"serverId"`
+	ServerName string             `json:"serverName"`
+	Sponsor    string             `json:"sponsor"`
+	Country    string             `json:"country"`
+	Host       string             `json:"host"`
+	Distance   float64            `json:"distance"`
+	Source     string             `json:"source,omitempty"` // source interface or address of the test
+	IP         string             `json:"ip,omitempty"`
+	ISP        string             `json:"isp,omitempty"`
+	DLSpeed    speedtest.ByteRate `json:"dlSpeed"`
+	ULSpeed    speedtest.ByteRate `json:"ulSpeed"`
+	Latency    time.Duration      `json:"latency"`
+	Jitter     time.Duration      `json:"jitter"`
+	PacketLoss float64            `json:"packetLoss"` // percent, -1 when not available
+}
+
+// NewRecord creates a record from the result of a tested server.
+func NewRecord(user *speedtest.User, server *speedtest.Server, timestamp time.Time) Record {
+	record := Record{
+		Timestamp:  timestamp,
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		Sponsor:    server.Sponsor,
+		Country:    server.Country,
+		Host:       server.Host,
+		Distance:   server.Distance,
+		Source:     server.Source,
+		DLSpeed:    server.DLSpeed,
+		ULSpeed:    server.ULSpeed,
+		Latency:    server.Latency,
+		Jitter:     server.Jitter,
+		PacketLoss: server.PacketLoss.LossPercent(),
+	}
+
+	if user != nil {
+		record.IP = user.IP
+		record.ISP = user.Isp
+	}
+
+	return record
+}
+
+// Filter selects records from the store. Zero values match every record.
+type Filter struct {
+	ServerIDs []string  // only keep records of these servers
+	Since     time.Time // only keep records at or after this time
+	Until     time.Time // only keep records before this time
+}
+
+// Match reports whether the record is selected by the filter.
+func (f Filter) Match(record Record) bool {
+	if len(f.ServerIDs) > 0 && !slices.Contains(f.ServerIDs, record.ServerID) {
+		return false
+	}
+
+	if !f.Since.IsZero() && record.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !record.Timestamp.Before(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Store is an append-only JSONL file of records.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the history file location under the user config directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+
+	return filepath.Join(dir, appDirName, fileName), nil
+}
+
+// New creates a store backed by the file at path.
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the location of the backing file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append writes the records to the end of the store, creating it if needed.
+func (s *Store) Append(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(s.path), dirPerm)
+	if err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// encode everything up front so a failing record never leaves a partial line.
+	var data []byte
+
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode history record: %w", err)
+		}
+
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close history file: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the records selected by the filter in the order they were written.
+// A missing store yields no records. Lines that cannot be decoded, such as a
+// line truncated by an interrupted write, are skipped.
+func (s *Store) Load(filter Filter) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineSize)
+
+	for scanner.Scan() {
+		var record Record
+
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return records, nil
+}
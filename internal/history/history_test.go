@@ -0,0 +1,131 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+func TestNewRecord(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := &speedtest.Server{
+		ID:         "1234",
+		Name:       "Tokyo",
+		Sponsor:    "Test",
+		Country:    "Japan",
+		Host:       "example.com:8080",
+		Source:     "eth1",
+		DLSpeed:    1000,
+		ULSpeed:    500,
+		Latency:    10 * time.Millisecond,
+		Jitter:     time.Millisecond,
+		PacketLoss: transport.PLoss{Sent: 100, Dup: 0, Max: 99},
+	}
+
+	record := NewRecord(&speedtest.User{IP: "127.0.0.1", Isp: "ISP"}, server, timestamp)
+	assert.Equal(t, Record{
+		Timestamp:  timestamp,
+		ServerID:   "1234",
+		ServerName: "Tokyo",
+		Sponsor:    "Test",
+		Country:    "Japan",
+		Host:       "example.com:8080",
+		Source:     "eth1",
+		IP:         "127.0.0.1",
+		ISP:        "ISP",
+		DLSpeed:    1000,
+		ULSpeed:    500,
+		Latency:    10 * time.Millisecond,
+		Jitter:     time.Millisecond,
+		PacketLoss: 0,
+	}, record)
+
+	record = NewRecord(nil, &speedtest.Server{ID: "1"}, timestamp)
+	assert.Empty(t, record.ISP)
+	assert.InDelta(t, -1, record.PacketLoss, 0)
+}
+
+func TestFilter_Match(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	record := Record{ServerID: "1", Timestamp: base}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty filter", filter: Filter{}, want: true},
+		{name: "server match", filter: Filter{ServerIDs: []string{"2", "1"}}, want: true},
+		{name: "server mismatch", filter: Filter{ServerIDs: []string{"2"}}, want: false},
+		{name: "since inclusive", filter: Filter{Since: base}, want: true},
+		{name: "since after", filter: Filter{Since: base.Add(time.Second)}, want: false},
+		{name: "until exclusive", filter: Filter{Until: base}, want: false},
+		{name: "until after", filter: Filter{Until: base.Add(time.Second)}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.filter.Match(record))
+		})
+	}
+}
+
+func TestStore_AppendLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", fileName)
+	store := New(path)
+	assert.Equal(t, path, store.Path())
+
+	records, err := store.Load(Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	base := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	first := Record{ServerID: "1", Timestamp: base, DLSpeed: 100, PacketLoss: -1}
+	second := Record{ServerID: "2", Timestamp: base.Add(time.Hour), ULSpeed: 50}
+
+	require.NoError(t, store.Append(first))
+	require.NoError(t, store.Append(second))
+	require.NoError(t, store.Append())
+
+	records, err = store.Load(Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{first, second}, records)
+
+	records, err = store.Load(Filter{ServerIDs: []string{"2"}})
+	require.NoError(t, err)
+	assert.Equal(t, []Record{second}, records)
+
+	// a truncated trailing line is skipped.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, filePerm)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"serverId":"3","timest`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	records, err = store.Load(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+	t.Setenv("HOME", "/tmp/home")
+
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, appDirName, filepath.Base(filepath.Dir(path)))
+	assert.Equal(t, fileName, filepath.Base(path))
+}
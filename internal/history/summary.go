@@ -0,0 +1,102 @@
+package history
+
+import (
+	"slices"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const p95 = 0.95
+
+// Stats describes the distribution of a single metric.
+// Count is zero when no record provided a value for the metric.
+type Stats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+}
+
+// Summary aggregates the metrics of a set of records.
+// Rates are in bytes per second, latency and jitter in nanoseconds and
+// packet loss in percent.
+type Summary struct {
+	Runs       int       `json:"runs"`
+	First      time.Time `json:"first"`
+	Last       time.Time `json:"last"`
+	DLSpeed    Stats     `json:"dlSpeed"`
+	ULSpeed    Stats     `json:"ulSpeed"`
+	Latency    Stats     `json:"latency"`
+	Jitter     Stats     `json:"jitter"`
+	PacketLoss Stats     `json:"packetLoss"`
+}
+
+// Summarize computes min, median and p95 of every metric. Metrics that were not
+// measured in a run, such as a skipped upload or unavailable packet loss, are
+// excluded from the statistics of that metric.
+func Summarize(records []Record) Summary {
+	summary := Summary{Runs: len(records)}
+
+	var dlSpeed, ulSpeed, latency, jitter, packetLoss []float64
+
+	for _, record := range records {
+		if summary.First.IsZero() || record.Timestamp.Before(summary.First) {
+			summary.First = record.Timestamp
+		}
+
+		if record.Timestamp.After(summary.Last) {
+			summary.Last = record.Timestamp
+		}
+
+		if record.DLSpeed > 0 {
+			dlSpeed = append(dlSpeed, float64(record.DLSpeed))
+		}
+
+		if record.ULSpeed > 0 {
+			ulSpeed = append(ulSpeed, float64(record.ULSpeed))
+		}
+
+		if record.Latency > 0 {
+			latency = append(latency, float64(record.Latency))
+			jitter = append(jitter, float64(record.Jitter))
+		}
+
+		if record.PacketLoss >= 0 {
+			packetLoss = append(packetLoss, record.PacketLoss)
+		}
+	}
+
+	summary.DLSpeed = newStats(dlSpeed)
+	summary.ULSpeed = newStats(ulSpeed)
+	summary.Latency = newStats(latency)
+	summary.Jitter = newStats(jitter)
+	summary.PacketLoss = newStats(packetLoss)
+
+	return summary
+}
+
+func newStats(values []float64) Stats {
+	if len(values) == 0 {
+		return Stats{}
+	}
+
+	slices.Sort(values)
+
+	return Stats{
+		Count:  len(values),
+		Min:    values[0],
+		Median: median(values),
+		P95:    speedtest.Percentile(values, p95),
+	}
+}
+
+// median returns the median of sorted values.
+func median(values []float64) float64 {
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+
+	return values[mid]
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	records := make([]Record, 0, 20)
+	for i := 1; i <= 20; i++ {
+		records = append(records, Record{
+			Timestamp:  base.Add(time.Duration(i) * time.Hour),
+			DLSpeed:    speedtest.ByteRate(100 * i),
+			ULSpeed:    0, // upload skipped
+			Latency:    time.Duration(i) * time.Millisecond,
+			Jitter:     time.Millisecond,
+			PacketLoss: -1,
+		})
+	}
+
+	records[3].PacketLoss = 1.5
+
+	summary := Summarize(records)
+	assert.Equal(t, 20, summary.Runs)
+	assert.Equal(t, base.Add(time.Hour), summary.First)
+	assert.Equal(t, base.Add(20*time.Hour), summary.Last)
+
+	assert.Equal(t, Stats{Count: 20, Min: 100, Median: 1050, P95: 1900}, summary.DLSpeed)
+	assert.Equal(t, Stats{}, summary.ULSpeed)
+	assert.Equal(t, Stats{
+		Count:  20,
+		Min:    float64(time.Millisecond),
+		Median: float64(10500 * time.Microsecond),
+		P95:    float64(19 * time.Millisecond),
+	}, summary.Latency)
+	assert.Equal(t, Stats{Count: 1, Min: 1.5, Median: 1.5, P95: 1.5}, summary.PacketLoss)
+}
+
+func TestSummarize_Empty(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Summary{}, Summarize(nil))
+}
@@ -2,11 +2,17 @@
 package output
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
@@ -36,6 +42,266 @@ func ShowServerList(servers speedtest.Servers) {
 	}
 }
 
+// serverListHeader is the column order of WriteServerListCSV.
+var serverListHeader = []string{
+	"server_id",
+	"server_name",
+	"sponsor",
+	"country",
+	"host",
+	"url",
+	"lat",
+	"lon",
+	"distance_km",
+	"latency_ms",
+}
+
+// WriteServerListJSON writes the servers as a JSON array, in the server format
+// of the json result output.
+func WriteServerListJSON(w io.Writer, servers speedtest.Servers) error {
+	data, err := json.Marshal(servers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal server list: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to write server list: %w", err)
+	}
+
+	return nil
+}
+
+// WriteServerListCSV writes the servers as CSV rows after a header row. The
+// latency of unreachable servers is empty.
+func WriteServerListCSV(w io.Writer, servers speedtest.Servers) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(serverListHeader)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, server := range servers {
+		latency := ""
+		if server.Latency > 0 {
+			latency = strconv.FormatFloat(float64(server.Latency)/float64(time.Millisecond), 'f', 3, 64)
+		}
+
+		err = writer.Write([]string{
+			server.ID,
+			server.Name,
+			server.Sponsor,
+			server.Country,
+			server.Host,
+			server.URL,
+			server.Lat,
+			server.Lon,
+			strconv.FormatFloat(server.Distance, 'f', 2, 64),
+			latency,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+
+	err = writer.Error()
+	if err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+
+	return nil
+}
+
+// ShowHistory prints the recorded speedtest results, one run per line, with the
+// rates in the unit.
+func ShowHistory(records []history.Record, unit speedtest.UnitType) {
+	for _, record := range records {
+		_, _ = fmt.Fprintf(
+			os.Stdout,
+			"%s [%5s] %s (%s) by %s\n",
+			record.Timestamp.Local().Format(time.DateTime),
+			record.ServerID,
+			record.ServerName,
+			record.Country,
+			record.Sponsor,
+		)
+		_, _ = fmt.Fprintf(
+			os.Stdout,
+			"    Download: %s Upload: %s Latency: %v Jitter: %v Packet Loss: %s\n",
+			rateOrNA(record.DLSpeed, unit),
+			rateOrNA(record.ULSpeed, unit),
+			record.Latency,
+			record.Jitter,
+			percentOrNA(record.PacketLoss),
+		)
+	}
+}
+
+// ShowHistorySummary prints min, median and p95 statistics of recorded results,
+// with the rates in the unit.
+func ShowHistorySummary(summary history.Summary, unit speedtest.UnitType) {
+	if summary.Runs == 0 {
+		_, _ = fmt.Fprintln(os.Stdout, "No recorded runs")
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(
+		os.Stdout,
+		"Runs: %d (%s - %s)\n",
+		summary.Runs,
+		summary.First.Local().Format(time.DateTime),
+		summary.Last.Local().Format(time.DateTime),
+	)
+
+	rows := []struct {
+		name   string
+		stats  history.Stats
+		format func(float64) string
+	}{
+		{"Download", summary.DLSpeed, func(v float64) string { return speedtest.ByteRate(v).Format(unit) }},
+		{"Upload", summary.ULSpeed, func(v float64) string { return speedtest.ByteRate(v).Format(unit) }},
+		{"Latency", summary.Latency, formatDuration},
+		{"Jitter", summary.Jitter, formatDuration},
+		{"Packet Loss", summary.PacketLoss, func(v float64) string { return percentOrNA(v) }},
+	}
+
+	for _, row := range rows {
+		if row.stats.Count == 0 {
+			_, _ = fmt.Fprintf(os.Stdout, "%-12s N/A\n", row.name+":")
+
+			continue
+		}
+
+		_, _ = fmt.Fprintf(
+			os.Stdout,
+			"%-12s min %s / median %s / p95 %s (%d runs)\n",
+			row.name+":",
+			row.format(row.stats.Min),
+			row.format(row.stats.Median),
+			row.format(row.stats.P95),
+			row.stats.Count,
+		)
+	}
+}
+
+func rateOrNA(rate speedtest.ByteRate, unit speedtest.UnitType) string {
+	if rate <= 0 {
+		return "N/A"
+	}
+
+	return rate.Format(unit)
+}
+
+// comparisonColumnWidth is the minimum width of the columns of a comparison.
+const comparisonColumnWidth = 16
+
+// ShowDualStack prints the results of the servers tested over both families
+// side by side. The servers are pairs of the same server tested over IPv4 and
+// over IPv6, in that order.
+func ShowDualStack(servers speedtest.Servers, unit speedtest.UnitType) {
+	writeComparison(os.Stdout, servers, 2, unit, func(server *speedtest.Server) string {
+		return server.IPFamily.String()
+	})
+}
+
+// ShowSources prints the results of the servers tested from several sources
+// side by side, keyed by source. Every server is tested from count sources in
+// a row, in the same order.
+func ShowSources(servers speedtest.Servers, count int, unit speedtest.UnitType) {
+	writeComparison(os.Stdout, servers, count, unit, func(server *speedtest.Server) string {
+		return server.Source
+	})
+}
+
+// comparisonRow is a row of the side by side results, the value of every server.
+type comparisonRow struct {
+	name  string
+	value func(*speedtest.Server) string
+}
+
+// writeComparison writes the results of groups of count servers side by side,
+// in columns labeled by label, with the rates in the unit. The servers of a
+// group are the same server tested by different clients. A group with failed
+// servers gets a status row, followed by the errors.
+func writeComparison(
+	w io.Writer, servers speedtest.Servers, count int, unit speedtest.UnitType, label func(*speedtest.Server) string,
+) {
+	if count < 1 {
+		return
+	}
+
+	for i := 0; i+count <= len(servers); i += count {
+		group := servers[i : i+count]
+
+		width := comparisonColumnWidth
+		for _, server := range group {
+			width = max(width, len(label(server))+1)
+		}
+
+		rows := []comparisonRow{
+			{"", label},
+			{"Latency:", func(server *speedtest.Server) string { return server.Latency.String() }},
+			{"Jitter:", func(server *speedtest.Server) string { return server.Jitter.String() }},
+			{"Download:", func(server *speedtest.Server) string { return server.DLSpeed.Format(unit) }},
+			{"Upload:", func(server *speedtest.Server) string { return server.ULSpeed.Format(unit) }},
+			{"Packet Loss:", func(server *speedtest.Server) string {
+				return percentOrNA(server.PacketLoss.LossPercent())
+			}},
+		}
+
+		failed := slices.ContainsFunc(group, func(server *speedtest.Server) bool { return len(server.Error) > 0 })
+		if failed {
+			rows = append(rows, comparisonRow{"Status:", status})
+		}
+
+		_, _ = fmt.Fprintf(w, "\n%s\n", group[0].String())
+
+		for _, row := range rows {
+			line := fmt.Sprintf("%-12s", row.name)
+			for j, server := range group {
+				if j == len(group)-1 {
+					line += " " + row.value(server)
+				} else {
+					line += fmt.Sprintf(" %-*s", width, row.value(server))
+				}
+			}
+
+			_, _ = fmt.Fprintln(w, line)
+		}
+
+		for _, server := range group {
+			if len(server.Error) > 0 {
+				_, _ = fmt.Fprintf(w, "%s: %s\n", label(server), server.Error)
+			}
+		}
+	}
+}
+
+// status returns whether the tests of the server failed.
+func status(server *speedtest.Server) string {
+	if len(server.Error) > 0 {
+		return "Failed"
+	}
+
+	return "OK"
+}
+
+func percentOrNA(percent float64) string {
+	if percent < 0 {
+		return "N/A"
+	}
+
+	return fmt.Sprintf("%.2f%%", percent)
+}
+
+func formatDuration(value float64) string {
+	return time.Duration(value).Round(time.Microsecond).String()
+}
+
 // AppInfo prints application information.
 func AppInfo(jsonOutput, jsonlOutput bool) {
 	if !jsonOutput && !jsonlOutput {
@@ -1,11 +1,16 @@
 package output
 
 import (
+	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/nicholas-fedor/speedtest-go/internal/history"
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
@@ -35,6 +40,55 @@ func TestShowServerList(t *testing.T) {
 	}
 }
 
+func TestWriteServerListCSV(t *testing.T) {
+	t.Parallel()
+
+	servers := speedtest.Servers{
+		{ID: "1", Name: "Tokyo", Sponsor: "Example, Inc.", Country: "Japan", Host: "a:8080", Lat: "35.68", Lon: "139.76",
+			URL: "http://a:8080/speedtest/upload.php", Distance: 9.034, Latency: 12500 * time.Microsecond},
+		{ID: "2", Name: "Osaka", Distance: 400, Latency: speedtest.PingTimeout},
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, WriteServerListCSV(&buf, servers))
+	assert.Equal(t, `server_id,server_name,sponsor,country,host,url,lat,lon,distance_km,latency_ms
+1,Tokyo,"Example, Inc.",Japan,a:8080,http://a:8080/speedtest/upload.php,35.68,139.76,9.03,12.500
+2,Osaka,,,,,,,400.00,
+`, buf.String())
+}
+
+func TestWriteServerListJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	require.NoError(t, WriteServerListJSON(&buf, speedtest.Servers{{ID: "1", Distance: 9.5}}))
+
+	var servers speedtest.Servers
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &servers))
+	require.Len(t, servers, 1)
+	assert.Equal(t, "1", servers[0].ID)
+	assert.InDelta(t, 9.5, servers[0].Distance, 0)
+
+	buf.Reset()
+	require.NoError(t, WriteServerListJSON(&buf, speedtest.Servers{}))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestShowDualStack(t *testing.T) {
+	t.Parallel()
+
+	servers := speedtest.Servers{
+		{ID: "1", Name: "Tokyo", IPFamily: speedtest.IPFamilyIPv4, Latency: 10 * time.Millisecond},
+		{ID: "1", Name: "Tokyo", IPFamily: speedtest.IPFamilyIPv6, Latency: 12 * time.Millisecond},
+		{ID: "2", Name: "Osaka", IPFamily: speedtest.IPFamilyIPv4},
+	}
+
+	assert.NotPanics(t, func() { ShowDualStack(servers, speedtest.UnitTypeDecimalBits) })
+}
+
 func TestAppInfo(t *testing.T) {
 	tests := []struct {
 		name string
@@ -79,3 +133,61 @@ func TestVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestShowHistory(t *testing.T) {
+	t.Parallel()
+
+	records := []history.Record{
+		{ServerID: "1", ServerName: "Test Server", DLSpeed: 1000, PacketLoss: -1},
+		{ServerID: "2", ServerName: "Other Server", ULSpeed: 1000, PacketLoss: 0.5},
+	}
+
+	assert.NotPanics(t, func() { ShowHistory(records, speedtest.UnitTypeDefaultMbps) })
+	assert.NotPanics(t, func() { ShowHistorySummary(history.Summarize(records), speedtest.UnitTypeDefaultMbps) })
+	assert.NotPanics(t, func() { ShowHistorySummary(history.Summary{}, speedtest.UnitTypeDefaultMbps) })
+}
+
+func Test_percentOrNA(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "N/A", percentOrNA(-1))
+	assert.Equal(t, "1.50%", percentOrNA(1.5))
+}
+
+func Test_writeComparison(t *testing.T) {
+	t.Parallel()
+
+	servers := speedtest.Servers{
+		{ID: "1", Name: "Tokyo", Sponsor: "Test", Source: "eth0", Latency: 10 * time.Millisecond, DLSpeed: -1},
+		{ID: "1", Name: "Tokyo", Sponsor: "Test", Source: "192.168.10.2", Latency: 20 * time.Millisecond, DLSpeed: -1},
+		{ID: "1", Name: "Tokyo", Sponsor: "Test", Source: "a-very-long-interface", DLSpeed: -1},
+		{ID: "2", Name: "Osaka", Sponsor: "Test", Source: "eth0"},
+	}
+
+	buffer := &bytes.Buffer{}
+	writeComparison(buffer, servers, 3, speedtest.UnitTypeDecimalBits, func(server *speedtest.Server) string { return server.Source })
+
+	lines := strings.Split(buffer.String(), "\n")
+	// the incomplete group of the last server is left out.
+	require.Len(t, lines, 9)
+	assert.Equal(t, "[   1] 0.00km Tokyo by Test", lines[1])
+	assert.Equal(t, "             eth0                   192.168.10.2           a-very-long-interface", lines[2])
+	assert.Equal(t, "Latency:     10ms                   20ms                   0s", lines[3])
+	assert.Equal(t, "Download:    N/A                    N/A                    N/A", lines[5])
+	assert.Equal(t, "Packet Loss: N/A                    N/A                    N/A", lines[7])
+
+	// a failed server is marked and its error printed below.
+	servers[1].Error = "download: connection refused"
+
+	buffer.Reset()
+	writeComparison(buffer, servers, 3, speedtest.UnitTypeDecimalBits, func(server *speedtest.Server) string { return server.Source })
+
+	lines = strings.Split(buffer.String(), "\n")
+	require.Len(t, lines, 11)
+	assert.Equal(t, "Status:      OK                     Failed                 OK", lines[8])
+	assert.Equal(t, "192.168.10.2: download: connection refused", lines[9])
+
+	buffer.Reset()
+	writeComparison(buffer, servers, 0, speedtest.UnitTypeDecimalBits, func(server *speedtest.Server) string { return server.Source })
+	assert.Empty(t, buffer.String())
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+// progressTimeFormat is the format of the timestamps, the one of the json result output.
+const progressTimeFormat = "2006-01-02 15:04:05.000"
+
+// progressLine is a line of the progress output. Rates are in bytes per second
+// and durations in nanoseconds, like in the json result output.
+type progressLine struct {
+	Type       speedtest.EventType `json:"type"`
+	Timestamp  string              `json:"timestamp"`
+	ServerID   string              `json:"serverId,omitempty"`
+	Host       string              `json:"host,omitempty"`
+	IPFamily   speedtest.IPFamily  `json:"ipFamily,omitempty"`
+	Source     string              `json:"source,omitempty"`
+	Phase      speedtest.Phase     `json:"phase,omitempty"`
+	Elapsed    *time.Duration      `json:"elapsed,omitempty"`
+	Rate       *speedtest.ByteRate `json:"rate,omitempty"`
+	Bytes      *int64              `json:"bytes,omitempty"`
+	Latency    *time.Duration      `json:"latency,omitempty"`
+	PacketLoss *transport.PLoss    `json:"packetLoss,omitempty"`
+	Addr       string              `json:"addr,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// ProgressWriter writes the events of the tests as lines of JSON.
+type ProgressWriter struct {
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewProgressWriter creates a writer of progress lines to w.
+func NewProgressWriter(w io.Writer) *ProgressWriter {
+	return &ProgressWriter{writer: w}
+}
+
+// WriteEvent writes the event as a line of JSON. The latency of a rate sample
+// is loadedLatency, the latency under load, which is left out if it is 0.
+func (p *ProgressWriter) WriteEvent(event speedtest.Event, loadedLatency time.Duration) error {
+	line := progressLine{
+		Type:       event.Type,
+		Timestamp:  event.Time.Format(progressTimeFormat),
+		Host:       event.Host,
+		Phase:      event.Phase,
+		PacketLoss: event.PacketLoss,
+		Addr:       event.Addr,
+	}
+
+	if event.Server != nil {
+		line.ServerID = event.Server.ID
+		line.IPFamily = event.Server.IPFamily
+		line.Source = event.Server.Source
+	}
+
+	if event.Err != nil {
+		line.Error = event.Err.Error()
+	}
+
+	switch event.Type {
+	case speedtest.EventRateSample:
+		line.Elapsed = &event.Elapsed
+		line.Rate = &event.Rate
+		line.Bytes = &event.Bytes
+
+		if loadedLatency > 0 {
+			line.Latency = &loadedLatency
+		}
+	case speedtest.EventPingSample:
+		line.Latency = &event.Latency
+	case speedtest.EventPhaseFinished:
+		line.Elapsed = &event.Elapsed
+	default:
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.writer.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write progress: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+func TestProgressWriter_WriteEvent(t *testing.T) {
+	t.Parallel()
+
+	stamp := time.Date(2026, 1, 2, 3, 4, 5, 6_000_000, time.UTC)
+	server := &speedtest.Server{ID: "42", Host: "example.com:8080", Source: "eth1"}
+
+	tests := []struct {
+		name          string
+		event         speedtest.Event
+		loadedLatency time.Duration
+		want          string
+	}{
+		{
+			name: "rate sample",
+			event: speedtest.Event{
+				Type: speedtest.EventRateSample, Time: stamp, Server: server, Host: server.Host,
+				Phase: speedtest.PhaseDownload, Elapsed: time.Second, Rate: 1000, Bytes: 2000,
+			},
+			loadedLatency: 5 * time.Millisecond,
+			want: `{"type":"rate_sample","timestamp":"2026-01-02 03:04:05.006","serverId":"42",` +
+				`"host":"example.com:8080","source":"eth1","phase":"download","elapsed":1000000000,` +
+				`"rate":1000,"bytes":2000,"latency":5000000}`,
+		},
+		{
+			name: "rate sample without latency",
+			event: speedtest.Event{
+				Type: speedtest.EventRateSample, Time: stamp, Host: server.Host, Phase: speedtest.PhaseUpload,
+			},
+			want: `{"type":"rate_sample","timestamp":"2026-01-02 03:04:05.006","host":"example.com:8080",` +
+				`"phase":"upload","elapsed":0,"rate":0,"bytes":0}`,
+		},
+		{
+			name: "ping sample",
+			event: speedtest.Event{
+				Type: speedtest.EventPingSample, Time: stamp, Host: server.Host, Phase: speedtest.PhasePing,
+				Latency: time.Millisecond,
+			},
+			loadedLatency: 5 * time.Millisecond,
+			want: `{"type":"ping_sample","timestamp":"2026-01-02 03:04:05.006","host":"example.com:8080",` +
+				`"phase":"ping","latency":1000000}`,
+		},
+		{
+			name: "failed connection",
+			event: speedtest.Event{
+				Type: speedtest.EventConnectionFailed, Time: stamp, Host: server.Host, Phase: speedtest.PhasePing,
+				Addr: server.Host, Err: errors.New("refused"),
+			},
+			want: `{"type":"connection_failed","timestamp":"2026-01-02 03:04:05.006","host":"example.com:8080",` +
+				`"phase":"ping","addr":"example.com:8080","error":"refused"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buffer := &bytes.Buffer{}
+			require.NoError(t, NewProgressWriter(buffer).WriteEvent(tt.event, tt.loadedLatency))
+			assert.Equal(t, tt.want+"\n", buffer.String())
+		})
+	}
+}
@@ -2,11 +2,89 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
+// timeLayouts are the absolute time formats accepted by ParseTime.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// Output types accepted by ParseOutput.
+const (
+	OutputJSON    = "json"
+	OutputJSONL   = "jsonl"
+	OutputCSV     = "csv"
+	OutputInflux  = "influx"
+	OutputWebhook = "webhook"
+)
+
+// rateUnits maps lower-case rate units to their size in bytes per second.
+var rateUnits = map[string]float64{
+	"bps":   1.0 / bitsPerByte,
+	"kbps":  speedtest.Kilobyte / bitsPerByte,
+	"mbps":  speedtest.Megabyte / bitsPerByte,
+	"gbps":  speedtest.Gigabyte / bitsPerByte,
+	"kibps": speedtest.KiB / bitsPerByte,
+	"mibps": speedtest.MiB / bitsPerByte,
+	"gibps": speedtest.GiB / bitsPerByte,
+	"b/s":   speedtest.B,
+	"kb/s":  speedtest.Kilobyte,
+	"mb/s":  speedtest.Megabyte,
+	"gb/s":  speedtest.Gigabyte,
+	"kib/s": speedtest.KiB,
+	"mib/s": speedtest.MiB,
+	"gib/s": speedtest.GiB,
+}
+
+// sizeUnits maps lower-case size units without the trailing "b" to their size in bytes.
+var sizeUnits = map[string]float64{
+	"":   speedtest.B,
+	"k":  speedtest.Kilobyte,
+	"m":  speedtest.Megabyte,
+	"g":  speedtest.Gigabyte,
+	"ki": speedtest.KiB,
+	"mi": speedtest.MiB,
+	"gi": speedtest.GiB,
+}
+
+const (
+	bitsPerByte     = 8
+	maxPercent      = 100
+	defaultRateUnit = "mbps"
+)
+
+var (
+	// ErrInvalidRate indicates a rate string in an unsupported format.
+	ErrInvalidRate = errors.New("invalid rate")
+	// ErrInvalidSize indicates a data volume string in an unsupported format.
+	ErrInvalidSize = errors.New("invalid size")
+	// ErrInvalidPercent indicates a percentage string in an unsupported format.
+	ErrInvalidPercent = errors.New("invalid percentage")
+	// ErrInvalidTime indicates a time string in an unsupported format.
+	ErrInvalidTime = errors.New("invalid time")
+	// ErrInvalidOutput indicates an unsupported output type or target.
+	ErrInvalidOutput = errors.New("invalid output")
+	// ErrInvalidCustomServer indicates a custom server with an unsupported label.
+	ErrInvalidCustomServer = errors.New("invalid custom server")
+	// ErrInvalidStrategy indicates an unsupported server selection strategy.
+	ErrInvalidStrategy = errors.New("invalid selection strategy")
+	// ErrInvalidSortKey indicates an unsupported server list sort key.
+	ErrInvalidSortKey = errors.New("invalid sort key")
+	// ErrInvalidHTTPSMode indicates an unsupported HTTPS mode.
+	ErrInvalidHTTPSMode = errors.New("invalid HTTPS mode")
+)
+
 // ParseUnit parses the unit string to a UnitType.
 func ParseUnit(str string) speedtest.UnitType {
 	str = strings.ToLower(str)
@@ -36,3 +114,232 @@ func ParseProto(str string) speedtest.Proto {
 		return speedtest.HTTP
 	}
 }
+
+// ParseStrategy parses a server selection strategy, empty means the default
+// lowest latency strategy.
+func ParseStrategy(str string) (speedtest.Strategy, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if len(str) == 0 {
+		return speedtest.StrategyLatency, nil
+	}
+
+	strategy := speedtest.Strategy(str)
+	if !slices.Contains(speedtest.Strategies, strategy) {
+		return "", fmt.Errorf(
+			"%w %q: must be one of latency/best-of/median/nearest/probe",
+			ErrInvalidStrategy,
+			str,
+		)
+	}
+
+	return strategy, nil
+}
+
+// ParseSortKey parses a server list sort key, empty means sorting by distance.
+func ParseSortKey(str string) (speedtest.SortKey, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if len(str) == 0 {
+		return speedtest.SortByDistance, nil
+	}
+
+	key := speedtest.SortKey(str)
+	if !slices.Contains(speedtest.SortKeys, key) {
+		return "", fmt.Errorf("%w %q: must be one of distance/latency/name", ErrInvalidSortKey, str)
+	}
+
+	return key, nil
+}
+
+// ParseHTTPSMode parses an HTTPS mode, empty means off.
+func ParseHTTPSMode(str string) (speedtest.HTTPSMode, error) {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "", "off":
+		return speedtest.HTTPSOff, nil
+	case "prefer":
+		return speedtest.HTTPSPrefer, nil
+	case "require":
+		return speedtest.HTTPSRequire, nil
+	default:
+		return "", fmt.Errorf("%w %q: must be one of off/prefer/require", ErrInvalidHTTPSMode, str)
+	}
+}
+
+// ParseTime parses an absolute time (RFC3339, "2006-01-02 15:04[:05]" or
+// "2006-01-02" in local time) or a duration relative to now such as "36h" or "7d".
+func ParseTime(str string, now time.Time) (time.Time, error) {
+	str = strings.TrimSpace(str)
+
+	for _, layout := range timeLayouts {
+		parsed, err := time.ParseInLocation(layout, str, now.Location())
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err == nil && count >= 0 {
+			return now.AddDate(0, 0, -count), nil
+		}
+	}
+
+	duration, err := time.ParseDuration(str)
+	if err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w %q: use a date, RFC3339 time or duration like 24h or 7d",
+		ErrInvalidTime, str)
+}
+
+// ParseOutput parses an output specification of the form type[:target], where
+// type is json, jsonl, csv, influx or webhook. The target is split at the first
+// colon, an empty target or "-" means stdout and webhooks require an http(s) URL.
+func ParseOutput(str string) (string, string, error) {
+	outputType, target, _ := strings.Cut(str, ":")
+	outputType = strings.ToLower(strings.TrimSpace(outputType))
+
+	switch outputType {
+	case OutputJSON, OutputJSONL, OutputCSV, OutputInflux:
+	case OutputWebhook:
+		if !IsHTTPURL(target) {
+			return "", "", fmt.Errorf("%w %q: webhook requires an http(s) url", ErrInvalidOutput, str)
+		}
+	default:
+		return "", "", fmt.Errorf(
+			"%w %q: type must be one of json/jsonl/csv/influx/webhook",
+			ErrInvalidOutput,
+			str,
+		)
+	}
+
+	if target == "-" {
+		target = ""
+	}
+
+	return outputType, target, nil
+}
+
+// ParseCustomServer parses a custom server specification of the form
+// url[;label=value...], where the labels are name, id and the upload, download
+// and latency paths, e.g. "http://10.0.0.2:8080;name=Lab;download=/files".
+func ParseCustomServer(str string) (speedtest.CustomServerOptions, error) {
+	rawURL, labels, _ := strings.Cut(str, ";")
+	options := speedtest.CustomServerOptions{URL: strings.TrimSpace(rawURL)}
+
+	if len(options.URL) == 0 {
+		return options, fmt.Errorf("%w %q: missing url", ErrInvalidCustomServer, str)
+	}
+
+	for label := range strings.SplitSeq(labels, ";") {
+		if len(strings.TrimSpace(label)) == 0 {
+			continue
+		}
+
+		key, value, _ := strings.Cut(label, "=")
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "name":
+			options.Name = value
+		case "id":
+			options.ID = value
+		case "upload":
+			options.UploadPath = value
+		case "download":
+			options.DownloadPath = value
+		case "latency":
+			options.LatencyPath = value
+		default:
+			return options, fmt.Errorf(
+				"%w %q: label must be one of name/id/upload/download/latency",
+				ErrInvalidCustomServer,
+				str,
+			)
+		}
+	}
+
+	return options, nil
+}
+
+// IsHTTPURL reports whether the target is an http or https URL.
+func IsHTTPURL(target string) bool {
+	lower := strings.ToLower(target)
+
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// ParseRate parses a rate like "500Mbps", "1.5 Gbps" or "50MB/s" to a ByteRate.
+// Units ending with "bps" are bits, "B/s" or "Bps" are bytes, and numbers without
+// a unit are Mbps.
+func ParseRate(str string) (speedtest.ByteRate, error) {
+	str = strings.TrimSpace(str)
+
+	value, unit, ok := splitUnit(str)
+	if !ok {
+		return 0, fmt.Errorf("%w %q: use a number with a unit like 500Mbps or 50MB/s",
+			ErrInvalidRate, str)
+	}
+
+	if prefix, ok := strings.CutSuffix(unit, "Bps"); ok {
+		unit = prefix + "b/s" // bytes, not to be confused with bits once lower-cased
+	}
+
+	unit = strings.ToLower(unit)
+	if len(unit) == 0 {
+		unit = defaultRateUnit
+	}
+
+	size, ok := rateUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("%w %q: unknown unit %q", ErrInvalidRate, str, unit)
+	}
+
+	return speedtest.ByteRate(value * size), nil
+}
+
+// ParseSize parses a data volume like "100MB", "1.5 GiB" or "500k" to bytes.
+// Units are case-insensitive and always bytes, numbers without a unit are bytes.
+func ParseSize(str string) (int64, error) {
+	str = strings.TrimSpace(str)
+
+	value, unit, ok := splitUnit(str)
+	if !ok {
+		return 0, fmt.Errorf("%w %q: use a number with a unit like 100MB or 1GiB",
+			ErrInvalidSize, str)
+	}
+
+	size, ok := sizeUnits[strings.TrimSuffix(strings.ToLower(unit), "b")]
+	if !ok {
+		return 0, fmt.Errorf("%w %q: unknown unit %q", ErrInvalidSize, str, unit)
+	}
+
+	return int64(value * size), nil
+}
+
+// splitUnit splits a non-negative number from the unit following it.
+func splitUnit(str string) (float64, string, bool) {
+	index := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if index < 0 {
+		index = len(str)
+	}
+
+	value, err := strconv.ParseFloat(str[:index], 64)
+	if err != nil || value < 0 {
+		return 0, "", false
+	}
+
+	return value, strings.TrimSpace(str[index:]), true
+}
+
+// ParsePercent parses a percentage like "1%" or "0.5" in the range 0 to 100.
+func ParsePercent(str string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(str), "%"), 64)
+	if err != nil || value < 0 || value > maxPercent {
+		return 0, fmt.Errorf("%w %q: use a number between 0 and 100", ErrInvalidPercent, str)
+	}
+
+	return value, nil
+}
@@ -2,8 +2,10 @@ package parser
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
@@ -89,3 +91,384 @@ func TestParseProto(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	type args struct {
+		str string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "rfc3339",
+			args: args{str: "2026-03-01T08:30:00Z"},
+			want: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "date and time",
+			args: args{str: "2026-03-01 08:30"},
+			want: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "date",
+			args: args{str: "2026-03-01"},
+			want: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "hours ago",
+			args: args{str: "36h"},
+			want: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "days ago",
+			args: args{str: "7d"},
+			want: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "negative duration",
+			args:    args{str: "-1h"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			args:    args{str: "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseTime(tt.args.str, now)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidTime)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	type args struct {
+		str string
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		wantType   string
+		wantTarget string
+		wantErr    bool
+	}{
+		{
+			name:     "stdout",
+			args:     args{str: "jsonl"},
+			wantType: OutputJSONL,
+		},
+		{
+			name:     "dash is stdout",
+			args:     args{str: "CSV:-"},
+			wantType: OutputCSV,
+		},
+		{
+			name:       "file",
+			args:       args{str: "csv:C:\\results.csv"},
+			wantType:   OutputCSV,
+			wantTarget: "C:\\results.csv",
+		},
+		{
+			name:       "influx url",
+			args:       args{str: "influx:http://localhost:8086/api/v2/write?bucket=net"},
+			wantType:   OutputInflux,
+			wantTarget: "http://localhost:8086/api/v2/write?bucket=net",
+		},
+		{
+			name:       "webhook",
+			args:       args{str: "webhook:https://example.com/hook"},
+			wantType:   OutputWebhook,
+			wantTarget: "https://example.com/hook",
+		},
+		{
+			name:    "webhook without url",
+			args:    args{str: "webhook:hook.json"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			args:    args{str: "xml:out.xml"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotType, gotTarget, err := ParseOutput(tt.args.str)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidOutput)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, gotType)
+			assert.Equal(t, tt.wantTarget, gotTarget)
+		})
+	}
+}
+
+func TestParseCustomServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    speedtest.CustomServerOptions
+		wantErr bool
+	}{
+		{
+			name: "url only",
+			str:  "http://10.0.0.2:8080",
+			want: speedtest.CustomServerOptions{URL: "http://10.0.0.2:8080"},
+		},
+		{
+			name: "labels",
+			str:  "https://lab.example.com; name=Lab A ;id=lab;upload=/up.php;download=/files;latency=/ping.txt;",
+			want: speedtest.CustomServerOptions{
+				URL:          "https://lab.example.com",
+				ID:           "lab",
+				Name:         "Lab A",
+				UploadPath:   "/up.php",
+				DownloadPath: "/files",
+				LatencyPath:  "/ping.txt",
+			},
+		},
+		{
+			name:    "missing url",
+			str:     ";name=Lab",
+			wantErr: true,
+		},
+		{
+			name:    "unknown label",
+			str:     "http://10.0.0.2;sponsor=me",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseCustomServer(tt.str)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidCustomServer)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		str     string
+		want    speedtest.Strategy
+		wantErr bool
+	}{
+		{str: "", want: speedtest.StrategyLatency},
+		{str: "Median", want: speedtest.StrategyMedian},
+		{str: " best-of ", want: speedtest.StrategyBestOf},
+		{str: "probe", want: speedtest.StrategyProbe},
+		{str: "fastest", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStrategy(tt.str)
+		if tt.wantErr {
+			require.ErrorIs(t, err, ErrInvalidStrategy, tt.str)
+
+			continue
+		}
+
+		require.NoError(t, err, tt.str)
+		assert.Equal(t, tt.want, got, tt.str)
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		str     string
+		want    speedtest.SortKey
+		wantErr bool
+	}{
+		{str: "", want: speedtest.SortByDistance},
+		{str: "Latency", want: speedtest.SortByLatency},
+		{str: " name ", want: speedtest.SortByName},
+		{str: "sponsor", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSortKey(tt.str)
+		if tt.wantErr {
+			require.ErrorIs(t, err, ErrInvalidSortKey, tt.str)
+
+			continue
+		}
+
+		require.NoError(t, err, tt.str)
+		assert.Equal(t, tt.want, got, tt.str)
+	}
+}
+
+func TestParseHTTPSMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		str     string
+		want    speedtest.HTTPSMode
+		wantErr bool
+	}{
+		{str: "", want: speedtest.HTTPSOff},
+		{str: "off", want: speedtest.HTTPSOff},
+		{str: " Prefer ", want: speedtest.HTTPSPrefer},
+		{str: "require", want: speedtest.HTTPSRequire},
+		{str: "always", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHTTPSMode(tt.str)
+		if tt.wantErr {
+			require.ErrorIs(t, err, ErrInvalidHTTPSMode, tt.str)
+
+			continue
+		}
+
+		require.NoError(t, err, tt.str)
+		assert.Equal(t, tt.want, got, tt.str)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	type args struct {
+		str string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    speedtest.ByteRate
+		wantErr bool
+	}{
+		{name: "mbps", args: args{str: "500Mbps"}, want: 62500000},
+		{name: "gbps with space", args: args{str: "1.5 Gbps"}, want: 187500000},
+		{name: "kbps", args: args{str: "800kbps"}, want: 100000},
+		{name: "bps", args: args{str: "8bps"}, want: 1},
+		{name: "binary bits", args: args{str: "8Mibps"}, want: speedtest.MiB},
+		{name: "bytes", args: args{str: "50MB/s"}, want: 50 * speedtest.Megabyte},
+		{name: "bytes short", args: args{str: "50MBps"}, want: 50 * speedtest.Megabyte},
+		{name: "binary bytes", args: args{str: "2GiB/s"}, want: 2 * speedtest.GiB},
+		{name: "default unit", args: args{str: "100"}, want: 12500000},
+		{name: "unknown unit", args: args{str: "100mph"}, wantErr: true},
+		{name: "no number", args: args{str: "Mbps"}, wantErr: true},
+		{name: "empty", args: args{str: ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseRate(tt.args.str)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidRate)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, float64(tt.want), float64(got), 1e-6)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	type args struct {
+		str string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    int64
+		wantErr bool
+	}{
+		{name: "megabytes", args: args{str: "100MB"}, want: 100 * speedtest.Megabyte},
+		{name: "gibibytes with space", args: args{str: "1.5 GiB"}, want: 1.5 * speedtest.GiB},
+		{name: "short unit", args: args{str: "500k"}, want: 500 * speedtest.Kilobyte},
+		{name: "lower case", args: args{str: "2mib"}, want: 2 * speedtest.MiB},
+		{name: "bytes", args: args{str: "1024B"}, want: 1024},
+		{name: "no unit", args: args{str: "4096"}, want: 4096},
+		{name: "unknown unit", args: args{str: "1TB"}, wantErr: true},
+		{name: "negative", args: args{str: "-1MB"}, wantErr: true},
+		{name: "empty", args: args{str: ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseSize(tt.args.str)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSize)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	type args struct {
+		str string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    float64
+		wantErr bool
+	}{
+		{name: "with sign", args: args{str: "1%"}, want: 1},
+		{name: "without sign", args: args{str: "0.5"}, want: 0.5},
+		{name: "zero", args: args{str: "0%"}, want: 0},
+		{name: "above hundred", args: args{str: "101%"}, wantErr: true},
+		{name: "negative", args: args{str: "-1%"}, wantErr: true},
+		{name: "invalid", args: args{str: "low"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParsePercent(tt.args.str)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidPercent)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 0)
+		})
+	}
+}
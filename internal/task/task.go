@@ -7,8 +7,13 @@ import (
 	"strings"
 
 	"github.com/chelnak/ysmrr"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
+// FatalExitCode is the exit code used by CheckError when a task fails.
+const FatalExitCode = 2
+
 // Manager manages tasks with spinners for CLI output.
 type Manager struct {
 	sm         ysmrr.SpinnerManager
@@ -124,6 +129,17 @@ func (t *Task) Update(format string) {
 	t.spinner.UpdateMessage(format)
 }
 
+// Observer returns an observer updating the message of the task with the events
+// formatted by format. Events formatted to an empty message are ignored.
+func (t *Task) Observer(format func(event speedtest.Event) string) speedtest.Observer {
+	return speedtest.ObserverFunc(func(event speedtest.Event) {
+		message := format(event)
+		if len(message) > 0 {
+			t.Update(message)
+		}
+	})
+}
+
 // Println prints a message.
 func (t *Task) Println(message string) {
 	if t.manager.noProgress {
@@ -154,7 +170,17 @@ func (t *Task) Printf(format string, args ...any) {
 	t.spinner.UpdateMessagef(format, args...)
 }
 
-// CheckError checks for error and exits if present.
+// Fail marks the task as failed with the error. Unlike CheckError it does not
+// exit, and nothing is printed for the machine readable outputs.
+func (t *Task) Fail(err error) {
+	t.Printf("Failed: %s, err: %v", strings.ToLower(t.title), err)
+
+	if t.spinner != nil {
+		t.spinner.Error()
+	}
+}
+
+// CheckError checks for error and exits with FatalExitCode if present.
 func (t *Task) CheckError(err error) {
 	if err != nil {
 		if t.spinner != nil {
@@ -162,9 +188,9 @@ func (t *Task) CheckError(err error) {
 			t.spinner.Error()
 			t.manager.Stop()
 		} else {
-			_, _ = fmt.Fprintf(os.Stdout, "Fatal: %s, err: %v", strings.ToLower(t.title), err)
+			_, _ = fmt.Fprintf(os.Stdout, "Fatal: %s, err: %v\n", strings.ToLower(t.title), err)
 		}
 
-		os.Exit(0)
+		os.Exit(FatalExitCode)
 	}
 }
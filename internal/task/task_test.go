@@ -1,9 +1,14 @@
 package task
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/chelnak/ysmrr"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
 )
 
 func TestNewManager(t *testing.T) {
@@ -279,6 +284,29 @@ func TestTask_Update(t *testing.T) {
 	}
 }
 
+func TestTask_Observer(t *testing.T) {
+	t.Parallel()
+
+	tr := &Task{
+		manager: NewManager(false, false),
+		spinner: ysmrr.NewSpinnerManager().AddSpinner("start"),
+	}
+
+	observer := tr.Observer(func(event speedtest.Event) string {
+		if event.Type != speedtest.EventPingSample {
+			return ""
+		}
+
+		return event.Latency.String()
+	})
+
+	observer.OnEvent(speedtest.Event{Type: speedtest.EventPingSample, Latency: 5 * time.Millisecond})
+	assert.Equal(t, "5ms", tr.spinner.GetMessage())
+
+	observer.OnEvent(speedtest.Event{Type: speedtest.EventRateSample})
+	assert.Equal(t, "5ms", tr.spinner.GetMessage())
+}
+
 func TestTask_Println(t *testing.T) {
 	type args struct {
 		message string
@@ -354,3 +382,30 @@ func TestTask_CheckError(t *testing.T) {
 		})
 	}
 }
+
+func TestTask_Fail(t *testing.T) {
+	tests := []struct {
+		name string
+		tr   *Task
+	}{
+		{
+			name: "fail with spinner",
+			tr:   &Task{manager: NewManager(false, false), title: "test"},
+		},
+		{
+			name: "fail without output",
+			tr:   &Task{manager: NewManager(true, false), title: "test"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tt.tr.manager.isOut {
+				tt.tr.spinner = tt.tr.manager.sm.AddSpinner(tt.tr.title)
+			}
+
+			assert.NotPanics(t, func() { tt.tr.Fail(errors.New("refused")) })
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package threshold checks speedtest results against minimum and maximum limits.
+package threshold
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+)
+
+const (
+	notMeasured = "N/A"
+	// lossEpsilon absorbs floating point errors of the packet loss ratio,
+	// e.g. a loss of 1 in 100 packets must satisfy a 1% limit.
+	lossEpsilon = 1e-9
+)
+
+// Thresholds are the limits a tested server must satisfy. Zero values disable a check.
+type Thresholds struct {
+	MinDownload   speedtest.ByteRate
+	MinUpload     speedtest.ByteRate
+	MaxLatency    time.Duration
+	MaxJitter     time.Duration
+	MaxPacketLoss *float64 // percent, nil disables the check since 0% is a valid limit
+
+	Unit speedtest.UnitType // unit the rates of the violations are formatted in
+}
+
+// Enabled reports whether any threshold is set.
+func (t Thresholds) Enabled() bool {
+	return t.MinDownload > 0 || t.MinUpload > 0 || t.MaxLatency > 0 || t.MaxJitter > 0 ||
+		t.MaxPacketLoss != nil
+}
+
+// Violation describes a result that does not satisfy a threshold.
+type Violation struct {
+	Metric string // e.g. "download"
+	Actual string // measured value, N/A when it was not measured
+	Limit  string // the limit including its comparison, e.g. ">= 500.00 Mbps"
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s, want %s", v.Metric, v.Actual, v.Limit)
+}
+
+// Check returns the thresholds violated by the server. A metric that was not
+// measured violates its threshold, since it cannot be asserted.
+func (t Thresholds) Check(server *speedtest.Server) []Violation {
+	var violations []Violation
+
+	if t.MinDownload > 0 && server.DLSpeed < t.MinDownload {
+		violations = append(violations, Violation{
+			Metric: "download",
+			Actual: t.rateString(server.DLSpeed),
+			Limit:  ">= " + t.MinDownload.Format(t.Unit),
+		})
+	}
+
+	if t.MinUpload > 0 && server.ULSpeed < t.MinUpload {
+		violations = append(violations, Violation{
+			Metric: "upload",
+			Actual: t.rateString(server.ULSpeed),
+			Limit:  ">= " + t.MinUpload.Format(t.Unit),
+		})
+	}
+
+	if t.MaxLatency > 0 && (server.Latency <= 0 || server.Latency > t.MaxLatency) {
+		violations = append(violations, Violation{
+			Metric: "latency",
+			Actual: durationString(server.Latency),
+			Limit:  "<= " + t.MaxLatency.String(),
+		})
+	}
+
+	if t.MaxJitter > 0 && (server.Latency <= 0 || server.Jitter > t.MaxJitter) {
+		violations = append(violations, Violation{
+			Metric: "jitter",
+			Actual: durationString(server.Jitter),
+			Limit:  "<= " + t.MaxJitter.String(),
+		})
+	}
+
+	if t.MaxPacketLoss != nil {
+		loss := server.PacketLoss.LossPercent()
+		if loss < 0 || loss > *t.MaxPacketLoss+lossEpsilon {
+			violations = append(violations, Violation{
+				Metric: "packet loss",
+				Actual: percentString(loss),
+				Limit:  "<= " + percentString(*t.MaxPacketLoss),
+			})
+		}
+	}
+
+	return violations
+}
+
+func (t Thresholds) rateString(rate speedtest.ByteRate) string {
+	if rate <= 0 {
+		return notMeasured
+	}
+
+	return rate.Format(t.Unit)
+}
+
+func durationString(duration time.Duration) string {
+	if duration <= 0 {
+		return notMeasured
+	}
+
+	return duration.String()
+}
+
+func percentString(percent float64) string {
+	if percent < 0 {
+		return notMeasured
+	}
+
+	return fmt.Sprintf("%.2f%%", percent)
+}
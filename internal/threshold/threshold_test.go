@@ -0,0 +1,88 @@
+package threshold
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest"
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+func percent(value float64) *float64 {
+	return &value
+}
+
+func TestThresholds_Enabled(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, Thresholds{}.Enabled())
+	assert.True(t, Thresholds{MaxJitter: time.Millisecond}.Enabled())
+	assert.True(t, Thresholds{MaxPacketLoss: percent(0)}.Enabled())
+}
+
+func TestThresholds_Check(t *testing.T) {
+	t.Parallel()
+
+	server := &speedtest.Server{
+		DLSpeed:    62500000, // 500 Mbps
+		ULSpeed:    -1,       // N/A
+		Latency:    20 * time.Millisecond,
+		Jitter:     5 * time.Millisecond,
+		PacketLoss: transport.PLoss{Sent: 99, Dup: 0, Max: 99},
+	}
+
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		want       []Violation
+	}{
+		{
+			name:       "no thresholds",
+			thresholds: Thresholds{},
+		},
+		{
+			name: "all satisfied",
+			thresholds: Thresholds{
+				MinDownload:   62500000,
+				MaxLatency:    20 * time.Millisecond,
+				MaxJitter:     10 * time.Millisecond,
+				MaxPacketLoss: percent(1),
+				Unit:          speedtest.UnitTypeDefaultMbps,
+			},
+		},
+		{
+			name: "all violated",
+			thresholds: Thresholds{
+				MinDownload:   125000000,
+				MinUpload:     1,
+				MaxLatency:    10 * time.Millisecond,
+				MaxJitter:     time.Millisecond,
+				MaxPacketLoss: percent(0.5),
+				Unit:          speedtest.UnitTypeDefaultMbps,
+			},
+			want: []Violation{
+				{Metric: "download", Actual: "500.00 Mbps", Limit: ">= 1000.00 Mbps"},
+				{Metric: "upload", Actual: "N/A", Limit: ">= 0.00 Mbps"},
+				{Metric: "latency", Actual: "20ms", Limit: "<= 10ms"},
+				{Metric: "jitter", Actual: "5ms", Limit: "<= 1ms"},
+				{Metric: "packet loss", Actual: "1.00%", Limit: "<= 0.50%"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.thresholds.Check(server))
+		})
+	}
+}
+
+func TestThresholds_CheckNotMeasured(t *testing.T) {
+	t.Parallel()
+
+	violations := Thresholds{MaxPacketLoss: percent(100)}.Check(&speedtest.Server{})
+	assert.Equal(t, []Violation{{Metric: "packet loss", Actual: "N/A", Limit: "<= 100.00%"}}, violations)
+	assert.Equal(t, "packet loss N/A, want <= 100.00%", violations[0].String())
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/nicholas-fedor/speedtest-go/cmd"
+	"github.com/nicholas-fedor/speedtest-go/internal/app"
 )
 
 func main() {
@@ -12,6 +13,6 @@ func main() {
 
 	err := cmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(app.ExitCode(err))
 	}
 }
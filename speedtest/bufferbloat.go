@@ -0,0 +1,276 @@
+package speedtest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction is the direction of a transfer test.
+type Direction int
+
+const (
+	// DirectionDownload is the download direction.
+	DirectionDownload Direction = iota
+	// DirectionUpload is the upload direction.
+	DirectionUpload
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionDownload:
+		return "download"
+	case DirectionUpload:
+		return "upload"
+	default:
+		return fmt.Sprintf("Direction(%d)", int(d))
+	}
+}
+
+// Bufferbloat grades, from best to worst.
+const (
+	GradeAPlus = "A+"
+	GradeA     = "A"
+	GradeB     = "B"
+	GradeC     = "C"
+	GradeD     = "D"
+	GradeF     = "F"
+)
+
+// grades are the bufferbloat grades ordered from best to worst.
+var grades = []string{GradeAPlus, GradeA, GradeB, GradeC, GradeD, GradeF}
+
+// gradeLimits are the upper bounds of the latency increase under load for every
+// grade but F, the same bounds as the common web based bufferbloat tests.
+var gradeLimits = []struct {
+	limit time.Duration
+	grade string
+}{
+	{5 * time.Millisecond, GradeAPlus},
+	{30 * time.Millisecond, GradeA},
+	{60 * time.Millisecond, GradeB},
+	{200 * time.Millisecond, GradeC},
+	{400 * time.Millisecond, GradeD},
+}
+
+const (
+	p50 = 0.50
+	p95 = 0.95
+
+	// loadedLatencyInterval is the interval the latency is sampled at during
+	// the transfer tests.
+	loadedLatencyInterval = 500 * time.Millisecond
+	// loadedLatencyPings is the number of pings of a transfer phase, which in
+	// fact end with the phase.
+	loadedLatencyPings = math.MaxInt32
+)
+
+// LoadedLatency is the latency measured while a transfer test saturates the link.
+type LoadedLatency struct {
+	Samples  int           `json:"samples"`
+	Mean     time.Duration `json:"mean"`
+	Jitter   time.Duration `json:"jitter"`
+	Min      time.Duration `json:"min"`
+	Max      time.Duration `json:"max"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	Increase time.Duration `json:"increase"` // mean loaded latency minus the idle latency
+	Grade    string        `json:"grade"`
+}
+
+// Bufferbloat compares the idle latency of a server with its latency under load.
+type Bufferbloat struct {
+	Idle     time.Duration  `json:"idle"`
+	Download *LoadedLatency `json:"download,omitempty"`
+	Upload   *LoadedLatency `json:"upload,omitempty"`
+	Grade    string         `json:"grade"` // the worse grade of both directions
+}
+
+// GradeBufferbloat grades a latency increase under load from A+ to F.
+func GradeBufferbloat(increase time.Duration) string {
+	for _, limit := range gradeLimits {
+		if increase < limit.limit {
+			return limit.grade
+		}
+	}
+
+	return GradeF
+}
+
+// NewLoadedLatency computes the statistics of latencies in nanoseconds measured
+// under load against the idle latency. It returns nil without latencies.
+func NewLoadedLatency(idle time.Duration, latencies []int64) *LoadedLatency {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	mean, _, stdDev, minVal, maxVal := StandardDeviation(latencies)
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	increase := max(time.Duration(mean)-idle, 0)
+
+	return &LoadedLatency{
+		Samples:  len(latencies),
+		Mean:     time.Duration(mean),
+		Jitter:   time.Duration(stdDev),
+		Min:      time.Duration(minVal),
+		Max:      time.Duration(maxVal),
+		P50:      time.Duration(Percentile(sorted, p50)),
+		P95:      time.Duration(Percentile(sorted, p95)),
+		Increase: increase,
+		Grade:    GradeBufferbloat(increase),
+	}
+}
+
+// Loaded returns the latency under load of the given direction, or nil if it
+// was not measured.
+func (b *Bufferbloat) Loaded(direction Direction) *LoadedLatency {
+	if b == nil {
+		return nil
+	}
+
+	switch direction {
+	case DirectionDownload:
+		return b.Download
+	case DirectionUpload:
+		return b.Upload
+	default:
+		return nil
+	}
+}
+
+// recordLoadedLatency records the latencies in nanoseconds measured during the
+// transfer test of the given direction and updates the bufferbloat grade. The
+// idle latency is the latency of the ping test, so nothing is recorded unless
+// it ran first.
+func (s *Server) recordLoadedLatency(direction Direction, latencies []int64) *LoadedLatency {
+	if s.Latency == 0 {
+		return nil
+	}
+
+	loaded := NewLoadedLatency(s.Latency, latencies)
+	if loaded == nil {
+		return nil
+	}
+
+	if s.Bufferbloat == nil {
+		s.Bufferbloat = &Bufferbloat{}
+	}
+
+	s.Bufferbloat.Idle = s.Latency
+
+	switch direction {
+	case DirectionDownload:
+		s.Bufferbloat.Download = loaded
+	case DirectionUpload:
+		s.Bufferbloat.Upload = loaded
+	}
+
+	s.Bufferbloat.Grade = worseGrade(s.Bufferbloat.Download, s.Bufferbloat.Upload)
+
+	return loaded
+}
+
+// latencySampler pings a server while a transfer test saturates the link, with
+// the ping mode of the idle latency so that both are comparable.
+type latencySampler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	latencies []int64
+	samples   []LatencySample
+}
+
+// sampleLoadedLatency pings the server every loadedLatencyInterval until the
+// sampler is stopped or ctx, the context of the transfer phase, is done. The
+// pings of a phase share one connection, so that every sample is a single round
+// trip. Every latency is sent as a ping sample of the phase.
+func (s *Server) sampleLoadedLatency(ctx context.Context) *latencySampler {
+	// the pings are not requests of the transfer, so they leave its phase
+	// timings and connection info alone.
+	pingCtx, cancel := context.WithCancel(context.Background())
+	stopAfter := context.AfterFunc(ctx, cancel)
+	emit := emitterFrom(ctx)
+
+	sampler := &latencySampler{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(sampler.done)
+		defer stopAfter()
+
+		sleepContext(pingCtx, loadedLatencyInterval)
+
+		_, _ = s.ping(pingCtx, loadedLatencyPings, loadedLatencyInterval, func(latency time.Duration) {
+			sampler.mu.Lock()
+			sampler.latencies = append(sampler.latencies, latency.Nanoseconds())
+			sampler.samples = append(sampler.samples, LatencySample{Time: time.Now(), Latency: latency})
+			sampler.mu.Unlock()
+
+			emit(Event{Type: EventPingSample, Latency: latency})
+		})
+	}()
+
+	return sampler
+}
+
+// stop stops the pings and records the latencies measured under load in the
+// bufferbloat and the time series of the server.
+func (l *latencySampler) stop(s *Server, direction Direction) {
+	l.cancel()
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s.recordLoadedLatency(direction, l.latencies)
+	s.recordLatencySamples(direction, l.samples)
+}
+
+// String returns the grade and the latency increase per direction.
+func (b *Bufferbloat) String() string {
+	if b == nil {
+		return "Bufferbloat: N/A"
+	}
+
+	parts := make([]string, 0, 2)
+
+	if b.Download != nil {
+		parts = append(parts, "Download: +"+b.Download.Increase.Round(time.Millisecond).String())
+	}
+
+	if b.Upload != nil {
+		parts = append(parts, "Upload: +"+b.Upload.Increase.Round(time.Millisecond).String())
+	}
+
+	return fmt.Sprintf("Bufferbloat: %s (Idle: %v %s)",
+		b.Grade, b.Idle.Round(time.Millisecond), strings.Join(parts, " "))
+}
+
+// worseGrade returns the worse grade of the measured directions.
+func worseGrade(loaded ...*LoadedLatency) string {
+	grade := ""
+
+	for _, current := range loaded {
+		if current != nil && slices.Index(grades, current.Grade) > slices.Index(grades, grade) {
+			grade = current.Grade
+		}
+	}
+
+	return grade
+}
+
+// Percentile returns the nearest-rank percentile p, between 0 and 1, of values
+// sorted in ascending order. values must not be empty.
+func Percentile[T cmp.Ordered](sorted []T, p float64) T {
+	rank := max(int(math.Ceil(p*float64(len(sorted)))), 1)
+
+	return sorted[rank-1]
+}
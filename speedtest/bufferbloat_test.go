@@ -0,0 +1,224 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
+)
+
+func TestGradeBufferbloat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		increase time.Duration
+		want     string
+	}{
+		{0, GradeAPlus},
+		{4 * time.Millisecond, GradeAPlus},
+		{5 * time.Millisecond, GradeA},
+		{29 * time.Millisecond, GradeA},
+		{30 * time.Millisecond, GradeB},
+		{60 * time.Millisecond, GradeC},
+		{200 * time.Millisecond, GradeD},
+		{400 * time.Millisecond, GradeF},
+		{time.Second, GradeF},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, GradeBufferbloat(tt.increase), tt.increase.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{name: "single", values: []float64{3}, p: 0.95, want: 3},
+		{name: "nearest rank", values: []float64{1, 2, 3, 4, 5}, p: 0.95, want: 5},
+		{name: "median", values: []float64{1, 2, 3, 4}, p: 0.5, want: 2},
+		{name: "lowest", values: []float64{1, 2, 3}, p: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.InDelta(t, tt.want, Percentile(tt.values, tt.p), 0)
+		})
+	}
+
+	assert.Equal(t, int64(20), Percentile([]int64{10, 20, 30}, 0.5))
+}
+
+func TestNewLoadedLatency(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, NewLoadedLatency(10*time.Millisecond, nil))
+
+	latencies := make([]int64, 0, 20)
+	for i := 1; i <= 20; i++ {
+		latencies = append(latencies, int64(i*5*int(time.Millisecond)))
+	}
+
+	loaded := NewLoadedLatency(10*time.Millisecond, latencies)
+	require.NotNil(t, loaded)
+	assert.Equal(t, 20, loaded.Samples)
+	assert.Equal(t, 52500*time.Microsecond, loaded.Mean)
+	assert.Equal(t, 5*time.Millisecond, loaded.Min)
+	assert.Equal(t, 100*time.Millisecond, loaded.Max)
+	assert.Equal(t, 50*time.Millisecond, loaded.P50)
+	assert.Equal(t, 95*time.Millisecond, loaded.P95)
+	assert.Equal(t, 42500*time.Microsecond, loaded.Increase)
+	assert.Equal(t, GradeB, loaded.Grade)
+
+	// a loaded latency below the idle latency is no increase.
+	loaded = NewLoadedLatency(time.Second, latencies)
+	require.NotNil(t, loaded)
+	assert.Equal(t, time.Duration(0), loaded.Increase)
+	assert.Equal(t, GradeAPlus, loaded.Grade)
+}
+
+func TestServer_recordLoadedLatency(t *testing.T) {
+	t.Parallel()
+
+	ms := int64(time.Millisecond)
+
+	// without the idle latency there is nothing to compare with.
+	server := &Server{ID: "1"}
+	assert.Nil(t, server.recordLoadedLatency(DirectionDownload, []int64{12 * ms}))
+	assert.Nil(t, server.Bufferbloat)
+
+	server.Latency = 10 * time.Millisecond
+	assert.Nil(t, server.recordLoadedLatency(DirectionDownload, nil))
+	assert.Nil(t, server.Bufferbloat)
+
+	server.recordLoadedLatency(DirectionDownload, []int64{12 * ms, 14 * ms})
+	require.NotNil(t, server.Bufferbloat)
+	assert.Equal(t, 10*time.Millisecond, server.Bufferbloat.Idle)
+	assert.Equal(t, GradeAPlus, server.Bufferbloat.Grade)
+	assert.Nil(t, server.Bufferbloat.Upload)
+	assert.Nil(t, server.Bufferbloat.Loaded(DirectionUpload))
+
+	server.recordLoadedLatency(DirectionUpload, []int64{200 * ms, 300 * ms})
+	assert.Equal(t, GradeD, server.Bufferbloat.Upload.Grade)
+	assert.Equal(t, GradeD, server.Bufferbloat.Grade)
+	assert.Equal(t, server.Bufferbloat.Upload, server.Bufferbloat.Loaded(DirectionUpload))
+	assert.Equal(t, "Bufferbloat: D (Idle: 10ms Download: +3ms Upload: +240ms)", server.Bufferbloat.String())
+
+	data, err := json.Marshal(server)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"bufferbloat":{"idle":10000000,"download":{"samples":2,`)
+	assert.Contains(t, string(data), `"grade":"D"}`)
+}
+
+func TestServer_sampleLoadedLatency(t *testing.T) {
+	t.Parallel()
+
+	// the latency under load is measured like the idle latency.
+	tests := []struct {
+		name     string
+		pingMode Proto
+	}{
+		{name: "HTTP", pingMode: HTTP},
+		{name: "TCP", pingMode: TCP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := startLocalServer(t)
+			recorder := &eventRecorder{}
+
+			client := New(WithUserConfig(&UserConfig{
+				PingMode:       tt.pingMode,
+				MaxConnections: 1,
+				MinDuration:    2 * time.Second,
+				MaxDuration:    3 * time.Second,
+				TimeSeries:     true,
+			}), WithObserver(recorder))
+
+			target, err := client.CustomServer("http://" + host)
+			require.NoError(t, err)
+			require.NoError(t, target.PingTest(nil))
+			require.NoError(t, target.DownloadTest())
+
+			require.NotNil(t, target.Bufferbloat)
+			assert.Equal(t, target.Latency, target.Bufferbloat.Idle)
+			require.NotNil(t, target.Bufferbloat.Download)
+			assert.Nil(t, target.Bufferbloat.Upload)
+
+			// every latency under load is sent as a ping sample of the download.
+			loaded := 0
+
+			for _, event := range recorder.of(EventPingSample) {
+				if event.Phase == PhaseDownload {
+					loaded++
+				}
+			}
+
+			assert.Equal(t, target.Bufferbloat.Download.Samples, loaded)
+
+			// and kept in the time series.
+			latencies := target.TimeSeries.Download.Latencies
+			require.Len(t, latencies, loaded)
+			assert.Positive(t, latencies[0].Elapsed)
+		})
+	}
+}
+
+func TestServer_sampleLoadedLatency_requests(t *testing.T) {
+	t.Parallel()
+
+	var pings atomic.Int64
+
+	handler := server.New(nil).Handler()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "latency.txt") {
+			pings.Add(1)
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	client := New(WithUserConfig(&UserConfig{
+		MaxConnections: 1,
+		MinDuration:    2 * time.Second,
+		MaxDuration:    3 * time.Second,
+	}))
+
+	target, err := client.CustomServer(httpServer.URL)
+	require.NoError(t, err)
+	require.NoError(t, target.PingTest(nil))
+
+	pings.Store(0)
+	require.NoError(t, target.DownloadTest())
+
+	// a single request opens the connection of the phase, every sample is a
+	// single round trip on it.
+	require.NotNil(t, target.Bufferbloat.Download)
+	assert.Positive(t, target.Bufferbloat.Download.Samples)
+	assert.Equal(t, int64(target.Bufferbloat.Download.Samples+1), pings.Load())
+}
+
+func TestDirection_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "download", DirectionDownload.String())
+	assert.Equal(t, "upload", DirectionUpload.String())
+	assert.Equal(t, "Direction(5)", Direction(5).String())
+	assert.Equal(t, "Bufferbloat: N/A", (*Bufferbloat)(nil).String())
+	assert.Nil(t, (*Bufferbloat)(nil).Loaded(DirectionDownload))
+}
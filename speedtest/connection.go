@@ -0,0 +1,243 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"sync"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+// ConnectionInfo describes the connections a server was tested over.
+type ConnectionInfo struct {
+	// RemoteAddrs are the distinct addresses connected to, the proxy's when a proxy was used.
+	RemoteAddrs []string `json:"remoteAddrs"`
+	// LocalAddrs are the distinct source addresses of the connections.
+	LocalAddrs []string `json:"localAddrs"`
+	// IPFamily is the family of the remote addresses, empty if both were used.
+	IPFamily IPFamily `json:"ipFamily,omitempty"`
+	// Protocols are the distinct negotiated HTTP protocols, e.g. HTTP/1.1 or HTTP/2.0.
+	Protocols []string `json:"protocols,omitempty"`
+	// TLSVersion is the negotiated TLS version, empty without TLS.
+	TLSVersion string `json:"tlsVersion,omitempty"`
+	// Proxy reports whether the connections were made through a proxy.
+	Proxy bool `json:"proxy"`
+
+	mu sync.Mutex
+}
+
+type connectionInfoKey struct{}
+
+// withConnectionInfo returns a context recording the connections of the
+// requests made with it to the info.
+func withConnectionInfo(ctx context.Context, info *ConnectionInfo) context.Context {
+	return context.WithValue(ctx, connectionInfoKey{}, info)
+}
+
+// connectionInfoFrom returns the info recording the connections of the
+// context, or nil.
+func connectionInfoFrom(ctx context.Context) *ConnectionInfo {
+	info, _ := ctx.Value(connectionInfoKey{}).(*ConnectionInfo)
+
+	return info
+}
+
+// connectionInfo returns the connection info of the server, created on first
+// use. It must not be called concurrently for the same server.
+func (s *Server) connectionInfo() *ConnectionInfo {
+	if s.Connection == nil {
+		s.Connection = &ConnectionInfo{}
+	}
+
+	return s.Connection
+}
+
+// traceConnection returns the request with a trace recording its connection to
+// the info of the request context and sending the connections opened or failed
+// to its emitter, if any.
+func traceConnection(req *http.Request) *http.Request {
+	info := connectionInfoFrom(req.Context())
+	if info == nil && req.Context().Value(emitterKey{}) == nil {
+		return req
+	}
+
+	emit := emitterFrom(req.Context())
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		ConnectDone: func(_, addr string, err error) {
+			if err != nil {
+				emit(Event{Type: EventConnectionFailed, Addr: addr, Err: err})
+			}
+		},
+		GotConn: func(conn httptrace.GotConnInfo) {
+			info.recordAddrs(conn.Conn.LocalAddr(), conn.Conn.RemoteAddr())
+
+			if !conn.Reused {
+				emit(Event{Type: EventConnectionOpened, Addr: conn.Conn.RemoteAddr().String()})
+			}
+		},
+	}))
+}
+
+// recordAddrs records the addresses of a connection.
+func (c *ConnectionInfo) recordAddrs(localAddr, remoteAddr net.Addr) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remote := addrIP(remoteAddr)
+	if len(remote) > 0 && !slices.Contains(c.RemoteAddrs, remote) {
+		family := IPFamilyIPv6
+		if IPFamilyIPv4.allows(remote) {
+			family = IPFamilyIPv4
+		}
+
+		switch {
+		case len(c.RemoteAddrs) == 0:
+			c.IPFamily = family
+		case c.IPFamily != family:
+			c.IPFamily = IPFamilyAny
+		}
+
+		c.RemoteAddrs = append(c.RemoteAddrs, remote)
+	}
+
+	local := addrIP(localAddr)
+	if len(local) > 0 && !slices.Contains(c.LocalAddrs, local) {
+		c.LocalAddrs = append(c.LocalAddrs, local)
+	}
+}
+
+// recordResponse records the protocol and the TLS version of the response.
+func (c *ConnectionInfo) recordResponse(resp *http.Response, proxied bool) {
+	if c == nil || resp == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !slices.Contains(c.Protocols, resp.Proto) {
+		c.Protocols = append(c.Protocols, resp.Proto)
+	}
+
+	if resp.TLS != nil {
+		c.TLSVersion = tls.VersionName(resp.TLS.Version)
+	}
+
+	c.Proxy = c.Proxy || proxied
+}
+
+// recordProxy records whether a connection was made through a proxy.
+func (c *ConnectionInfo) recordProxy(proxied bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Proxy = c.Proxy || proxied
+}
+
+// addrIP returns the IP address of the network address without the port.
+func addrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return host
+}
+
+// transportPool keeps the idle speedtest.net TCP protocol connections of a
+// transfer test, so every worker reuses its connection for the whole test
+// instead of connecting for each chunk.
+type transportPool struct {
+	mu     sync.Mutex
+	idle   map[string][]*transport.Client
+	closed bool
+}
+
+type transportPoolKey struct{}
+
+// withTransportPool returns a context reusing the TCP protocol connections of
+// the transfers made with it, and a function closing them once the test is over.
+func withTransportPool(ctx context.Context) (context.Context, func()) {
+	pool := &transportPool{idle: make(map[string][]*transport.Client)}
+
+	return context.WithValue(ctx, transportPoolKey{}, pool), pool.close
+}
+
+// transportPoolFrom returns the connection pool of the context, or nil.
+func transportPoolFrom(ctx context.Context) *transportPool {
+	pool, _ := ctx.Value(transportPoolKey{}).(*transportPool)
+
+	return pool
+}
+
+// get returns an idle connection to the server, or connects a new one. A nil
+// pool always connects.
+func (p *transportPool) get(ctx context.Context, server *Server) (*transport.Client, error) {
+	if p != nil {
+		p.mu.Lock()
+		idle := p.idle[server.Host]
+
+		if len(idle) > 0 {
+			client := idle[len(idle)-1]
+			p.idle[server.Host] = idle[:len(idle)-1]
+			p.mu.Unlock()
+
+			return client, nil
+		}
+
+		p.mu.Unlock()
+	}
+
+	return server.connectTransport(ctx)
+}
+
+// release keeps the connection to the server for the next transfer if it is
+// reusable, and closes it otherwise or once the pool is closed.
+func (p *transportPool) release(server *Server, client *transport.Client, reusable bool) {
+	if p != nil && reusable {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if !p.closed {
+			p.idle[server.Host] = append(p.idle[server.Host], client)
+
+			return
+		}
+	}
+
+	_ = client.Close()
+}
+
+// close closes the idle connections, the ones released later are closed
+// right away.
+func (p *transportPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	for _, idle := range p.idle {
+		for _, client := range idle {
+			_ = client.Close()
+		}
+	}
+
+	p.idle = nil
+}
@@ -0,0 +1,86 @@
+package speedtest
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionInfo_recordAddrs(t *testing.T) {
+	t.Parallel()
+
+	info := &ConnectionInfo{}
+	local := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 50000}
+
+	info.recordAddrs(local, &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 8080})
+	info.recordAddrs(local, &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 8080})
+	assert.Equal(t, []string{"198.51.100.1"}, info.RemoteAddrs)
+	assert.Equal(t, []string{"192.0.2.10"}, info.LocalAddrs)
+	assert.Equal(t, IPFamilyIPv4, info.IPFamily)
+
+	info.recordAddrs(&net.TCPAddr{IP: net.ParseIP("2001:db8::10")}, &net.TCPAddr{IP: net.ParseIP("2001:db8::1")})
+	assert.Equal(t, []string{"198.51.100.1", "2001:db8::1"}, info.RemoteAddrs)
+	assert.Equal(t, []string{"192.0.2.10", "2001:db8::10"}, info.LocalAddrs)
+	assert.Equal(t, IPFamilyAny, info.IPFamily)
+
+	var missing *ConnectionInfo
+
+	assert.NotPanics(t, func() { missing.recordAddrs(local, nil) })
+}
+
+func TestConnectionInfo_recordResponse(t *testing.T) {
+	t.Parallel()
+
+	info := &ConnectionInfo{}
+
+	info.recordResponse(&http.Response{Proto: "HTTP/1.1"}, false)
+	info.recordResponse(&http.Response{Proto: "HTTP/2.0", TLS: &tls.ConnectionState{Version: tls.VersionTLS13}}, true)
+	info.recordResponse(&http.Response{Proto: "HTTP/1.1"}, false)
+
+	assert.Equal(t, []string{"HTTP/1.1", "HTTP/2.0"}, info.Protocols)
+	assert.Equal(t, "TLS 1.3", info.TLSVersion)
+	assert.True(t, info.Proxy)
+}
+
+func TestServer_Connection(t *testing.T) {
+	host := startLocalServer(t)
+
+	tests := []struct {
+		name string
+		mode Proto
+	}{
+		{name: "http", mode: HTTP},
+		{name: "tcp", mode: TCP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := New(WithUserConfig(&UserConfig{PingMode: tt.mode, TransferMode: tt.mode, MaxConnections: 1}))
+			client.SetCaptureTime(200 * time.Millisecond)
+
+			target, err := client.CustomServer("http://" + host)
+			require.NoError(t, err)
+
+			require.NoError(t, target.PingTest(nil))
+			require.NoError(t, target.DownloadTest())
+			require.NotNil(t, target.Connection)
+
+			assert.Equal(t, []string{"127.0.0.1"}, target.Connection.RemoteAddrs)
+			assert.Equal(t, []string{"127.0.0.1"}, target.Connection.LocalAddrs)
+			assert.Equal(t, IPFamilyIPv4, target.Connection.IPFamily)
+			assert.False(t, target.Connection.Proxy)
+
+			if tt.mode == HTTP {
+				assert.Equal(t, []string{"HTTP/1.1"}, target.Connection.Protocols)
+			} else {
+				assert.Empty(t, target.Connection.Protocols)
+			}
+		})
+	}
+}
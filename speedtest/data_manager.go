@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,8 +15,10 @@ import (
 	"github.com/nicholas-fedor/speedtest-go/speedtest/internal"
 )
 
+// DefaultCaptureTime is the default maximum duration of a download or upload test.
+const DefaultCaptureTime = 15 * time.Second
+
 const (
-	defaultCaptureTime          = 15 * time.Second
 	defaultRateCaptureFrequency = 50 * time.Millisecond
 	welfordWindowSize           = 5 * time.Second
 	conversionFactor            = 1000
@@ -28,6 +31,7 @@ const (
 // Manager defines the interface for managing data chunks and test directions.
 type Manager interface {
 	SetRateCaptureFrequency(duration time.Duration) Manager
+	// SetCaptureTime sets the maximum duration of a download or upload test.
 	SetCaptureTime(duration time.Duration) Manager
 
 	NewChunk() Chunk
@@ -57,6 +61,20 @@ type Manager interface {
 	SetNThread(n int) Manager
 }
 
+// TestLimiter is implemented by managers supporting the limits of the transfer
+// tests besides the capture time, like *DataManager. NewUserConfig applies the
+// limits of the UserConfig to a Manager that implements it.
+type TestLimiter interface {
+	// SetMinDuration sets the duration before which a test is not stopped, even if the rate is stable.
+	SetMinDuration(duration time.Duration) Manager
+	// SetWarmUp sets the period at the start of a test that is excluded from the rate.
+	SetWarmUp(duration time.Duration) Manager
+	// SetStabilityThreshold sets the coefficient of variation below which a test is stopped early.
+	SetStabilityThreshold(cv float64) Manager
+	// SetMaxDataVolume sets the number of bytes after which a test is stopped, per direction.
+	SetMaxDataVolume(bytes int64) Manager
+}
+
 // Chunk defines the interface for data chunks used in speed tests.
 type Chunk interface {
 	UploadHandler(size int64) Chunk
@@ -89,6 +107,10 @@ var (
 	)
 	// ErrFailedGetBufferPool is returned when failing to get buffer from pool.
 	ErrFailedGetBufferPool = errors.New("failed to get buffer from pool")
+
+	// errDataBudget cuts the uploads in flight short once the data budget is
+	// used up, the requests fail with it.
+	errDataBudget = errors.New("data budget used up")
 )
 
 type funcGroup struct {
@@ -109,6 +131,10 @@ type DataManager struct {
 	repeatByte *[]byte
 
 	captureTime          time.Duration
+	minDuration          time.Duration // the test is not stopped earlier, even if the rate is stable
+	warmUp               time.Duration // samples of this period are excluded from the rate
+	stabilityCV          float64       // C.V threshold the test is stopped below
+	maxDataVolume        int64         // data budget of a test in bytes, unlimited if zero
 	rateCaptureFrequency time.Duration
 	nThread              int
 
@@ -117,6 +143,8 @@ type DataManager struct {
 
 	download *TestDirection
 	upload   *TestDirection
+
+	dbg *Debug
 }
 
 // TestDirection represents a direction of test (upload or download) with associated handlers.
@@ -130,6 +158,14 @@ type TestDirection struct {
 	welford         *internal.Welford           // std/EWMA/mean
 	captureCallback func(realTimeRate ByteRate) // user callback
 	closeFunc       func()                      // close func
+	emit            emitter                     // sends the rate samples of the test, set by StartContext
+
+	samplesMu   sync.Mutex
+	startTime   time.Time    // start of the rate capture
+	rateSamples []RateSample // timestamped rate history
+
+	budgetBase    int64       // data volume of the previous tests, outside the budget of this one
+	budgetLimited atomic.Bool // stopped because the data budget was used up
 }
 
 // NewDataManager creates a new DataManager instance with default settings.
@@ -140,10 +176,12 @@ func NewDataManager() *DataManager {
 	) // uniformly distributed sequence of bits
 	ret := &DataManager{
 		nThread:              runtime.NumCPU(),
-		captureTime:          defaultCaptureTime,
+		captureTime:          DefaultCaptureTime,
+		stabilityCV:          internal.DefaultStabilityCV,
 		rateCaptureFrequency: defaultRateCaptureFrequency,
 		Snapshot:             &Snapshot{},
 		repeatByte:           &repeatedData,
+		dbg:                  NewDebug(),
 	}
 	ret.download = ret.NewDataDirection(typeDownload)
 	ret.upload = ret.NewDataDirection(typeUpload)
@@ -218,13 +256,45 @@ func (td *TestDirection) GetTotalDataVolume() int64 {
 	return atomic.LoadInt64(&td.totalDataVolume)
 }
 
-// AddTotalDataVolume adds to the total data volume.
+// AddTotalDataVolume adds to the total data volume and stops the test once the
+// data budget is used up.
 func (td *TestDirection) AddTotalDataVolume(delta int64) int64 {
-	return atomic.AddInt64(&td.totalDataVolume, delta)
+	total := atomic.AddInt64(&td.totalDataVolume, delta)
+	used := total - atomic.LoadInt64(&td.budgetBase)
+
+	budget := td.manager.maxDataVolume
+	if budget > 0 && used >= budget && used-delta < budget {
+		td.budgetLimited.Store(true)
+
+		if td.closeFunc != nil {
+			go td.closeFunc()
+		}
+	}
+
+	return total
+}
+
+// BudgetLimited reports whether the test was stopped because the data budget
+// set by SetMaxDataVolume was used up.
+func (td *TestDirection) BudgetLimited() bool {
+	return td.budgetLimited.Load()
+}
+
+// TestDataVolume returns the data volume transferred by the current or last
+// test of the direction, which the data budget applies to.
+func (td *TestDirection) TestDataVolume() int64 {
+	return td.GetTotalDataVolume() - atomic.LoadInt64(&td.budgetBase)
 }
 
 // Start begins the test direction execution with the given cancel function and main request handler index.
 func (td *TestDirection) Start(cancel context.CancelFunc, mainRequestHandlerIndex int) {
+	td.StartContext(context.Background(), cancel, mainRequestHandlerIndex)
+}
+
+// StartContext is like Start, but stops the test direction early once the
+// context is done. The handlers are stopped and cancel is called as if the
+// capture time had elapsed, so the rate measured so far remains available.
+func (td *TestDirection) StartContext(ctx context.Context, cancel context.CancelFunc, mainRequestHandlerIndex int) {
 	if len(td.fns) == 0 {
 		panic("empty task stack")
 	}
@@ -246,12 +316,17 @@ func (td *TestDirection) Start(cancel context.CancelFunc, mainRequestHandlerInde
 	}
 
 	auxN := td.manager.nThread - mainN
-	dbg.Printf("Available fns: %d\n", len(td.fns))
-	dbg.Printf("mainN: %d\n", mainN)
-	dbg.Printf("auxN: %d\n", auxN)
+	td.manager.dbg.Printf("Available fns: %d\n", len(td.fns))
+	td.manager.dbg.Printf("mainN: %d\n", mainN)
+	td.manager.dbg.Printf("auxN: %d\n", auxN)
+
+	// every test gets the full data budget.
+	atomic.StoreInt64(&td.budgetBase, td.GetTotalDataVolume())
+	td.budgetLimited.Store(false)
 
 	waitGroup := sync.WaitGroup{}
 	td.manager.running = true
+	td.emit = emitterFrom(ctx)
 	stopCapture := td.rateCapture()
 
 	// refresh once function
@@ -265,12 +340,15 @@ func (td *TestDirection) Start(cancel context.CancelFunc, mainRequestHandlerInde
 			td.manager.running = false
 			td.manager.runningRW.Unlock()
 			cancel()
-			dbg.Println("FuncGroup: Stop")
+			td.manager.dbg.Println("FuncGroup: Stop")
 		})
 	}
 
 	time.AfterFunc(td.manager.captureTime, td.closeFunc)
 
+	stopContext := context.AfterFunc(ctx, td.closeFunc)
+	defer stopContext()
+
 	for range mainN {
 		waitGroup.Go(func() {
 			for {
@@ -329,10 +407,22 @@ func (td *TestDirection) rateCapture() chan bool {
 
 	var prevTotalDataVolume int64
 
+	startDataVolume := td.GetTotalDataVolume()
+	emit := td.emit
+
 	stopCapture := make(chan bool)
 	td.welford = internal.NewWelford(welfordWindowSize, td.manager.rateCaptureFrequency)
+	td.welford.SetStabilityThreshold(td.manager.stabilityCV)
 	sTime := time.Now()
 
+	// the average excludes the data transferred during the warm-up.
+	baseTime, baseDataVolume := sTime, int64(0)
+
+	td.samplesMu.Lock()
+	td.startTime = sTime
+	td.rateSamples = nil
+	td.samplesMu.Unlock()
+
 	go func(t *time.Ticker) {
 		defer t.Stop()
 
@@ -346,16 +436,50 @@ func (td *TestDirection) rateCapture() chan bool {
 				if deltaDataVolume != 0 {
 					td.RateSequence = append(td.RateSequence, deltaDataVolume)
 				}
+
+				elapsed := time.Since(sTime)
+				warmUp := elapsed < td.manager.warmUp
+
+				td.addRateSample(sTime, deltaDataVolume, warmUp)
+
+				if warmUp {
+					baseTime, baseDataVolume = time.Now(), newTotalDataVolume
+
+					// the measuring instrument is not fed yet, report the raw rate.
+					rate := td.intervalRate(deltaDataVolume)
+					if td.captureCallback != nil {
+						td.captureCallback(rate)
+					}
+
+					if emit != nil {
+						emit(Event{
+							Type: EventRateSample, Elapsed: elapsed, Rate: rate,
+							Bytes: newTotalDataVolume - startDataVolume,
+						})
+					}
+
+					continue
+				}
+
 				// anyway we update the measuring instrument
-				globalAvg := (float64(td.GetTotalDataVolume())) / float64(
-					time.Since(sTime).Milliseconds(),
+				globalAvg := (float64(newTotalDataVolume - baseDataVolume)) / float64(
+					max(time.Since(baseTime).Milliseconds(), 1),
 				) * conversionFactor
-				if td.welford.Update(globalAvg, float64(deltaDataVolume)) {
+				if td.welford.Update(globalAvg, float64(deltaDataVolume)) &&
+					elapsed >= td.manager.minDuration && td.closeFunc != nil {
 					go td.closeFunc()
 				}
 				// reports the current rate at the given rate
+				rate := ByteRate(td.welford.EWMA())
 				if td.captureCallback != nil {
-					td.captureCallback(ByteRate(td.welford.EWMA()))
+					td.captureCallback(rate)
+				}
+
+				if emit != nil {
+					emit(Event{
+						Type: EventRateSample, Elapsed: elapsed, Rate: rate,
+						Bytes: newTotalDataVolume - startDataVolume,
+					})
 				}
 			case stop := <-stopCapture:
 				if stop {
@@ -368,6 +492,54 @@ func (td *TestDirection) rateCapture() chan bool {
 	return stopCapture
 }
 
+// intervalRate returns the rate of a data volume transferred within a capture interval.
+func (td *TestDirection) intervalRate(deltaDataVolume int64) ByteRate {
+	return ByteRate(float64(deltaDataVolume) / td.manager.rateCaptureFrequency.Seconds())
+}
+
+// addRateSample records the data volume of the latest capture interval.
+func (td *TestDirection) addRateSample(sTime time.Time, deltaDataVolume int64, warmUp bool) {
+	now := time.Now()
+
+	td.samplesMu.Lock()
+	defer td.samplesMu.Unlock()
+
+	td.rateSamples = append(td.rateSamples, RateSample{
+		Time:    now,
+		Elapsed: now.Sub(sTime),
+		Bytes:   deltaDataVolume,
+		Rate:    td.intervalRate(deltaDataVolume),
+		EWMA:    ByteRate(td.welford.EWMA()),
+		WarmUp:  warmUp,
+	})
+}
+
+// RateSamples returns a copy of the rate samples captured by the latest Start,
+// one per rate capture interval.
+func (td *TestDirection) RateSamples() []RateSample {
+	td.samplesMu.Lock()
+	defer td.samplesMu.Unlock()
+
+	return slices.Clone(td.rateSamples)
+}
+
+// StartTime returns the time the latest Start began capturing rates.
+func (td *TestDirection) StartTime() time.Time {
+	td.samplesMu.Lock()
+	defer td.samplesMu.Unlock()
+
+	return td.startTime
+}
+
+// Direction returns the direction of the test.
+func (td *TestDirection) Direction() Direction {
+	if td.TestType == typeUpload {
+		return DirectionUpload
+	}
+
+	return DirectionDownload
+}
+
 // NewChunk creates a new data chunk for the manager.
 func (dm *DataManager) NewChunk() Chunk {
 	var dataChunk DataChunk
@@ -414,6 +586,43 @@ func (dm *DataManager) SetCaptureTime(duration time.Duration) Manager {
 	return dm
 }
 
+// SetMinDuration sets the duration before which a test is not stopped, even if
+// the rate is stable. A minimum equal to the capture time runs fixed-duration tests.
+func (dm *DataManager) SetMinDuration(duration time.Duration) Manager {
+	dm.minDuration = duration
+
+	return dm
+}
+
+// SetWarmUp sets the period at the start of a test whose samples are excluded
+// from the rate, e.g. TCP slow start. It must be shorter than the capture time.
+func (dm *DataManager) SetWarmUp(duration time.Duration) Manager {
+	dm.warmUp = duration
+
+	return dm
+}
+
+// SetStabilityThreshold sets the coefficient of variation below which a test is
+// stopped before the capture time. Values <= 0 restore the default of 3%.
+func (dm *DataManager) SetStabilityThreshold(cv float64) Manager {
+	if cv <= 0 {
+		cv = internal.DefaultStabilityCV
+	}
+
+	dm.stabilityCV = cv
+
+	return dm
+}
+
+// SetMaxDataVolume sets the data budget of a download or upload test in bytes.
+// A test is stopped as soon as it has transferred that many bytes, requests in
+// flight may still add a little. Values <= 0 disable the budget.
+func (dm *DataManager) SetMaxDataVolume(bytes int64) Manager {
+	dm.maxDataVolume = max(bytes, 0)
+
+	return dm
+}
+
 // SetNThread sets the number of threads for the manager.
 func (dm *DataManager) SetNThread(n int) Manager {
 	if n < 1 {
@@ -549,6 +758,11 @@ func (dc *DataChunk) DownloadHandler(reader io.Reader) error {
 		dc.remainOrDiscardSize += rs
 		dc.manager.download.AddTotalDataVolume(rs)
 
+		// do not wait for the test to stop, the data is paid for.
+		if dc.manager.download.BudgetLimited() {
+			return nil
+		}
+
 		if dc.err != nil {
 			if errors.Is(dc.err, io.EOF) {
 				return nil
@@ -585,6 +799,14 @@ func (dc *DataChunk) GetParent() Manager {
 func (dc *DataChunk) Read(buffer []byte) (int, error) {
 	var bytesRead int
 
+	// cut the request short, the data is paid for. An early EOF would not
+	// match the content length the request was sent with.
+	if dc.manager.upload.BudgetLimited() {
+		dc.endTime = time.Now()
+
+		return 0, errDataBudget
+	}
+
 	if dc.remainOrDiscardSize < readChunkSize {
 		if dc.remainOrDiscardSize <= 0 {
 			dc.endTime = time.Now()
@@ -633,10 +855,10 @@ func calcMAFilter(list []int64) float64 {
 	return float64(sum) / float64(listLength-medianExclusionCount)
 }
 
-func pautaFilter(vector []int64) []int64 {
-	dbg.Println("Per capture unit")
-	dbg.Printf("Raw Sequence len: %d\n", len(vector))
-	dbg.Printf("Raw Sequence: %v\n", vector)
+func (dm *DataManager) pautaFilter(vector []int64) []int64 {
+	dm.dbg.Println("Per capture unit")
+	dm.dbg.Printf("Raw Sequence len: %d\n", len(vector))
+	dm.dbg.Printf("Raw Sequence: %v\n", vector)
 
 	if len(vector) == 0 {
 		return vector
@@ -652,9 +874,9 @@ func pautaFilter(vector []int64) []int64 {
 		}
 	}
 
-	dbg.Printf("Raw average: %dByte\n", mean)
-	dbg.Printf("Pauta Sequence len: %d\n", len(retVec))
-	dbg.Printf("Pauta Sequence: %v\n", retVec)
+	dm.dbg.Printf("Raw average: %dByte\n", mean)
+	dm.dbg.Printf("Pauta Sequence len: %d\n", len(retVec))
+	dm.dbg.Printf("Pauta Sequence: %v\n", retVec)
 
 	return retVec
 }
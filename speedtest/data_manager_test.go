@@ -289,6 +289,28 @@ func TestTestDirection_Start(t *testing.T) {
 	}
 }
 
+func TestTestDirection_StartContext(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	dm.SetCaptureTime(time.Minute)
+
+	testDirection := dm.NewDataDirection(typeDownload)
+	testDirection.Add(func() { time.Sleep(time.Millisecond) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	requestCtx, requestCancel := context.WithCancel(context.Background())
+
+	start := time.Now()
+	testDirection.StartContext(ctx, requestCancel, 0)
+
+	// the test direction stops with the context, long before the capture time.
+	assert.Less(t, time.Since(start), 5*time.Second)
+	require.ErrorIs(t, requestCtx.Err(), context.Canceled)
+}
+
 func TestTestDirection_rateCapture(t *testing.T) {
 	tests := []struct {
 		name string
@@ -305,6 +327,8 @@ func TestTestDirection_rateCapture(t *testing.T) {
 			td := dm.NewDataDirection(typeDownload)
 			got := td.rateCapture()
 			assert.NotNil(t, got)
+
+			got <- true
 		})
 	}
 }
@@ -463,6 +487,128 @@ func TestDataManager_SetCaptureTime(t *testing.T) {
 	}
 }
 
+func TestDataManager_SetMinDuration(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	got := dm.SetMinDuration(5 * time.Second)
+	assert.Equal(t, dm, got)
+	assert.Equal(t, 5*time.Second, dm.minDuration)
+}
+
+func TestDataManager_SetWarmUp(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	got := dm.SetWarmUp(2 * time.Second)
+	assert.Equal(t, dm, got)
+	assert.Equal(t, 2*time.Second, dm.warmUp)
+}
+
+func TestDataManager_SetStabilityThreshold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cv   float64
+		want float64
+	}{
+		{name: "custom threshold", cv: 0.05, want: 0.05},
+		{name: "zero restores default", cv: 0, want: 0.03},
+		{name: "negative restores default", cv: -1, want: 0.03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dm := NewDataManager()
+			got := dm.SetStabilityThreshold(tt.cv)
+			assert.Equal(t, dm, got)
+			assert.InDelta(t, tt.want, dm.stabilityCV, 0)
+		})
+	}
+}
+
+func TestTestDirection_WarmUp(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	dm.SetNThread(1).SetCaptureTime(400 * time.Millisecond)
+	dm.SetWarmUp(200 * time.Millisecond)
+
+	testDirection := dm.NewDataDirection(typeDownload)
+	testDirection.Add(func() {
+		testDirection.AddTotalDataVolume(1000)
+		time.Sleep(time.Millisecond)
+	})
+
+	var rates []ByteRate
+
+	testDirection.captureCallback = func(rate ByteRate) { rates = append(rates, rate) }
+
+	_, cancel := context.WithCancel(context.Background())
+	testDirection.Start(cancel, 0)
+
+	samples := testDirection.RateSamples()
+	require.NotEmpty(t, samples)
+	assert.True(t, samples[0].WarmUp)
+	assert.False(t, samples[len(samples)-1].WarmUp)
+	assert.Zero(t, samples[0].EWMA, "warm-up samples must not feed the rate")
+	assert.Positive(t, samples[len(samples)-1].EWMA)
+	assert.Positive(t, rates[0], "the raw rate is reported during the warm-up")
+
+	for _, sample := range samples {
+		assert.Equal(t, sample.Elapsed < 200*time.Millisecond, sample.WarmUp)
+	}
+}
+
+func TestDataManager_SetMaxDataVolume(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	got := dm.SetMaxDataVolume(1000)
+	assert.Equal(t, dm, got)
+	assert.Equal(t, int64(1000), dm.maxDataVolume)
+
+	dm.SetMaxDataVolume(-1)
+	assert.Zero(t, dm.maxDataVolume)
+}
+
+func TestTestDirection_BudgetLimited(t *testing.T) {
+	t.Parallel()
+
+	dm := NewDataManager()
+	dm.SetNThread(1)
+	dm.SetMaxDataVolume(10000)
+
+	testDirection := dm.NewDataDirection(typeDownload)
+	testDirection.Add(func() {
+		testDirection.AddTotalDataVolume(1000)
+		time.Sleep(time.Millisecond)
+	})
+
+	start := time.Now()
+
+	_, cancel := context.WithCancel(context.Background())
+	testDirection.Start(cancel, 0)
+
+	assert.Less(t, time.Since(start), DefaultCaptureTime)
+	assert.True(t, testDirection.BudgetLimited())
+	assert.Equal(t, int64(10000), testDirection.GetTotalDataVolume())
+	assert.False(t, dm.NewDataDirection(typeUpload).BudgetLimited())
+
+	// a second test without a reset gets the full budget again.
+	start = time.Now()
+
+	_, cancel = context.WithCancel(context.Background())
+	testDirection.Start(cancel, 0)
+
+	assert.Less(t, time.Since(start), DefaultCaptureTime)
+	assert.True(t, testDirection.BudgetLimited())
+	assert.Equal(t, int64(20000), testDirection.GetTotalDataVolume())
+	assert.Equal(t, int64(10000), testDirection.TestDataVolume())
+}
+
 func TestDataManager_SetNThread(t *testing.T) {
 	tests := []struct {
 		name string
@@ -814,7 +960,7 @@ func Test_pautaFilter(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			got := pautaFilter(tt.vector)
+			got := NewDataManager().pautaFilter(tt.vector)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -3,12 +3,14 @@ package speedtest
 import (
 	"log"
 	"os"
+	"sync/atomic"
 )
 
-// Debug is a simple debug logging utility.
+// Debug is a simple debug logging utility. Every client has its own, so that
+// enabling the debug logs of one client does not affect the others.
 type Debug struct {
 	dbg  *log.Logger
-	flag bool
+	flag atomic.Bool
 }
 
 // NewDebug creates a new debug logger.
@@ -18,21 +20,24 @@ func NewDebug() *Debug {
 
 // Enable enables debug logging.
 func (d *Debug) Enable() {
-	d.flag = true
+	d.flag.Store(true)
 }
 
-// Println prints debug messages if enabled.
+// Disable disables debug logging.
+func (d *Debug) Disable() {
+	d.flag.Store(false)
+}
+
+// Println prints debug messages if enabled. A nil Debug prints nothing.
 func (d *Debug) Println(v ...any) {
-	if d.flag {
+	if d != nil && d.flag.Load() {
 		d.dbg.Println(v...)
 	}
 }
 
-// Printf prints formatted debug messages if enabled.
+// Printf prints formatted debug messages if enabled. A nil Debug prints nothing.
 func (d *Debug) Printf(format string, v ...any) {
-	if d.flag {
+	if d != nil && d.flag.Load() {
 		d.dbg.Printf(format, v...)
 	}
 }
-
-var dbg = NewDebug()
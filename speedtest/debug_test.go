@@ -20,7 +20,7 @@ func TestNewDebug(t *testing.T) {
 
 			got := NewDebug()
 			assert.NotNil(t, got)
-			assert.False(t, got.flag)
+			assert.False(t, got.flag.Load())
 			assert.NotNil(t, got.dbg)
 		})
 	}
@@ -39,9 +39,9 @@ func TestDebug_Enable(t *testing.T) {
 			t.Parallel()
 
 			d := NewDebug()
-			assert.False(t, d.flag)
+			assert.False(t, d.flag.Load())
 			d.Enable()
-			assert.True(t, d.flag)
+			assert.True(t, d.flag.Load())
 		})
 	}
 }
@@ -10,7 +10,8 @@
 //     with automatic distance calculation based on user location
 //   - Latency Testing: Performs ping tests using HTTP, TCP, or ICMP protocols with jitter and latency statistics
 //   - Speed Testing: Measures download speeds via HTTP GET requests and upload speeds
-//     via HTTP POST requests to speedtest servers
+//     via HTTP POST requests to speedtest servers, or via the raw TCP protocol when
+//     UserConfig.TransferMode is TCP
 //   - Packet Loss: Calculates uplink packet loss using TCP and UDP transport implementations
 //   - Multi-server Testing: Supports concurrent testing across multiple servers for more accurate results
 //
@@ -24,12 +25,18 @@
 //   - Manager/DataManager: Handles data collection and rate calculations during tests
 //   - Chunk: Manages individual data transfer chunks with rate and duration tracking
 //   - ByteRate: Represents data transfer rates with flexible unit formatting (bps, Kbps, Mbps, etc.)
+//   - Result/ResultSink: Delivers server results to JSON, JSONL, CSV, InfluxDB line protocol or webhook sinks
+//   - Event/Observer: Streams test lifecycle events, like phases, rate and ping samples and results, to subscribed observers
 //
 // ## Main Functions
 //
 //   - New(): Creates a new speedtest client with optional configuration
 //   - FetchUserInfo(): Retrieves user information from speedtest.net
 //   - FetchServers(): Discovers available speedtest servers
+//   - PrepareServers(): Pings, locates and sorts servers from another source, like a static list
+//   - NewCustomServer(): Creates a self-hosted server with its own id, name and endpoint paths
+//   - SelectServer(): Picks a server by latency, median latency, distance or a throughput probe after filtering
+//   - CloneServer(): Copies a server for another client, e.g. one forced to IPv4 or IPv6 by UserConfig.IPFamily or bound to another UserConfig.Source
 //   - Server.PingTest(): Measures latency to a server
 //   - Server.DownloadTest(): Performs download speed test
 //   - Server.UploadTest(): Performs upload speed test
@@ -43,10 +50,17 @@
 //   - Flexible Configuration: Customizable timeouts, proxy settings, source addresses, and debug modes
 //   - Distance-based Server Selection: Automatically selects geographically optimal servers
 //   - Statistics: Comprehensive statistics including mean, standard deviation, and coefficient of variation
+//   - Time Series: UserConfig.TimeSeries keeps the timestamped rate and latency samples of the transfer tests
+//   - HTTPS: UserConfig.HTTPS upgrades the test URLs to HTTPS, with custom CAs, client certificates and SNI overrides
+//   - Bufferbloat Grading: the transfer tests sample the latency under load and Server.Bufferbloat grades its
+//     increase over the idle latency from A+ to F
+//   - Concurrent Clients: the debug logs, rate units (UserConfig.Unit, FormatRate), dialers and resolver are
+//     per client, so clients with different settings can test in parallel in one process
 //
 // # Subpackages
 //
 //   - transport: Implements TCP and UDP transport layers for low-level network operations
+//   - server: Implements a speedtest.net compatible server for closed-loop testing
 //   - internal: Provides Welford's algorithm for online statistical calculations
 //
 // # Dependencies
@@ -64,5 +78,5 @@
 //	server.DownloadTest()
 //	server.UploadTest()
 //	fmt.Printf("Download: %s, Upload: %s, Latency: %s\n",
-//	    server.DLSpeed, server.ULSpeed, server.Latency)
+//	    client.FormatRate(server.DLSpeed), client.FormatRate(server.ULSpeed), server.Latency)
 package speedtest
@@ -0,0 +1,206 @@
+package speedtest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+// Event types, the fields of an event set for each type are listed by Event.
+const (
+	EventPhaseStarted     EventType = "phase_started"
+	EventPhaseFinished    EventType = "phase_finished"
+	EventRateSample       EventType = "rate_sample"
+	EventPingSample       EventType = "ping_sample"
+	EventPacketLoss       EventType = "packet_loss"
+	EventConnectionOpened EventType = "connection_opened"
+	EventConnectionFailed EventType = "connection_failed"
+	EventChunkError       EventType = "chunk_error"
+	EventResult           EventType = "result"
+)
+
+// Phase is a test of a server.
+type Phase string
+
+// Phases of the tests.
+const (
+	PhasePing       Phase = "ping"
+	PhaseDownload   Phase = "download"
+	PhaseUpload     Phase = "upload"
+	PhasePacketLoss Phase = "packet_loss"
+)
+
+// Event is an event of the lifecycle of the tests of a client.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Server is the server under test, nil for the events of a packet loss
+	// analyzer, which only know the host.
+	Server *Server
+	Host   string
+
+	// Phase is set for all events but the results.
+	Phase Phase
+	// Elapsed is the time since the start of the phase, for rate samples and
+	// finished phases.
+	Elapsed time.Duration
+	// Rate is the current EWMA rate of a rate sample, the rate of the last
+	// interval during the warm-up.
+	Rate ByteRate
+	// Bytes is the data transferred since the start of the phase, for rate samples.
+	Bytes int64
+	// Latency is the round trip time of a ping sample, the latency under load
+	// for the ping samples of the download and upload phases.
+	Latency time.Duration
+	// PacketLoss is the packet loss measured so far, for packet loss updates.
+	PacketLoss *transport.PLoss
+	// Addr is the remote address of a connection, the proxy's when a proxy is used.
+	Addr string
+	// Err is the error of a failed connection, a chunk error, or of a phase
+	// finished early.
+	Err error
+	// Result is set for the results, emitted by TestAll and by Speedtest.Emit.
+	Result *Result
+}
+
+// Observer receives the events of a client. It is called synchronously by the
+// goroutines of the tests, concurrently during the transfer tests, so it must
+// be safe for concurrent use and return quickly.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc is a function used as an Observer.
+type ObserverFunc func(event Event)
+
+// OnEvent calls f(event).
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// observers are the subscribed observers of a client.
+type observers struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+type subscription struct {
+	observer Observer
+}
+
+// WithObserver subscribes an observer to the events of the client.
+func WithObserver(observer Observer) Option {
+	return func(s *Speedtest) {
+		s.Subscribe(observer)
+	}
+}
+
+// Subscribe subscribes the observer to the events of the client and returns a
+// function unsubscribing it.
+func (s *Speedtest) Subscribe(observer Observer) func() {
+	sub := &subscription{observer: observer}
+
+	s.observers.mu.Lock()
+	s.observers.subs = append(s.observers.subs, sub)
+	s.observers.mu.Unlock()
+
+	return func() {
+		s.observers.mu.Lock()
+		defer s.observers.mu.Unlock()
+
+		s.observers.subs = slices.DeleteFunc(s.observers.subs, func(other *subscription) bool {
+			return other == sub
+		})
+	}
+}
+
+// Emit sends the event to the observers of the client, e.g. the results of
+// tests run one by one. The time of the event defaults to now.
+func (s *Speedtest) Emit(event Event) {
+	if s == nil {
+		return
+	}
+
+	s.observers.mu.RLock()
+	subs := slices.Clone(s.observers.subs)
+	s.observers.mu.RUnlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for _, sub := range subs {
+		sub.observer.OnEvent(event)
+	}
+}
+
+// EmitResult creates the result of the server measured now and sends it to the
+// observers of the client.
+func (s *Speedtest) EmitResult(server *Server) *Result {
+	result := s.NewResult(server)
+	s.Emit(Event{Type: EventResult, Time: result.Timestamp, Server: server, Host: server.Host, Result: result})
+
+	return result
+}
+
+// emit sends the event of the server to the observers of its client.
+func (s *Server) emit(event Event) {
+	if s == nil || s.Context == nil {
+		return
+	}
+
+	event.Server = s
+	event.Host = s.Host
+	s.Context.Emit(event)
+}
+
+// emitter sends the events of a phase.
+type emitter func(event Event)
+
+type emitterKey struct{}
+
+// withEmitter returns a context sending the events of the requests and
+// transfers made with it for the phase of the server.
+func withEmitter(ctx context.Context, server *Server, phase Phase) context.Context {
+	return context.WithValue(ctx, emitterKey{}, emitter(func(event Event) {
+		event.Phase = phase
+		server.emit(event)
+	}))
+}
+
+// emitterFrom returns the emitter of the context, which does nothing if there
+// is none.
+func emitterFrom(ctx context.Context) emitter {
+	emit, _ := ctx.Value(emitterKey{}).(emitter)
+	if emit == nil {
+		return func(Event) {}
+	}
+
+	return emit
+}
+
+// startPhase sends the start of the phase of the server and returns a context
+// emitting the events of the phase and a function sending its end, with the
+// error it ended with.
+func (s *Server) startPhase(ctx context.Context, phase Phase) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx = withEmitter(ctx, s, phase)
+	emit := emitterFrom(ctx)
+
+	emit(Event{Type: EventPhaseStarted, Time: start})
+
+	return ctx, func(err error) {
+		emit(Event{Type: EventPhaseFinished, Elapsed: time.Since(start), Err: err})
+	}
+}
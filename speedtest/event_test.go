@@ -0,0 +1,182 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder records the events of a client.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) OnEvent(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+// of returns the recorded events of the type.
+func (r *eventRecorder) of(eventType EventType) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []Event
+
+	for _, event := range r.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func TestSpeedtest_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	recorder := &eventRecorder{}
+	count := 0
+
+	client := New(WithObserver(recorder))
+	unsubscribe := client.Subscribe(ObserverFunc(func(Event) { count++ }))
+
+	client.Emit(Event{Type: EventPingSample, Latency: time.Millisecond})
+	unsubscribe()
+	client.Emit(Event{Type: EventPingSample, Latency: 2 * time.Millisecond})
+
+	assert.Equal(t, 1, count)
+
+	events := recorder.of(EventPingSample)
+	require.Len(t, events, 2)
+	assert.Equal(t, time.Millisecond, events[0].Latency)
+	assert.False(t, events[0].Time.IsZero())
+
+	// a nil client and a client without observers do nothing.
+	assert.NotPanics(t, func() { (*Speedtest)(nil).Emit(Event{}) })
+	assert.NotPanics(t, func() { New().Emit(Event{}) })
+}
+
+func TestServer_TestAll_events(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	recorder := &eventRecorder{}
+
+	client := New(WithUserConfig(&UserConfig{MaxConnections: 2}), WithObserver(recorder))
+	client.SetCaptureTime(500 * time.Millisecond)
+
+	target, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+	require.NoError(t, target.TestAll())
+
+	var phases []string
+
+	for _, event := range recorder.events {
+		switch event.Type {
+		case EventPhaseStarted, EventPhaseFinished:
+			phases = append(phases, string(event.Phase)+" "+string(event.Type))
+
+			require.NoError(t, event.Err)
+		}
+
+		assert.Same(t, target, event.Server)
+		assert.Equal(t, target.Host, event.Host)
+	}
+
+	assert.Equal(t, []string{
+		"ping phase_started", "ping phase_finished",
+		"download phase_started", "download phase_finished",
+		"upload phase_started", "upload phase_finished",
+	}, phases)
+
+	pings := recorder.of(EventPingSample)
+	assert.Len(t, pings, 10)
+	assert.Equal(t, PhasePing, pings[0].Phase)
+	assert.Positive(t, pings[0].Latency)
+
+	samples := recorder.of(EventRateSample)
+	require.NotEmpty(t, samples)
+	assert.True(t, slices.ContainsFunc(samples, func(event Event) bool {
+		return event.Phase == PhaseUpload && event.Rate > 0 && event.Bytes > 0
+	}))
+
+	last := samples[len(samples)-1]
+	assert.Positive(t, last.Elapsed)
+
+	// the requests canceled by the end of a test are no chunk errors.
+	assert.Empty(t, recorder.of(EventChunkError))
+
+	opened := recorder.of(EventConnectionOpened)
+	require.NotEmpty(t, opened)
+	assert.Equal(t, host, opened[0].Addr)
+
+	// the result is the last event.
+	results := recorder.of(EventResult)
+	require.Len(t, results, 1)
+	assert.Equal(t, results[0], recorder.events[len(recorder.events)-1])
+	assert.Same(t, target, results[0].Result.Server)
+}
+
+func TestServer_PingTest_connectionFailed(t *testing.T) {
+	t.Parallel()
+
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	host := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	recorder := &eventRecorder{}
+	client := New(WithUserConfig(&UserConfig{PingMode: TCP}), WithObserver(recorder))
+
+	target, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+	require.Error(t, target.PingTest(nil))
+
+	failed := recorder.of(EventConnectionFailed)
+	require.Len(t, failed, 1)
+	assert.Equal(t, host, failed[0].Addr)
+	require.Error(t, failed[0].Err)
+
+	finished := recorder.of(EventPhaseFinished)
+	require.Len(t, finished, 1)
+	assert.Equal(t, PhasePing, finished[0].Phase)
+	require.Error(t, finished[0].Err)
+}
+
+func TestPacketLossAnalyzer_events(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	recorder := &eventRecorder{}
+
+	client := New(WithObserver(recorder))
+	analyzer := client.NewPacketLossAnalyzer(&PacketLossAnalyzerOptions{
+		RemoteSamplingInterval: 100 * time.Millisecond,
+		PacketSendingInterval:  10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, analyzer.RunWithContext(ctx, host, nil))
+
+	updates := recorder.of(EventPacketLoss)
+	require.NotEmpty(t, updates)
+	assert.Equal(t, PhasePacketLoss, updates[0].Phase)
+	assert.Equal(t, host, updates[0].Host)
+	assert.NotNil(t, updates[0].PacketLoss)
+
+	assert.Len(t, recorder.of(EventPhaseStarted), 1)
+	assert.Len(t, recorder.of(EventPhaseFinished), 1)
+}
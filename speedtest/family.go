@@ -0,0 +1,97 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// IPFamily is the address family the connections of a client are forced to.
+type IPFamily string
+
+const (
+	// IPFamilyAny connects over either family, as resolved.
+	IPFamilyAny IPFamily = ""
+	// IPFamilyIPv4 only connects over IPv4.
+	IPFamilyIPv4 IPFamily = "ipv4"
+	// IPFamilyIPv6 only connects over IPv6.
+	IPFamilyIPv6 IPFamily = "ipv6"
+)
+
+// ErrIPFamily is returned when a connection to an address of the other family
+// is attempted, e.g. because the server has no address of the forced family.
+var ErrIPFamily = errors.New("address family not allowed")
+
+// String returns the display name of the family.
+func (family IPFamily) String() string {
+	switch family {
+	case IPFamilyIPv4:
+		return "IPv4"
+	case IPFamilyIPv6:
+		return "IPv6"
+	default:
+		return "IPv4/IPv6"
+	}
+}
+
+// allows reports whether the address, with or without a port, may be
+// connected to. Addresses that are not IP addresses are left to the dialer.
+func (family IPFamily) allows(address string) bool {
+	if family == IPFamilyAny {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+
+	if addr.Unmap().Is4() {
+		return family == IPFamilyIPv4
+	}
+
+	return family == IPFamilyIPv6
+}
+
+// network returns the network of the family, e.g. tcp4 for tcp over IPv4, so
+// that only the addresses of the family are resolved and connected to. Other
+// networks are returned unchanged.
+func (family IPFamily) network(network string) string {
+	switch network {
+	case "tcp", "udp", "ip":
+	default:
+		return network
+	}
+
+	switch family {
+	case IPFamilyIPv4:
+		return network + "4"
+	case IPFamilyIPv6:
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// familyDialer dials the connections of a dialer over an address family.
+type familyDialer struct {
+	dialer *net.Dialer
+	family IPFamily
+}
+
+// DialContext connects to the address over the network of the family.
+// Addresses of the other family fail with ErrIPFamily.
+func (d familyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !d.family.allows(address) {
+		return nil, fmt.Errorf("%w: %s over %s", ErrIPFamily, address, d.family)
+	}
+
+	return d.dialer.DialContext(ctx, d.family.network(network), address)
+}
@@ -0,0 +1,135 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFamily_allows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		family  IPFamily
+		address string
+		want    bool
+	}{
+		{family: IPFamilyAny, address: "[::1]:80", want: true},
+		{family: IPFamilyIPv4, address: "127.0.0.1:8080", want: true},
+		{family: IPFamilyIPv4, address: "[::1]:8080", want: false},
+		{family: IPFamilyIPv4, address: "[::ffff:127.0.0.1]:8080", want: true},
+		{family: IPFamilyIPv4, address: "192.0.2.1", want: true},
+		{family: IPFamilyIPv6, address: "192.0.2.1", want: false},
+		{family: IPFamilyIPv6, address: "[2001:db8::1]:443", want: true},
+		{family: IPFamilyIPv6, address: "fe80::1%eth0", want: true},
+		{family: IPFamilyIPv6, address: "/tmp/socket", want: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.family.allows(tt.address), "%s %s", tt.family, tt.address)
+	}
+}
+
+func TestIPFamily_network(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		family  IPFamily
+		network string
+		want    string
+	}{
+		{family: IPFamilyAny, network: "tcp", want: "tcp"},
+		{family: IPFamilyIPv4, network: "tcp", want: "tcp4"},
+		{family: IPFamilyIPv4, network: "udp", want: "udp4"},
+		{family: IPFamilyIPv6, network: "tcp", want: "tcp6"},
+		{family: IPFamilyIPv6, network: "ip", want: "ip6"},
+		{family: IPFamilyIPv6, network: "ip6:ipv6-icmp", want: "ip6:ipv6-icmp"},
+		{family: IPFamilyIPv4, network: "unix", want: "unix"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.family.network(tt.network), "%s %s", tt.family, tt.network)
+	}
+}
+
+func TestFamilyDialer_DialContext(t *testing.T) {
+	t.Parallel()
+
+	var networks []string
+
+	dialer := &net.Dialer{Control: func(network, _ string, _ syscall.RawConn) error {
+		networks = append(networks, network)
+
+		return nil
+	}}
+
+	host := startLocalServer(t)
+
+	ipv4 := familyDialer{dialer: dialer, family: IPFamilyIPv4}
+
+	conn, err := ipv4.DialContext(context.Background(), "tcp", host)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	assert.Equal(t, []string{"tcp4"}, networks)
+
+	// names resolve to the addresses of the family only.
+	_, port, err := net.SplitHostPort(host)
+	require.NoError(t, err)
+
+	conn, err = ipv4.DialContext(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	assert.Equal(t, []string{"tcp4", "tcp4"}, networks)
+
+	_, err = familyDialer{dialer: dialer, family: IPFamilyIPv6}.DialContext(context.Background(), "tcp", host)
+	require.ErrorIs(t, err, ErrIPFamily)
+}
+
+func TestIPFamily_Connections(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	for _, tt := range []struct {
+		family  IPFamily
+		wantErr bool
+	}{
+		{family: IPFamilyIPv4},
+		{family: IPFamilyIPv6, wantErr: true},
+	} {
+		client := New(WithUserConfig(&UserConfig{IPFamily: tt.family}))
+
+		server, err := client.CustomServer("http://" + host)
+		require.NoError(t, err)
+
+		_, err = server.HTTPPing(context.Background(), 1, 0, nil)
+		_, errTCP := server.TCPPing(context.Background(), 1, 0, nil)
+
+		if tt.wantErr {
+			require.Error(t, err)
+			require.ErrorIs(t, errTCP, ErrIPFamily)
+
+			continue
+		}
+
+		require.NoError(t, err)
+		require.NoError(t, errTCP)
+	}
+}
+
+func TestSpeedtest_CloneServer(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{ID: "1", Host: "a:8080", Distance: 12, Latency: 20, DLSpeed: 100, Context: New()}
+	client := New(WithUserConfig(&UserConfig{IPFamily: IPFamilyIPv6}))
+
+	clone := client.CloneServer(server)
+	assert.Equal(t, &Server{ID: "1", Host: "a:8080", Distance: 12, IPFamily: IPFamilyIPv6, Context: client}, clone)
+
+	client = New(WithUserConfig(&UserConfig{Source: "127.0.0.1"}))
+
+	clone = client.CloneServer(server)
+	assert.Equal(t, &Server{ID: "1", Host: "a:8080", Distance: 12, Source: "127.0.0.1", Context: client}, clone)
+}
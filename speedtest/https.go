@@ -0,0 +1,183 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPSMode is whether the HTTP test URLs of the servers are upgraded to HTTPS.
+type HTTPSMode string
+
+const (
+	// HTTPSOff tests the servers at their URLs as listed.
+	HTTPSOff HTTPSMode = ""
+	// HTTPSPrefer upgrades the URLs of the servers that answer over HTTPS and
+	// tests the others over HTTP.
+	HTTPSPrefer HTTPSMode = "prefer"
+	// HTTPSRequire upgrades the URLs of all servers and fails the tests of the
+	// servers that do not answer over HTTPS.
+	HTTPSRequire HTTPSMode = "require"
+)
+
+// httpsProbeTimeout bounds the request checking whether a server supports HTTPS.
+const httpsProbeTimeout = 5 * time.Second
+
+var (
+	// ErrHTTPSUnsupported is returned when a server does not answer over HTTPS
+	// while HTTPS is required.
+	ErrHTTPSUnsupported = errors.New("server does not support HTTPS")
+	// ErrInvalidTLSConfig is returned for TLS options that cannot be loaded.
+	ErrInvalidTLSConfig = errors.New("invalid TLS configuration")
+)
+
+// TLSConfig returns the TLS configuration of the connections built from the
+// TLS options, or nil if none is set. TLSServerName is left out, it only
+// applies to the connections to the test servers.
+func (c *UserConfig) TLSConfig() (*tls.Config, error) {
+	if len(c.TLSCAFile) == 0 && len(c.TLSCertFile) == 0 && len(c.TLSKeyFile) == 0 && !c.TLSInsecureSkipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+
+	if len(c.TLSCAFile) > 0 {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		// the bundle adds to the system roots, which the server list still needs.
+		config.RootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates found in %s", ErrInvalidTLSConfig, c.TLSCAFile)
+		}
+	}
+
+	if len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 {
+		if len(c.TLSCertFile) == 0 || len(c.TLSKeyFile) == 0 {
+			return nil, fmt.Errorf("%w: the client certificate and key must be set together", ErrInvalidTLSConfig)
+		}
+
+		certificate, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// hasTLSOptions reports whether any TLS option is set.
+func (c *UserConfig) hasTLSOptions() bool {
+	return len(c.TLSCAFile) > 0 || len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 ||
+		len(c.TLSServerName) > 0 || c.TLSInsecureSkipVerify
+}
+
+type serverRequestKey struct{}
+
+// withServerRequest returns a context marking the requests made with it as
+// requests to a test server, which are sent with the TLS server name override.
+func withServerRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serverRequestKey{}, true)
+}
+
+// isServerRequest reports whether the context marks a request to a test server.
+func isServerRequest(ctx context.Context) bool {
+	marked, _ := ctx.Value(serverRequestKey{}).(bool)
+
+	return marked
+}
+
+// upgradeHTTPS upgrades the test URLs of the server to HTTPS according to the
+// HTTPS mode of the client. The server is checked once, it must not be called
+// concurrently for the same server.
+func (s *Server) upgradeHTTPS(ctx context.Context) error {
+	if s.Context.config.HTTPS == HTTPSOff || s.httpsChecked {
+		return nil
+	}
+
+	err := s.probeHTTPS(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to check HTTPS: %w", ctx.Err())
+		}
+
+		if s.Context.config.HTTPS == HTTPSRequire {
+			return fmt.Errorf("failed to upgrade server %s to HTTPS: %w", s.ID, err)
+		}
+
+		s.Context.dbg.Printf("Warning: testing server %s over HTTP. err: %s\n", s.ID, err.Error())
+
+		s.httpsChecked = true
+
+		return nil
+	}
+
+	s.listedURLs = &serverURLs{url: s.URL, download: s.DownloadURL, latency: s.LatencyURL}
+	s.URL = httpsURL(s.URL)
+	s.DownloadURL = httpsURL(s.DownloadURL)
+	s.LatencyURL = httpsURL(s.LatencyURL)
+	s.httpsChecked = true
+
+	return nil
+}
+
+// probeHTTPS requests the latency probe of the server over HTTPS.
+func (s *Server) probeHTTPS(ctx context.Context) error {
+	latencyURL, err := s.latencyURL()
+	if err != nil {
+		return fmt.Errorf("failed to parse server URL for HTTPS: %w", err)
+	}
+
+	pCtx, cancel := context.WithTimeout(ctx, httpsProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(withServerRequest(pCtx), http.MethodGet, httpsURL(latencyURL), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTPS probe request: %w", err)
+	}
+
+	resp, err := s.Context.doer.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHTTPSUnsupported, err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrHTTPSUnsupported, resp.Status)
+	}
+
+	return nil
+}
+
+// httpsURL returns the URL with the https scheme if it is an http URL, and
+// any other URL as is.
+func httpsURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" {
+		return rawURL
+	}
+
+	u.Scheme = "https"
+
+	return u.String()
+}
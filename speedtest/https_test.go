@@ -0,0 +1,226 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
+)
+
+// startTLSServer starts a speedtest server answering over HTTPS only and
+// returns its host, the path of its CA bundle and the last server name sent.
+func startTLSServer(t *testing.T) (string, string, func() string) {
+	t.Helper()
+
+	var (
+		mu         sync.Mutex
+		serverName string
+	)
+
+	tlsServer := httptest.NewUnstartedServer(server.New(nil).Handler())
+	tlsServer.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			serverName = hello.ServerName
+
+			return nil, nil
+		},
+	}
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(block), 0o600))
+
+	return strings.TrimPrefix(tlsServer.URL, "https://"), caFile, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return serverName
+	}
+}
+
+func TestHTTPSURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rawURL string
+		want   string
+	}{
+		{rawURL: "http://example.com:8080/speedtest/upload.php", want: "https://example.com:8080/speedtest/upload.php"},
+		{rawURL: "https://example.com/speedtest/", want: "https://example.com/speedtest/"},
+		{rawURL: "", want: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, httpsURL(tt.rawURL), tt.rawURL)
+	}
+}
+
+func TestUserConfig_TLSConfig(t *testing.T) {
+	t.Parallel()
+
+	_, caFile, _ := startTLSServer(t)
+
+	invalidFile := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("not a certificate"), 0o600))
+
+	config, err := (&UserConfig{TLSServerName: "example.com"}).TLSConfig()
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = (&UserConfig{TLSCAFile: caFile, TLSInsecureSkipVerify: true}).TLSConfig()
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.NotNil(t, config.RootCAs)
+	assert.True(t, config.InsecureSkipVerify)
+
+	_, err = (&UserConfig{TLSCAFile: invalidFile}).TLSConfig()
+	require.ErrorIs(t, err, ErrInvalidTLSConfig)
+
+	_, err = (&UserConfig{TLSCertFile: caFile}).TLSConfig()
+	require.ErrorIs(t, err, ErrInvalidTLSConfig)
+
+	_, err = (&UserConfig{TLSCAFile: filepath.Join(t.TempDir(), "missing.pem")}).TLSConfig()
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestServer_upgradeHTTPS(t *testing.T) {
+	t.Parallel()
+
+	tlsHost, caFile, serverName := startTLSServer(t)
+	plainHost := startLocalServer(t)
+
+	tests := []struct {
+		name       string
+		host       string
+		config     UserConfig
+		wantScheme string
+		wantErr    error
+	}{
+		{name: "off", host: plainHost, config: UserConfig{}, wantScheme: "http://"},
+		{
+			name:       "prefer upgrade",
+			host:       tlsHost,
+			config:     UserConfig{HTTPS: HTTPSPrefer, TLSCAFile: caFile},
+			wantScheme: "https://",
+		},
+		{name: "prefer fallback", host: plainHost, config: UserConfig{HTTPS: HTTPSPrefer}, wantScheme: "http://"},
+		{name: "require", host: tlsHost, config: UserConfig{HTTPS: HTTPSRequire, TLSCAFile: caFile}, wantScheme: "https://"},
+		{
+			name:    "require unsupported",
+			host:    plainHost,
+			config:  UserConfig{HTTPS: HTTPSRequire},
+			wantErr: ErrHTTPSUnsupported,
+		},
+		{
+			name:    "require untrusted",
+			host:    tlsHost,
+			config:  UserConfig{HTTPS: HTTPSRequire},
+			wantErr: ErrHTTPSUnsupported,
+		},
+		{
+			name:       "insecure",
+			host:       tlsHost,
+			config:     UserConfig{HTTPS: HTTPSRequire, TLSInsecureSkipVerify: true},
+			wantScheme: "https://",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.config.MaxConnections = 1
+			client := New(WithUserConfig(&tt.config))
+			client.SetCaptureTime(200 * time.Millisecond)
+
+			target, err := client.CustomServer("http://" + tt.host)
+			require.NoError(t, err)
+
+			listedURL := target.URL
+
+			err = target.PingTest(nil)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(target.URL, tt.wantScheme), target.URL)
+			assert.Positive(t, target.Latency)
+
+			require.NoError(t, target.DownloadTest())
+			assert.Positive(t, target.DLSpeed)
+
+			// the server is listed with the URL it was created with.
+			assert.Equal(t, listedURL, target.Listing().URL)
+
+			if tt.wantScheme == "https://" {
+				assert.Equal(t, "TLS 1.3", target.Connection.TLSVersion)
+				assert.Empty(t, serverName())
+			}
+		})
+	}
+}
+
+func TestPingServers_noHTTPSUpgrade(t *testing.T) {
+	t.Parallel()
+
+	client := New(WithUserConfig(&UserConfig{HTTPS: HTTPSPrefer}))
+
+	target, err := client.CustomServer("http://" + startLocalServer(t))
+	require.NoError(t, err)
+
+	// the servers of the list are ranked without checking them for HTTPS.
+	pingServers(context.Background(), Servers{target}, HTTP)
+	assert.Positive(t, target.Latency)
+	assert.False(t, target.httpsChecked)
+
+	require.NoError(t, target.PingTest(nil))
+	assert.True(t, target.httpsChecked)
+}
+
+func TestServer_TLSServerName(t *testing.T) {
+	t.Parallel()
+
+	tlsHost, caFile, serverName := startTLSServer(t)
+
+	// the certificate of httptest is valid for example.com.
+	client := New(WithUserConfig(&UserConfig{
+		HTTPS:         HTTPSRequire,
+		TLSCAFile:     caFile,
+		TLSServerName: "example.com",
+	}))
+
+	target, err := client.CustomServer("http://" + tlsHost)
+	require.NoError(t, err)
+
+	require.NoError(t, target.PingTest(nil))
+	assert.Equal(t, "example.com", serverName())
+	assert.Equal(t, "https://"+tlsHost+"/speedtest/upload.php", target.URL)
+
+	client = New(WithUserConfig(&UserConfig{
+		HTTPS:         HTTPSRequire,
+		TLSCAFile:     caFile,
+		TLSServerName: "invalid.example.org",
+	}))
+
+	target, err = client.CustomServer("http://" + tlsHost)
+	require.NoError(t, err)
+	require.ErrorIs(t, target.PingTest(nil), ErrHTTPSUnsupported)
+}
@@ -9,6 +9,9 @@ import (
 )
 
 const (
+	// DefaultStabilityCV is the coefficient of variation below which the rate is considered stable.
+	DefaultStabilityCV = 0.03
+
 	stabilityThresholdDivisor = 3
 	minSamplesForVariance     = 2
 	ewmaBetaNumerator         = 2
@@ -28,6 +31,7 @@ type Welford struct {
 	consecutiveStableIterations          int
 	consecutiveStableIterationsThreshold int
 	cv                                   float64
+	stabilityCV                          float64 // C.V threshold of stability
 	ewmaMean                             float64
 	steps                                int
 	minSteps                             int
@@ -49,7 +53,18 @@ func NewWelford(cycle, frequency time.Duration) *Welford {
 		minSteps:                             windowSize * 2,                                // set minimum steps with 2x windowSize.
 		beta:                                 ewmaBetaNumerator / (float64(windowSize) + 1), // ewma beta ratio
 		scale:                                float64(time.Second / frequency),
+		stabilityCV:                          DefaultStabilityCV,
+	}
+}
+
+// SetStabilityThreshold sets the coefficient of variation below which Update
+// considers the rate stable. Values <= 0 restore DefaultStabilityCV.
+func (w *Welford) SetStabilityThreshold(cv float64) {
+	if cv <= 0 {
+		cv = DefaultStabilityCV
 	}
+
+	w.stabilityCV = cv
 }
 
 // Update Enter the given value into the measuring system.
@@ -92,7 +107,7 @@ func (w *Welford) Update(globalAvg, value float64) bool {
 
 	w.ewmaMean = value*w.beta + w.ewmaMean*(1-w.beta)
 	// acc consecutiveStableIterations
-	if w.n == w.cap && w.cv < 0.03 {
+	if w.n == w.cap && w.cv < w.stabilityCV {
 		w.consecutiveStableIterations++
 	} else if w.consecutiveStableIterations > 0 {
 		w.consecutiveStableIterations--
@@ -130,6 +145,10 @@ func (w *Welford) StandardDeviation() float64 {
 
 // EWMA returns the exponentially weighted moving average.
 func (w *Welford) EWMA() float64 {
+	if w.n == 0 {
+		return 0
+	}
+
 	return w.ewmaMean*0.5 + w.movingAvg/float64(w.n)*0.5
 }
 
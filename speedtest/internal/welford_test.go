@@ -182,6 +182,36 @@ func TestWelford_Update(t *testing.T) {
 	}
 }
 
+func TestWelford_SetStabilityThreshold(t *testing.T) {
+	t.Parallel()
+
+	// a rate alternating by 10% is stable with a 20% threshold only.
+	feed := func(w *Welford) bool {
+		stable := false
+		for i := range 100 {
+			value := 100.0
+			if i%2 == 0 {
+				value = 110.0
+			}
+
+			stable = w.Update(value, value)
+		}
+
+		return stable
+	}
+
+	w := NewWelford(time.Second, 100*time.Millisecond)
+	assert.InDelta(t, DefaultStabilityCV, w.stabilityCV, 0)
+	assert.False(t, feed(w))
+
+	w = NewWelford(time.Second, 100*time.Millisecond)
+	w.SetStabilityThreshold(0.2)
+	assert.True(t, feed(w))
+
+	w.SetStabilityThreshold(0)
+	assert.InDelta(t, DefaultStabilityCV, w.stabilityCV, 0)
+}
+
 func TestWelford_Mean(t *testing.T) {
 	type fields struct {
 		n                                    int
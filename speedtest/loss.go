@@ -1,6 +1,7 @@
 package speedtest
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"net"
@@ -17,13 +18,19 @@ type PacketLossAnalyzerOptions struct {
 	PacketSendingInterval  time.Duration
 	PacketSendingTimeout   time.Duration
 	SourceInterface        string      // source interface
+	IPFamily               IPFamily    // forces the connections of the dialers to one address family
 	TCPDialer              *net.Dialer // tcp dialer for sampling
 	UDPDialer              *net.Dialer // udp dialer for sending packet
 }
 
+// defaultPacketSendingTimeout is the default dial timeout of the analyzer.
+const defaultPacketSendingTimeout = 5 * time.Second
+
 // PacketLossAnalyzer performs packet loss analysis on network connections.
 type PacketLossAnalyzer struct {
 	options *PacketLossAnalyzerOptions
+	err     error      // the analysis is not possible, e.g. through a proxy
+	client  *Speedtest // client the events are sent to, if created by one
 }
 
 // NewPacketLossAnalyzer creates a new packet loss analyzer with the given options.
@@ -45,25 +52,27 @@ func NewPacketLossAnalyzer(options *PacketLossAnalyzerOptions) *PacketLossAnalyz
 	}
 
 	if options.PacketSendingTimeout == 0 {
-		options.PacketSendingTimeout = 5 * time.Second
+		options.PacketSendingTimeout = defaultPacketSendingTimeout
+	}
+
+	var source *net.IPAddr
+	if len(options.SourceInterface) > 0 {
+		// skip error and using auto-select
+		_, address := parseAddr(options.SourceInterface)
+		source, _ = net.ResolveIPAddr("ip", address)
 	}
 
 	if options.TCPDialer == nil {
 		options.TCPDialer = &net.Dialer{
-			Timeout: options.PacketSendingTimeout,
+			Timeout:   options.PacketSendingTimeout,
+			LocalAddr: localAddr("tcp", source),
 		}
 	}
 
 	if options.UDPDialer == nil {
-		var addr net.Addr
-		if len(options.SourceInterface) > 0 {
-			// skip error and using auto-select
-			addr, _ = net.ResolveUDPAddr("udp", options.SourceInterface)
-		}
-
 		options.UDPDialer = &net.Dialer{
 			Timeout:   options.PacketSendingTimeout,
-			LocalAddr: addr,
+			LocalAddr: localAddr("udp", source),
 		}
 	}
 
@@ -72,6 +81,41 @@ func NewPacketLossAnalyzer(options *PacketLossAnalyzerOptions) *PacketLossAnalyz
 	}
 }
 
+// NewPacketLossAnalyzer creates a packet loss analyzer whose default dialers
+// connect like the client, from its source address, with its resolver and over
+// its address family. The UDP packets cannot be sent through a proxy, so the
+// analyzer of a client with a proxy fails with ErrProxyUnsupported.
+func (s *Speedtest) NewPacketLossAnalyzer(options *PacketLossAnalyzerOptions) *PacketLossAnalyzer {
+	if options == nil {
+		options = &PacketLossAnalyzerOptions{}
+	}
+
+	timeout := cmp.Or(options.PacketSendingTimeout, defaultPacketSendingTimeout)
+
+	if options.TCPDialer == nil && s.tcpDialer != nil {
+		dialer := *s.tcpDialer
+		dialer.Timeout = timeout
+		options.TCPDialer = &dialer
+	}
+
+	if options.UDPDialer == nil && s.udpDialer != nil {
+		dialer := *s.udpDialer
+		dialer.Timeout = timeout
+		options.UDPDialer = &dialer
+	}
+
+	options.IPFamily = cmp.Or(options.IPFamily, s.config.IPFamily)
+
+	analyzer := NewPacketLossAnalyzer(options)
+	analyzer.client = s
+
+	if s.proxied() {
+		analyzer.err = fmt.Errorf("%w: %w", transport.ErrUnsupported, ErrProxyUnsupported)
+	}
+
+	return analyzer
+}
+
 // RunMulti Mix all servers to get the average packet loss.
 func (pla *PacketLossAnalyzer) RunMulti(hosts []string) (*transport.PLoss, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), pla.options.SamplingDuration)
@@ -132,17 +176,57 @@ func (pla *PacketLossAnalyzer) Run(host string, callback func(packetLoss *transp
 }
 
 // RunWithContext performs packet loss analysis on a single host with context.
+// An analyzer created by a client sends the start, the updates and the end of
+// the analysis to the observers of the client.
 func (pla *PacketLossAnalyzer) RunWithContext(
 	ctx context.Context,
 	host string,
 	callback func(packetLoss *transport.PLoss),
 ) error {
-	samplerClient, err := transport.NewClient(pla.options.TCPDialer)
+	if pla.client == nil {
+		return pla.runWithContext(ctx, host, callback)
+	}
+
+	start := time.Now()
+	emit := func(event Event) {
+		event.Host = host
+		event.Phase = PhasePacketLoss
+		pla.client.Emit(event)
+	}
+
+	emit(Event{Type: EventPhaseStarted, Time: start})
+
+	err := pla.runWithContext(ctx, host, func(packetLoss *transport.PLoss) {
+		emit(Event{Type: EventPacketLoss, PacketLoss: packetLoss})
+
+		if callback != nil {
+			callback(packetLoss)
+		}
+	})
+
+	emit(Event{Type: EventPhaseFinished, Elapsed: time.Since(start), Err: err})
+
+	return err
+}
+
+func (pla *PacketLossAnalyzer) runWithContext(
+	ctx context.Context,
+	host string,
+	callback func(packetLoss *transport.PLoss),
+) error {
+	if pla.err != nil {
+		return pla.err
+	}
+
+	samplerClient, err := transport.NewClient(familyDialer{dialer: pla.options.TCPDialer, family: pla.options.IPFamily})
 	if err != nil {
 		return transport.ErrUnsupported
 	}
 
-	senderClient, err := transport.NewPacketLossSender(samplerClient.ID(), pla.options.UDPDialer)
+	senderClient, err := transport.NewPacketLossSender(
+		samplerClient.ID(),
+		familyDialer{dialer: pla.options.UDPDialer, family: pla.options.IPFamily},
+	)
 	if err != nil {
 		return transport.ErrUnsupported
 	}
@@ -46,13 +46,7 @@ func (s *Speedtest) JSON(servers Servers) ([]byte, error) {
 
 // JSONL outputs a single server result in JSON format.
 func (s *Speedtest) JSONL(server *Server) ([]byte, error) {
-	data, err := json.Marshal(
-		singleServerOutput{
-			Timestamp: outputTime(time.Now()),
-			UserInfo:  s.User,
-			Server:    server,
-		},
-	)
+	data, err := json.Marshal(s.NewResult(server))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal server result to JSON: %w", err)
 	}
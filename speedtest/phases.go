@@ -0,0 +1,153 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// PhaseTimings breaks the HTTP requests of a test down into their phases. Every
+// phase is the median over the requests that went through it, so DNS, Connect
+// and TLS only cover the requests that opened a new connection.
+type PhaseTimings struct {
+	DNS      time.Duration `json:"dns"`      // DNS lookup, zero for IP addresses
+	Connect  time.Duration `json:"connect"`  // TCP connect
+	TLS      time.Duration `json:"tls"`      // TLS handshake, zero without TLS
+	TTFB     time.Duration `json:"ttfb"`     // from the written request to the first response byte
+	Requests int           `json:"requests"` // number of traced requests
+}
+
+// String returns the phases in the format of the latency results.
+func (p *PhaseTimings) String() string {
+	return fmt.Sprintf("DNS: %v Connect: %v TLS: %v TTFB: %v", p.DNS, p.Connect, p.TLS, p.TTFB)
+}
+
+// phaseRecorder collects the phase durations of HTTP requests.
+type phaseRecorder struct {
+	mu      sync.Mutex
+	dns     []int64
+	connect []int64
+	tls     []int64
+	ttfb    []int64
+}
+
+type phaseRecorderKey struct{}
+
+// withPhaseRecorder returns a context tracing the phases of the HTTP requests
+// made with it to the recorder.
+func withPhaseRecorder(ctx context.Context, recorder *phaseRecorder) context.Context {
+	return context.WithValue(ctx, phaseRecorderKey{}, recorder)
+}
+
+// tracePhases returns the context with a trace recording the phases of a single
+// request to the recorder of the context, if any.
+func tracePhases(ctx context.Context) context.Context {
+	recorder, _ := ctx.Value(phaseRecorderKey{}).(*phaseRecorder)
+	if recorder == nil {
+		return ctx
+	}
+
+	return httptrace.WithClientTrace(ctx, recorder.trace())
+}
+
+// trace returns a client trace recording the phases to the recorder. The trace
+// may be reused by sequential requests, like the ping requests, as every
+// request starts over in GetConn.
+func (r *phaseRecorder) trace() *httptrace.ClientTrace {
+	var (
+		mu                                             sync.Mutex
+		dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	)
+
+	begin := func(start *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// dual-stack dials race several connects, the phase starts with the first.
+		if start.IsZero() {
+			*start = time.Now()
+		}
+	}
+
+	end := func(start *time.Time, samples *[]int64) {
+		mu.Lock()
+		begun := *start
+		*start = time.Time{}
+		mu.Unlock()
+
+		if !begun.IsZero() {
+			r.record(samples, time.Since(begun))
+		}
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(_ string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			dnsStart, connectStart, tlsStart, wroteRequest = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+		},
+		DNSStart: func(_ httptrace.DNSStartInfo) { begin(&dnsStart) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				end(&dnsStart, &r.dns)
+			}
+		},
+		ConnectStart: func(_, _ string) { begin(&connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				end(&connectStart, &r.connect)
+			}
+		},
+		TLSHandshakeStart: func() { begin(&tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				end(&tlsStart, &r.tls)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				begin(&wroteRequest)
+			}
+		},
+		GotFirstResponseByte: func() { end(&wroteRequest, &r.ttfb) },
+	}
+}
+
+// record adds a sample of a phase.
+func (r *phaseRecorder) record(samples *[]int64, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	*samples = append(*samples, elapsed.Nanoseconds())
+}
+
+// timings returns the median of every phase, or nil if no request got a
+// response.
+func (r *phaseRecorder) timings() *PhaseTimings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.ttfb) == 0 {
+		return nil
+	}
+
+	medianOf := func(samples []int64) time.Duration {
+		if len(samples) == 0 {
+			return 0
+		}
+
+		return time.Duration(median(samples))
+	}
+
+	return &PhaseTimings{
+		DNS:      medianOf(r.dns),
+		Connect:  medianOf(r.connect),
+		TLS:      medianOf(r.tls),
+		TTFB:     medianOf(r.ttfb),
+		Requests: len(r.ttfb),
+	}
+}
@@ -0,0 +1,66 @@
+package speedtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseRecorder_timings(t *testing.T) {
+	t.Parallel()
+
+	recorder := &phaseRecorder{}
+	assert.Nil(t, recorder.timings())
+
+	recorder.record(&recorder.dns, 2*time.Millisecond)
+	recorder.record(&recorder.connect, 4*time.Millisecond)
+
+	for _, ttfb := range []time.Duration{30, 10, 20} {
+		recorder.record(&recorder.ttfb, ttfb*time.Millisecond)
+	}
+
+	assert.Equal(t, &PhaseTimings{
+		DNS:      2 * time.Millisecond,
+		Connect:  4 * time.Millisecond,
+		TTFB:     20 * time.Millisecond,
+		Requests: 3,
+	}, recorder.timings())
+	assert.Equal(t, "DNS: 2ms Connect: 4ms TLS: 0s TTFB: 20ms", recorder.timings().String())
+}
+
+func TestServer_Phases(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	_, port, _ := strings.Cut(host, ":")
+
+	client := New(WithUserConfig(&UserConfig{MaxConnections: 1}))
+	client.SetCaptureTime(200 * time.Millisecond)
+
+	target, err := client.CustomServer("http://localhost:" + port)
+	require.NoError(t, err)
+
+	require.NoError(t, target.PingTest(nil))
+	require.NotNil(t, target.PingPhases)
+
+	// the connection of the warm-up request is reused by the pings.
+	phases := target.PingPhases
+	assert.Equal(t, 11, phases.Requests)
+	assert.Positive(t, phases.Connect)
+	assert.Positive(t, phases.TTFB)
+	assert.Zero(t, phases.TLS)
+
+	// pings outside of PingTest, like the ones under load, are not recorded.
+	_, err = target.HTTPPing(context.Background(), 1, 0, nil)
+	require.NoError(t, err)
+	assert.Same(t, phases, target.PingPhases)
+
+	require.NoError(t, target.DownloadTest())
+	require.NotNil(t, target.DownloadPhases)
+	assert.Positive(t, target.DownloadPhases.Requests)
+	assert.Positive(t, target.DownloadPhases.TTFB)
+}
@@ -0,0 +1,227 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	// ErrInvalidProxy is returned for proxy URLs that cannot be used.
+	ErrInvalidProxy = errors.New("invalid proxy")
+	// ErrProxyUnsupported is returned by the tests that cannot be sent through
+	// a proxy, the ICMP ping and the UDP packets of the packet loss analyzer.
+	ErrProxyUnsupported = errors.New("not supported through a proxy")
+
+	errProxyRefused = errors.New("proxy refused the connection")
+)
+
+// parseProxy parses the URL of an HTTP, HTTPS or SOCKS5 proxy. Credentials are
+// given as the user info of the URL.
+func parseProxy(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		// the error of url.Parse repeats the URL, credentials included.
+		return nil, fmt.Errorf("%w: failed to parse the URL", ErrInvalidProxy)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf(
+			"%w %q: the scheme must be one of http/https/socks5/socks5h",
+			ErrInvalidProxy,
+			proxyURL.Redacted(),
+		)
+	}
+
+	if len(proxyURL.Hostname()) == 0 {
+		return nil, fmt.Errorf("%w %q: the host is missing", ErrInvalidProxy, proxyURL.Redacted())
+	}
+
+	return proxyURL, nil
+}
+
+// proxyDialer dials TCP connections through a proxy, by the CONNECT method of
+// HTTP proxies or the CONNECT command of SOCKS5 proxies. Names are resolved by
+// the proxy, like net/http does.
+type proxyDialer struct {
+	proxy   *url.URL
+	err     error               // the proxy could not be used, every dial fails
+	socks   proxy.ContextDialer // the dialer of SOCKS5 proxies
+	forward *net.Dialer
+}
+
+// newProxyDialer creates a dialer connecting through the proxy with the forward
+// dialer. A nil proxy fails every dial with err.
+func newProxyDialer(proxyURL *url.URL, err error, forward *net.Dialer) *proxyDialer {
+	dialer := &proxyDialer{proxy: proxyURL, err: err, forward: forward}
+	if err != nil {
+		return dialer
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, forward)
+		if err != nil {
+			dialer.err = fmt.Errorf("%w %q: %w", ErrInvalidProxy, proxyURL.Redacted(), err)
+
+			return dialer
+		}
+
+		// the SOCKS5 dialers of x/net always support contexts.
+		dialer.socks, _ = socks.(proxy.ContextDialer)
+	default:
+	}
+
+	return dialer
+}
+
+// DialContext connects to the address through the proxy.
+func (d *proxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrProxyUnsupported, network)
+	}
+
+	if d.socks != nil {
+		conn, err := d.socks.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect through proxy: %w", err)
+		}
+
+		return conn, nil
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", proxyAddress(d.proxy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial proxy: %w", err)
+	}
+
+	// the handshake observes the context, the connection afterwards does not.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	conn, err = d.handshake(conn, address)
+	if err != nil {
+		_ = conn.Close()
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to connect through proxy: %w", ctx.Err())
+		}
+
+		return nil, fmt.Errorf("failed to connect through proxy: %w", err)
+	}
+
+	if !stop() {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("failed to connect through proxy: %w", ctx.Err())
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// handshake asks the HTTP proxy to connect to the address and returns the tunnel.
+func (d *proxyDialer) handshake(conn net.Conn, address string) (net.Conn, error) {
+	switch d.proxy.Scheme {
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: d.proxy.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		})
+
+		return httpConnect(tlsConn, d.proxy.User, address)
+	default:
+		return httpConnect(conn, d.proxy.User, address)
+	}
+}
+
+// proxyAddress returns the host and port of the proxy, with the default port
+// of its scheme if none is given.
+func proxyAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if len(port) == 0 {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// httpConnect opens a tunnel to the address with the CONNECT method.
+func httpConnect(conn net.Conn, user *url.Userinfo, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	err := req.Write(conn)
+	if err != nil {
+		return conn, fmt.Errorf("failed to write CONNECT request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return conn, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("%w: %s", errProxyRefused, resp.Status)
+	}
+
+	// the server may already have sent data behind the response.
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were read into a buffer.
+type bufferedConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+}
+
+// Read reads from the buffer before the connection.
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
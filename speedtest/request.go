@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"path"
 	"strings"
@@ -28,6 +30,14 @@ var (
 	ulSizes = [...]int{100, 300, 500, 800, 1000, 1500, 2500, 3000, 3500, 4000} // kB
 )
 
+const (
+	defaultDialTimeout = 30 * time.Second
+
+	// downloadBytesPerPixel approximates the size of the random JPEG download
+	// files, e.g. random350x350.jpg is about 245KB on the public servers.
+	downloadBytesPerPixel = 2
+)
+
 // ErrConnectTimeout is returned when server connection times out.
 var ErrConnectTimeout = errors.New("server connect timeout")
 
@@ -40,29 +50,44 @@ var (
 
 func (s *Server) multiTestContext(
 	ctx context.Context,
+	phase Phase,
 	servers Servers,
 	register registerFunc,
 	requestFunc func(context.Context, *Server, int) error,
 	handlerName string,
 	getRate getRateFunc,
 	setSpeed func(ByteRate),
-) error {
+) (err error) {
 	if s == nil {
 		return ErrServerNil
 	}
 
+	ctx, finish := s.startPhase(ctx, phase)
+	defer func() { finish(err) }()
+
 	availableServers := servers.Available()
 	if availableServers.Len() == 0 {
 		return ErrNoAvailableServers
 	}
 
+	for _, server := range *availableServers {
+		err = server.upgradeHTTPS(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	mainIDIndex := 0
 
 	var testDirection *TestDirection
 
-	_context, cancel := context.WithCancel(ctx)
+	// the connections to all servers serve the test of this server.
+	_context, cancel := context.WithCancel(withConnectionInfo(ctx, s.connectionInfo()))
 	defer cancel()
 
+	_context, closePool := withTransportPool(_context)
+	defer closePool()
+
 	var (
 		errorTimes   int64
 		requestTimes int64
@@ -74,14 +99,16 @@ func (s *Server) multiTestContext(
 		}
 
 		server := availableServer
-		dbg.Printf("Register %s Handler: %s\n", handlerName, server.URL)
+		s.Context.dbg.Printf("Register %s Handler: %s\n", handlerName, server.URL)
 
 		testDirection = register(func() {
 			atomic.AddInt64(&requestTimes, 1)
 
+			// the requests cut short by the end of the test did not fail.
 			err := requestFunc(_context, server, 3)
-			if err != nil {
+			if err != nil && _context.Err() == nil && !errors.Is(err, errDataBudget) {
 				atomic.AddInt64(&errorTimes, 1)
+				emitChunkError(_context, err)
 			}
 		})
 	}
@@ -90,15 +117,39 @@ func (s *Server) multiTestContext(
 		return ErrUninitializedManager
 	}
 
-	testDirection.Start(cancel, mainIDIndex) // block here
+	start := time.Now()
+	sampler := s.sampleLoadedLatency(ctx)
+
+	testDirection.StartContext(ctx, cancel, mainIDIndex) // block here
+	s.recordRateSamples(testDirection)
+	sampler.stop(s, testDirection.Direction())
+	s.recordBudget(testDirection)
 
-	rate := ByteRate(getRate())
+	rate := budgetRate(ByteRate(getRate()), testDirection, time.Since(start))
 	setSpeed(rate)
 
 	if rate == 0 && float64(errorTimes)/float64(requestTimes) > 0.1 {
 		setSpeed(-1) // N/A
 	}
 
+	return interrupted(ctx, testDirection)
+}
+
+// emitChunkError sends the error of a request of a transfer test, unless the
+// test is over and the request was canceled by its end.
+func emitChunkError(ctx context.Context, err error) {
+	if ctx.Err() == nil {
+		emitterFrom(ctx)(Event{Type: EventChunkError, Err: err})
+	}
+}
+
+// interrupted returns the error of the context if it stopped the test of the
+// direction before its end. The results measured so far are kept.
+func interrupted(ctx context.Context, testDirection *TestDirection) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s test interrupted: %w", strings.ToLower(testDirection.Direction().String()), ctx.Err())
+	}
+
 	return nil
 }
 
@@ -108,11 +159,19 @@ func (s *Server) MultiDownloadTestContext(ctx context.Context, servers Servers)
 		return ErrServerNil
 	}
 
+	if s.Context == nil {
+		return ErrUninitializedManager
+	}
+
+	recorder := &phaseRecorder{}
+	defer func() { s.DownloadPhases = recorder.timings() }()
+
 	return s.multiTestContext(
-		ctx,
+		withPhaseRecorder(ctx, recorder),
+		PhaseDownload,
 		servers,
 		s.Context.RegisterDownloadHandler,
-		downloadRequest,
+		s.Context.downloadRequestFunc(),
 		"Download",
 		s.Context.GetEWMADownloadRate,
 		func(rate ByteRate) { s.DLSpeed = rate },
@@ -125,11 +184,16 @@ func (s *Server) MultiUploadTestContext(ctx context.Context, servers Servers) er
 		return ErrServerNil
 	}
 
+	if s.Context == nil {
+		return ErrUninitializedManager
+	}
+
 	return s.multiTestContext(
 		ctx,
+		PhaseUpload,
 		servers,
 		s.Context.RegisterUploadHandler,
-		uploadRequest,
+		s.Context.uploadRequestFunc(),
 		"Upload",
 		s.Context.GetEWMAUploadRate,
 		func(rate ByteRate) { s.ULSpeed = rate },
@@ -142,7 +206,7 @@ func (s *Server) DownloadTest() error {
 		return ErrServerNil
 	}
 
-	return s.downloadTestContext(context.Background(), downloadRequest)
+	return s.DownloadTestContext(context.Background())
 }
 
 // DownloadTestContext executes the test to measure download speed, observing the given context.
@@ -151,18 +215,23 @@ func (s *Server) DownloadTestContext(ctx context.Context) error {
 		return ErrServerNil
 	}
 
-	return s.downloadTestContext(ctx, downloadRequest)
+	if s.Context == nil {
+		return ErrUninitializedManager
+	}
+
+	return s.downloadTestContext(ctx, s.Context.downloadRequestFunc())
 }
 
 func (s *Server) testContext(
 	ctx context.Context,
+	phase Phase,
 	requestFunc func(context.Context, *Server, int) error,
 	size int,
 	register registerFunc,
 	getRate getRateFunc,
 	setSpeed func(ByteRate),
 	setDuration func(*time.Duration),
-) error {
+) (err error) {
 	if s == nil {
 		return ErrServerNil
 	}
@@ -171,25 +240,45 @@ func (s *Server) testContext(
 		return ErrUninitializedManager
 	}
 
+	ctx, finish := s.startPhase(ctx, phase)
+	defer func() { finish(err) }()
+
+	err = s.upgradeHTTPS(ctx)
+	if err != nil {
+		return err
+	}
+
 	var (
 		errorTimes   int64
 		requestTimes int64
 	)
 
 	start := time.Now()
-	_context, cancel := context.WithCancel(ctx)
-	register(func() {
+	_context, cancel := context.WithCancel(withConnectionInfo(ctx, s.connectionInfo()))
+
+	_context, closePool := withTransportPool(_context)
+	defer closePool()
+
+	testDirection := register(func() {
 		atomic.AddInt64(&requestTimes, 1)
 
+		// the requests cut short by the end of the test did not fail.
 		err := requestFunc(_context, s, size)
-		if err != nil {
+		if err != nil && _context.Err() == nil && !errors.Is(err, errDataBudget) {
 			atomic.AddInt64(&errorTimes, 1)
+			emitChunkError(_context, err)
 		}
-	}).Start(cancel, 0)
+	})
+	sampler := s.sampleLoadedLatency(ctx)
+	testDirection.StartContext(ctx, cancel, 0)
 
 	duration := time.Since(start)
 
-	rate := ByteRate(getRate())
+	s.recordRateSamples(testDirection)
+	sampler.stop(s, testDirection.Direction())
+	s.recordBudget(testDirection)
+
+	rate := budgetRate(ByteRate(getRate()), testDirection, duration)
 	if rate == 0 && float64(errorTimes)/float64(requestTimes) > 0.1 {
 		rate = -1 // N/A
 	}
@@ -198,7 +287,27 @@ func (s *Server) testContext(
 	setDuration(&duration)
 	s.testDurationTotalCount()
 
-	return nil
+	return interrupted(ctx, testDirection)
+}
+
+// recordBudget records whether the test of a direction was stopped by the data budget.
+func (s *Server) recordBudget(testDirection *TestDirection) {
+	switch testDirection.Direction() {
+	case DirectionDownload:
+		s.DLBudgetLimited = testDirection.BudgetLimited()
+	case DirectionUpload:
+		s.ULBudgetLimited = testDirection.BudgetLimited()
+	}
+}
+
+// budgetRate falls back to the average rate when the data budget was used up
+// before the first rate capture.
+func budgetRate(rate ByteRate, testDirection *TestDirection, duration time.Duration) ByteRate {
+	if rate != 0 || !testDirection.BudgetLimited() || duration <= 0 {
+		return rate
+	}
+
+	return ByteRate(float64(testDirection.TestDataVolume()) / duration.Seconds())
 }
 
 func (s *Server) downloadTestContext(ctx context.Context, downloadRequest downloadFunc) error {
@@ -206,8 +315,12 @@ func (s *Server) downloadTestContext(ctx context.Context, downloadRequest downlo
 		return ErrServerNil
 	}
 
+	recorder := &phaseRecorder{}
+	defer func() { s.DownloadPhases = recorder.timings() }()
+
 	return s.testContext(
-		ctx,
+		withPhaseRecorder(ctx, recorder),
+		PhaseDownload,
 		downloadRequest,
 		3,
 		s.Context.RegisterDownloadHandler,
@@ -223,7 +336,7 @@ func (s *Server) UploadTest() error {
 		return ErrServerNil
 	}
 
-	return s.uploadTestContext(context.Background(), uploadRequest)
+	return s.UploadTestContext(context.Background())
 }
 
 // UploadTestContext executes the test to measure upload speed, observing the given context.
@@ -232,7 +345,11 @@ func (s *Server) UploadTestContext(ctx context.Context) error {
 		return ErrServerNil
 	}
 
-	return s.uploadTestContext(ctx, uploadRequest)
+	if s.Context == nil {
+		return ErrUninitializedManager
+	}
+
+	return s.uploadTestContext(ctx, s.Context.uploadRequestFunc())
 }
 
 func (s *Server) uploadTestContext(ctx context.Context, uploadRequest uploadFunc) error {
@@ -242,6 +359,7 @@ func (s *Server) uploadTestContext(ctx context.Context, uploadRequest uploadFunc
 
 	return s.testContext(
 		ctx,
+		PhaseUpload,
 		uploadRequest,
 		4,
 		s.Context.RegisterUploadHandler,
@@ -262,16 +380,15 @@ func downloadRequest(ctx context.Context, server *Server, writer int) error {
 
 	size := dlSizes[writer]
 
-	u, err := url.Parse(server.URL)
+	u, err := server.downloadBaseURL()
 	if err != nil {
 		return fmt.Errorf("failed to parse download URL: %w", err)
 	}
 
-	u.Path = path.Dir(u.Path)
 	xdlURL := u.JoinPath(fmt.Sprintf("random%dx%d.jpg", size, size)).String()
-	dbg.Printf("XdlURL: %s\n", xdlURL)
+	server.Context.dbg.Printf("XdlURL: %s\n", xdlURL)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, xdlURL, nil)
+	req, err := http.NewRequestWithContext(tracePhases(withServerRequest(ctx)), http.MethodGet, xdlURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create download HTTP request: %w", err)
 	}
@@ -283,10 +400,12 @@ func downloadRequest(ctx context.Context, server *Server, writer int) error {
 
 	defer func() { _ = resp.Body.Close() }()
 
-	return fmt.Errorf(
-		"failed to download data: %w",
-		server.Context.NewChunk().DownloadHandler(resp.Body),
-	)
+	err = server.Context.NewChunk().DownloadHandler(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download data: %w", err)
+	}
+
+	return nil
 }
 
 func uploadRequest(ctx context.Context, server *Server, writer int) error {
@@ -298,17 +417,16 @@ func uploadRequest(ctx context.Context, server *Server, writer int) error {
 		return ErrUninitializedManager
 	}
 
-	size := ulSizes[writer]
-	chunkSize := int64(size*100-51) * 10
+	chunkSize := uploadChunkSize(ulSizes[writer])
 	dc := server.Context.NewChunk().UploadHandler(chunkSize)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, io.NopCloser(dc))
+	req, err := http.NewRequestWithContext(withServerRequest(ctx), http.MethodPost, server.URL, io.NopCloser(dc))
 	if err != nil {
 		return fmt.Errorf("failed to create upload HTTP request: %w", err)
 	}
 
 	req.ContentLength = chunkSize
-	dbg.Printf("Len=%d, XulURL: %s\n", req.ContentLength, server.URL)
+	server.Context.dbg.Printf("Len=%d, XulURL: %s\n", req.ContentLength, server.URL)
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	resp, err := server.Context.doer.Do(req)
@@ -327,13 +445,200 @@ func uploadRequest(ctx context.Context, server *Server, writer int) error {
 	return nil
 }
 
+// downloadChunkSize returns the number of bytes of the random{size}x{size}.jpg
+// download files, the size of a TCP download chunk.
+func downloadChunkSize(size int) int64 {
+	return int64(size) * int64(size) * downloadBytesPerPixel
+}
+
+// uploadChunkSize returns the number of bytes sent by a single upload request.
+func uploadChunkSize(size int) int64 {
+	return int64(size*100-51) * 10
+}
+
+// downloadRequestFunc returns the download request implementation selected by TransferMode.
+func (s *Speedtest) downloadRequestFunc() downloadFunc {
+	if s.config != nil && s.config.TransferMode == TCP {
+		return tcpDownloadRequest
+	}
+
+	return downloadRequest
+}
+
+// uploadRequestFunc returns the upload request implementation selected by TransferMode.
+func (s *Speedtest) uploadRequestFunc() uploadFunc {
+	if s.config != nil && s.config.TransferMode == TCP {
+		return tcpUploadRequest
+	}
+
+	return uploadRequest
+}
+
+// tcpDownloadRequest downloads a chunk of the size of the HTTP download files
+// with the DOWNLOAD command of the speedtest.net TCP protocol, over the
+// connection of the worker to the server host.
+func tcpDownloadRequest(ctx context.Context, server *Server, writer int) error {
+	pool := transportPoolFrom(ctx)
+
+	client, err := pool.get(ctx, server)
+	if err != nil {
+		return err
+	}
+
+	// abort the transfer as soon as the test is finished.
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+
+	chunkSize := downloadChunkSize(dlSizes[writer])
+	server.Context.dbg.Printf("Len=%d, TCP download: %s\n", chunkSize, server.Host)
+
+	err = tcpDownload(server, client, chunkSize)
+	pool.release(server, client, stop() && err == nil)
+
+	return err
+}
+
+// tcpDownload downloads a chunk of the size over the connection.
+func tcpDownload(server *Server, client *transport.Client, chunkSize int64) error {
+	reader, err := client.Download(chunkSize)
+	if err != nil {
+		return fmt.Errorf("failed to request TCP download: %w", err)
+	}
+
+	err = server.Context.NewChunk().DownloadHandler(reader)
+	if err != nil {
+		return fmt.Errorf("failed to download data: %w", err)
+	}
+
+	return nil
+}
+
+// tcpUploadRequest uploads a chunk with the UPLOAD command of the
+// speedtest.net TCP protocol, over the connection of the worker to the server
+// host.
+func tcpUploadRequest(ctx context.Context, server *Server, writer int) error {
+	pool := transportPoolFrom(ctx)
+
+	client, err := pool.get(ctx, server)
+	if err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+
+	chunkSize := uploadChunkSize(ulSizes[writer])
+	dc := server.Context.NewChunk().UploadHandler(chunkSize)
+	server.Context.dbg.Printf("Len=%d, TCP upload: %s\n", chunkSize, server.Host)
+
+	_, err = client.Upload(chunkSize, dc)
+	if err != nil {
+		err = fmt.Errorf("failed to upload data: %w", err)
+	}
+
+	pool.release(server, client, stop() && err == nil)
+
+	return err
+}
+
+// tcpHost returns the host:port used by the speedtest.net TCP protocol.
+func (s *Server) tcpHost() (string, error) {
+	if len(s.Host) > 0 {
+		return s.Host, nil
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil || len(u.Host) == 0 {
+		return "", fmt.Errorf("failed to parse server URL for TCP: %w", err)
+	}
+
+	return u.Host, nil
+}
+
+// downloadBaseURL returns the URL of the directory of the download files.
+func (s *Server) downloadBaseURL() (*url.URL, error) {
+	if len(s.DownloadURL) > 0 {
+		return url.Parse(s.DownloadURL)
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Dir(u.Path)
+
+	return u, nil
+}
+
+// latencyURL returns the URL of the latency probe.
+func (s *Server) latencyURL() (string, error) {
+	if len(s.LatencyURL) > 0 {
+		return s.LatencyURL, nil
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "", err
+	}
+
+	if len(u.Host) == 0 {
+		return "", fmt.Errorf("%w: %q", errHostEmpty, s.URL)
+	}
+
+	u.Path = path.Dir(u.Path)
+
+	return u.JoinPath("latency.txt").String(), nil
+}
+
+// connectTransport opens a speedtest.net TCP protocol connection to the server.
+func (s *Server) connectTransport(ctx context.Context) (*transport.Client, error) {
+	if s == nil {
+		return nil, ErrServerNil
+	}
+
+	if s.Context == nil {
+		return nil, ErrUninitializedManager
+	}
+
+	host, err := s.tcpHost()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := s.Context.transportDialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: defaultDialTimeout}
+	}
+
+	client, err := transport.NewClient(dialer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport client: %w", err)
+	}
+
+	emit := emitterFrom(ctx)
+
+	err = client.Connect(ctx, host)
+	if err != nil {
+		emit(Event{Type: EventConnectionFailed, Addr: host, Err: err})
+
+		return nil, fmt.Errorf("failed to connect transport client: %w", err)
+	}
+
+	emit(Event{Type: EventConnectionOpened, Addr: client.RemoteAddr().String()})
+
+	info := connectionInfoFrom(ctx)
+	info.recordAddrs(client.LocalAddr(), client.RemoteAddr())
+	info.recordProxy(s.Context.proxied())
+
+	return client, nil
+}
+
 // PingTest executes test to measure latency.
 func (s *Server) PingTest(callback func(latency time.Duration)) error {
 	return s.PingTestContext(context.Background(), callback)
 }
 
 // PingTestContext executes test to measure latency, observing the given context.
-func (s *Server) PingTestContext(ctx context.Context, callback func(latency time.Duration)) error {
+func (s *Server) PingTestContext(ctx context.Context, callback func(latency time.Duration)) (err error) {
 	if s == nil {
 		return ErrServerNil
 	}
@@ -342,29 +647,44 @@ func (s *Server) PingTestContext(ctx context.Context, callback func(latency time
 		return ErrUninitializedManager
 	}
 
+	ctx, finish := s.startPhase(ctx, PhasePing)
+	defer func() { finish(err) }()
+
+	err = s.upgradeHTTPS(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.IPFamily = s.Context.config.IPFamily
+	s.Source = s.Context.config.Source
+	ctx = withConnectionInfo(ctx, s.connectionInfo())
+
+	recorder := &phaseRecorder{}
+	ctx = withPhaseRecorder(ctx, recorder)
+
+	defer func() { s.PingPhases = recorder.timings() }()
+
 	start := time.Now()
 
-	var (
-		vectorPingResult []int64
-		err              error
-	)
+	emit := emitterFrom(ctx)
+	observed := func(latency time.Duration) {
+		emit(Event{Type: EventPingSample, Latency: latency})
 
-	switch s.Context.config.PingMode {
-	case TCP:
-		vectorPingResult, err = s.TCPPing(ctx, 10, time.Millisecond*200, callback)
-	case ICMP:
-		vectorPingResult, err = s.ICMPPing(ctx, time.Second*4, 10, time.Millisecond*200, callback)
-	case HTTP:
-		vectorPingResult, err = s.HTTPPing(ctx, 10, time.Millisecond*200, callback)
-	default:
-		vectorPingResult, err = s.HTTPPing(ctx, 10, time.Millisecond*200, callback)
+		if callback != nil {
+			callback(latency)
+		}
 	}
 
-	if err != nil || len(vectorPingResult) == 0 {
+	var vectorPingResult []int64
+
+	vectorPingResult, err = s.ping(ctx, 10, time.Millisecond*200, observed)
+
+	// the latencies measured before the context was done are still recorded.
+	if len(vectorPingResult) == 0 {
 		return err
 	}
 
-	dbg.Printf("Before StandardDeviation: %v\n", vectorPingResult)
+	s.Context.dbg.Printf("Before StandardDeviation: %v\n", vectorPingResult)
 	mean, _, std, minLatency, maxLatency := StandardDeviation(vectorPingResult)
 	duration := time.Since(start)
 	s.Latency = time.Duration(mean) * time.Nanosecond
@@ -374,26 +694,78 @@ func (s *Server) PingTestContext(ctx context.Context, callback func(latency time
 	s.TestDuration.Ping = &duration
 	s.testDurationTotalCount()
 
-	return nil
+	return err
 }
 
 // TestAll executes ping, download and upload tests one by one.
 func (s *Server) TestAll() error {
+	return s.TestAllContext(context.Background())
+}
+
+// TestAllContext executes ping, download and upload tests one by one, observing the given context.
+// The result is sent to the observers of the client once all tests succeeded, the error of a
+// failed test is kept in Server.Error.
+func (s *Server) TestAllContext(ctx context.Context) error {
 	if s == nil {
 		return ErrServerNil
 	}
 
-	err := s.PingTest(nil)
+	// the results of a previous run must not be reported again.
+	s.ResetResults()
+
+	err := s.testAll(ctx)
 	if err != nil {
+		s.Error = err.Error()
+
 		return err
 	}
 
-	err = s.DownloadTest()
+	s.Context.EmitResult(s)
+
+	return nil
+}
+
+// testAll executes ping, download and upload tests one by one.
+func (s *Server) testAll(ctx context.Context) error {
+	err := s.PingTestContext(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	return s.UploadTest()
+	err = s.DownloadTestContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.UploadTestContext(ctx)
+}
+
+// sleepContext pauses for the duration, or until the context is done.
+func sleepContext(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// ping pings the server with the configured ping mode, HTTP by default.
+func (s *Server) ping(
+	ctx context.Context,
+	echoTimes int,
+	echoFreq time.Duration,
+	callback func(latency time.Duration),
+) ([]int64, error) {
+	switch s.Context.config.PingMode {
+	case TCP:
+		return s.TCPPing(ctx, echoTimes, echoFreq, callback)
+	case ICMP:
+		return s.ICMPPing(ctx, time.Second*4, echoTimes, echoFreq, callback)
+	default:
+		return s.HTTPPing(ctx, echoTimes, echoFreq, callback)
+	}
 }
 
 // TCPPing performs TCP ping test.
@@ -411,38 +783,28 @@ func (s *Server) TCPPing(
 		return nil, ErrUninitializedManager
 	}
 
-	var pingDst string
-
-	if len(s.Host) == 0 {
-		u, err := url.Parse(s.URL)
-		if err != nil || len(u.Host) == 0 {
-			return nil, fmt.Errorf("failed to parse server URL for TCP ping: %w", err)
-		}
-
-		pingDst = u.Host
-	} else {
-		pingDst = s.Host
-	}
-
 	failTimes := 0
 
-	latencies := make([]int64, 0, echoTimes)
-
-	client, err := transport.NewClient(s.Context.tcpDialer)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create transport client for TCP ping: %w", err)
-	}
+	latencies := make([]int64, 0, min(echoTimes, maxPreallocatedPings))
 
-	err = client.Connect(ctx, pingDst)
+	client, err := s.connectTransport(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect for TCP ping: %w", err)
 	}
 
+	defer func() { _ = client.Close() }()
+
 	for range echoTimes {
+		if ctx.Err() != nil {
+			return latencies, ctx.Err()
+		}
+
 		latency, err := client.PingContext(ctx)
 		if err != nil {
 			failTimes++
 
+			sleepContext(ctx, echoFreq)
+
 			continue
 		}
 
@@ -451,7 +813,7 @@ func (s *Server) TCPPing(
 			callback(time.Duration(latency))
 		}
 
-		time.Sleep(echoFreq)
+		sleepContext(ctx, echoFreq)
 	}
 
 	if failTimes == echoTimes {
@@ -482,19 +844,17 @@ func (s *Server) HTTPPing(
 
 	var contextErr error
 
-	u, err := url.Parse(s.URL)
-	if err != nil || len(u.Host) == 0 {
+	pingDst, err := s.latencyURL()
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse server URL for TCP ping: %w", err)
 	}
 
-	u.Path = path.Dir(u.Path)
-	pingDst := u.JoinPath("latency.txt").String()
-	dbg.Printf("Echo: %s\n", pingDst)
+	s.Context.dbg.Printf("Echo: %s\n", pingDst)
 
 	failTimes := 0
-	latencies := make([]int64, 0, echoTimes+1)
+	latencies := make([]int64, 0, min(echoTimes, maxPreallocatedPings)+1)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingDst, nil)
+	req, err := http.NewRequestWithContext(tracePhases(withServerRequest(ctx)), http.MethodGet, pingDst, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ping HTTP request: %w", err)
 	}
@@ -515,6 +875,8 @@ func (s *Server) HTTPPing(
 
 			failTimes++
 
+			sleepContext(ctx, echoFreq)
+
 			continue
 		}
 
@@ -524,14 +886,14 @@ func (s *Server) HTTPPing(
 		if i > 0 {
 			latency := endTime.Nanoseconds()
 			latencies = append(latencies, latency)
-			dbg.Printf("RTT: %d\n", latency)
+			s.Context.dbg.Printf("RTT: %d\n", latency)
 
 			if callback != nil {
 				callback(endTime)
 			}
 		}
 
-		time.Sleep(echoFreq)
+		sleepContext(ctx, echoFreq)
 	}
 
 	if contextErr != nil {
@@ -553,6 +915,9 @@ func (s *Server) HTTPPing(
 const (
 	PingTimeout        = -1
 	echoOptionDataSize = 32 // `echoMessage` need to change at same time
+	// maxPreallocatedPings bounds the latencies allocated up front, since the
+	// pings under load are only ended by their context.
+	maxPreallocatedPings = 64
 )
 
 // ICMPPing privileged method.
@@ -571,46 +936,64 @@ func (s *Server) ICMPPing(
 		return nil, ErrUninitializedManager
 	}
 
-	latencies := make([]int64, 0, echoTimes)
+	// ICMP cannot be tunneled, the pings would bypass the proxy.
+	if s.Context.proxied() {
+		return nil, fmt.Errorf("failed to ping over ICMP: %w", ErrProxyUnsupported)
+	}
+
+	latencies := make([]int64, 0, min(echoTimes, maxPreallocatedPings))
 
 	u, err := url.ParseRequestURI(s.URL)
 	if err != nil || len(u.Host) == 0 {
 		return nil, fmt.Errorf("failed to parse ICMP URL: %w", err)
 	}
 
-	dbg.Printf("Echo: %s\n", strings.Split(u.Host, ":")[0])
+	addr, err := s.Context.icmpAddr(ctx, u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ICMP address: %w", err)
+	}
 
-	dialContext, err := s.Context.ipDialer.DialContext(
-		ctx,
-		"ip:icmp",
-		strings.Split(u.Host, ":")[0],
-	)
+	s.Context.dbg.Printf("Echo: %s\n", addr)
+
+	echo := icmpEchoOf(addr)
+
+	dialContext, err := s.Context.ipDialer.DialContext(ctx, echo.network, addr.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial ICMP: %w", err)
 	}
 
 	defer func() { _ = dialContext.Close() }()
 
-	icmpData := prepareICMPPacket()
+	// a pending read is unblocked once the context is done.
+	stop := context.AfterFunc(ctx, func() { _ = dialContext.SetDeadline(time.Now()) })
+	defer stop()
+
+	icmpData := prepareICMPPacket(echo.request)
 
 	failTimes := 0
 
 	for i := range echoTimes {
-		latency, err := s.sendOneICMPPing(dialContext, icmpData, i, readTimeout)
+		if ctx.Err() != nil {
+			return latencies, ctx.Err()
+		}
+
+		latency, err := s.sendOneICMPPing(dialContext, echo, icmpData, i, readTimeout)
 		if err != nil {
 			failTimes++
 
+			sleepContext(ctx, echoFreq)
+
 			continue
 		}
 
 		latencies = append(latencies, latency.Nanoseconds())
-		dbg.Printf("1RTT: %s\n", latency)
+		s.Context.dbg.Printf("1RTT: %s\n", latency)
 
 		if callback != nil {
 			callback(latency)
 		}
 
-		time.Sleep(echoFreq)
+		sleepContext(ctx, echoFreq)
 	}
 
 	if failTimes == echoTimes {
@@ -620,9 +1003,57 @@ func (s *Server) ICMPPing(
 	return latencies, nil
 }
 
-func prepareICMPPacket() []byte {
+// icmpEcho describes the echo messages of ICMPv4 or ICMPv6.
+type icmpEcho struct {
+	network string // network the echo messages are sent over
+	request byte   // type of the echo request
+	reply   byte   // type of the echo reply
+	offset  int    // offset of the reply type in a read packet
+}
+
+// icmpEchoOf returns the echo messages of the family of the address. Raw IPv4
+// sockets read the IP header with the reply, raw IPv6 sockets do not.
+func icmpEchoOf(addr netip.Addr) icmpEcho {
+	if addr.Unmap().Is4() {
+		return icmpEcho{network: "ip4:icmp", request: 8, reply: 0, offset: 20}
+	}
+
+	return icmpEcho{network: "ip6:ipv6-icmp", request: 128, reply: 129, offset: 0}
+}
+
+// icmpAddr resolves the host pinged over ICMP to an address of the family the
+// client is forced to, since ICMP differs between IPv4 and IPv6.
+func (s *Speedtest) icmpAddr(ctx context.Context, host string) (netip.Addr, error) {
+	network := "ip"
+
+	switch s.config.IPFamily {
+	case IPFamilyIPv4:
+		network = "ip4"
+	case IPFamilyIPv6:
+		network = "ip6"
+	case IPFamilyAny:
+	}
+
+	resolver := net.DefaultResolver
+	if s.ipDialer != nil && s.ipDialer.Resolver != nil {
+		resolver = s.ipDialer.Resolver
+	}
+
+	addrs, err := resolver.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to look up %s: %w", host, err)
+	}
+
+	if len(addrs) == 0 {
+		return netip.Addr{}, fmt.Errorf("%w: %s over %s", ErrIPFamily, host, s.config.IPFamily)
+	}
+
+	return addrs[0].Unmap(), nil
+}
+
+func prepareICMPPacket(request byte) []byte {
 	icmpData := make([]byte, 8+echoOptionDataSize) // header + data
-	icmpData[0] = 8                                // echo
+	icmpData[0] = request                          // echo
 	icmpData[1] = 0                                // code
 	icmpData[2] = 0                                // checksum
 	icmpData[3] = 0                                // checksum
@@ -646,7 +1077,7 @@ func (s *Server) sendOneICMPPing(dialContext interface {
 	Read(data []byte) (n int, err error)
 	SetDeadline(t time.Time) error
 	Close() error
-}, icmpData []byte, _ int, readTimeout time.Duration,
+}, echo icmpEcho, icmpData []byte, _ int, readTimeout time.Duration,
 ) (time.Duration, error) {
 	// Update checksum and seq
 	icmpData[2] = 0
@@ -665,10 +1096,10 @@ func (s *Server) sendOneICMPPing(dialContext interface {
 		return 0, fmt.Errorf("failed to write ICMP packet: %w", err)
 	}
 
-	buf := make([]byte, 20+echoOptionDataSize+8)
+	buf := make([]byte, echo.offset+echoOptionDataSize+8)
 
 	_, err = dialContext.Read(buf)
-	if err != nil || buf[20] != 0x00 {
+	if err != nil || buf[echo.offset] != echo.reply {
 		return 0, fmt.Errorf("failed to read ICMP response: %w", err)
 	}
 
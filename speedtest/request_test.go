@@ -2,13 +2,42 @@ package speedtest
 
 import (
 	"context"
+	"net"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/server"
 )
 
+// startLocalServer serves a local speedtest server and returns its host:port.
+func startLocalServer(t *testing.T) string {
+	t.Helper()
+
+	lc := &net.ListenConfig{}
+
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	packetConn, err := lc.ListenPacket(context.Background(), "udp", listener.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- server.New(nil).Serve(ctx, listener, packetConn) }()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return listener.Addr().String()
+}
+
 func TestServer_MultiDownloadTestContext(t *testing.T) {
 	type args struct {
 		servers Servers
@@ -286,6 +315,18 @@ func Test_downloadRequest(t *testing.T) {
 	}
 }
 
+func Test_downloadRequest_localServer(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	target, err := New().CustomServer("http://" + host)
+	require.NoError(t, err)
+
+	// a complete chunk is no failed request.
+	require.NoError(t, downloadRequest(context.Background(), target, 0))
+}
+
 func Test_uploadRequest(t *testing.T) {
 	type args struct {
 		s *Server
@@ -411,6 +452,135 @@ func TestAll(t *testing.T) {
 	}
 }
 
+func TestServer_TestAllContext(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	client := New(WithUserConfig(&UserConfig{MaxConnections: 1}))
+	client.SetCaptureTime(time.Minute)
+
+	target, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+
+	// the ping takes about two seconds, the download is left the rest.
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err = target.TestAllContext(ctx)
+
+	// the download is stopped with the context and its partial rate is kept.
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, err.Error(), target.Error)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Positive(t, target.Latency)
+	assert.Positive(t, target.DLSpeed)
+	assert.Zero(t, target.ULSpeed)
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	require.ErrorIs(t, target.PingTestContext(canceled, nil), context.Canceled)
+	require.ErrorIs(t, target.UploadTestContext(canceled), context.Canceled)
+
+	// a successful run clears the failure of the previous one.
+	client.Reset()
+	client.SetCaptureTime(300 * time.Millisecond)
+
+	require.NoError(t, target.TestAllContext(context.Background()))
+	assert.Empty(t, target.Error)
+}
+
+func TestServer_TransferMode(t *testing.T) {
+	host := startLocalServer(t)
+
+	tests := []struct {
+		name string
+		mode Proto
+	}{
+		{name: "http", mode: HTTP},
+		{name: "tcp", mode: TCP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := New(WithUserConfig(&UserConfig{TransferMode: tt.mode, MaxConnections: 2}))
+			client.SetCaptureTime(500 * time.Millisecond)
+
+			target, err := client.CustomServer("http://" + host)
+			require.NoError(t, err)
+
+			require.NoError(t, target.DownloadTest())
+			require.NoError(t, target.UploadTest())
+
+			assert.Positive(t, client.GetTotalDownload())
+			assert.Positive(t, client.GetTotalUpload())
+			assert.Positive(t, float64(target.DLSpeed))
+			assert.Positive(t, float64(target.ULSpeed))
+		})
+	}
+}
+
+func TestServer_MaxDataVolume(t *testing.T) {
+	host := startLocalServer(t)
+
+	const budget = 2 * 1000 * 1000
+
+	recorder := &eventRecorder{}
+	client := New(WithUserConfig(&UserConfig{MaxDataVolume: budget, MaxConnections: 2}), WithObserver(recorder))
+
+	target, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+
+	require.NoError(t, target.DownloadTest())
+	require.NoError(t, target.UploadTest())
+
+	// the requests cut short by the budget did not fail.
+	assert.Empty(t, recorder.of(EventChunkError))
+
+	assert.True(t, target.DLBudgetLimited)
+	assert.True(t, target.ULBudgetLimited)
+	assert.GreaterOrEqual(t, client.GetTotalDownload(), int64(budget))
+	assert.GreaterOrEqual(t, client.GetTotalUpload(), int64(budget))
+	assert.Positive(t, float64(target.DLSpeed))
+	assert.Positive(t, float64(target.ULSpeed))
+	assert.Less(t, *target.TestDuration.Download, DefaultCaptureTime)
+
+	// a second test on the same client is not stopped by the data used before.
+	downloaded := client.GetTotalDownload()
+
+	require.NoError(t, target.DownloadTest())
+	assert.True(t, target.DLBudgetLimited)
+	assert.GreaterOrEqual(t, client.GetTotalDownload()-downloaded, int64(budget))
+	assert.Positive(t, float64(target.DLSpeed))
+}
+
+func Test_uploadRequest_dataBudget(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	for _, mode := range []Proto{HTTP, TCP} {
+		client := New(WithUserConfig(&UserConfig{TransferMode: mode}))
+
+		target, err := client.CustomServer("http://" + host)
+		require.NoError(t, err)
+
+		manager, ok := client.Manager.(*DataManager)
+		require.True(t, ok)
+		manager.upload.budgetLimited.Store(true)
+
+		// an upload cut short by the budget is told apart from a failed one.
+		ctx, closePool := withTransportPool(context.Background())
+		err = client.uploadRequestFunc()(ctx, target, 0)
+		closePool()
+
+		require.ErrorIs(t, err, errDataBudget, mode)
+	}
+}
+
 func TestServer_TCPPing(t *testing.T) {
 	type args struct {
 		echoTimes int
@@ -553,6 +723,75 @@ func TestServer_ICMPPing(t *testing.T) {
 	}
 }
 
+func Test_icmpEchoOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		addr netip.Addr
+		want icmpEcho
+	}{
+		{
+			name: "IPv4",
+			addr: netip.MustParseAddr("192.0.2.1"),
+			want: icmpEcho{network: "ip4:icmp", request: 8, reply: 0, offset: 20},
+		},
+		{
+			name: "IPv4-mapped IPv6",
+			addr: netip.MustParseAddr("::ffff:192.0.2.1"),
+			want: icmpEcho{network: "ip4:icmp", request: 8, reply: 0, offset: 20},
+		},
+		{
+			name: "IPv6",
+			addr: netip.MustParseAddr("2001:db8::1"),
+			want: icmpEcho{network: "ip6:ipv6-icmp", request: 128, reply: 129, offset: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, icmpEchoOf(tt.addr))
+		})
+	}
+}
+
+func TestSpeedtest_icmpAddr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		family  IPFamily
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{name: "any IPv4", family: IPFamilyAny, host: "127.0.0.1", want: "127.0.0.1"},
+		{name: "any IPv6", family: IPFamilyAny, host: "::1", want: "::1"},
+		{name: "IPv4", family: IPFamilyIPv4, host: "127.0.0.1", want: "127.0.0.1"},
+		{name: "IPv4 without address", family: IPFamilyIPv4, host: "::1", wantErr: true},
+		{name: "IPv6", family: IPFamilyIPv6, host: "::1", want: "::1"},
+		{name: "IPv6 without address", family: IPFamilyIPv6, host: "127.0.0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := New(WithUserConfig(&UserConfig{IPFamily: tt.family}))
+
+			addr, err := client.icmpAddr(context.Background(), tt.host)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, addr.String())
+		})
+	}
+}
+
 func Test_checkSum(t *testing.T) {
 	type args struct {
 		data []byte
@@ -630,3 +869,35 @@ func TestStandardDeviation(t *testing.T) {
 		})
 	}
 }
+
+func Test_downloadChunkSize(t *testing.T) {
+	t.Parallel()
+
+	// random350x350.jpg is about 245KB.
+	assert.Equal(t, int64(245000), downloadChunkSize(350))
+	assert.Equal(t, int64(32000000), downloadChunkSize(dlSizes[len(dlSizes)-1]))
+}
+
+func TestServer_TCPTransfer_connections(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	recorder := &eventRecorder{}
+
+	client := New(WithUserConfig(&UserConfig{TransferMode: TCP, MaxConnections: 2}), WithObserver(recorder))
+	client.SetCaptureTime(500 * time.Millisecond)
+
+	target, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+	require.NoError(t, target.DownloadTest())
+	require.NoError(t, target.UploadTest())
+
+	assert.Positive(t, target.DLSpeed)
+	assert.Positive(t, target.ULSpeed)
+	assert.Empty(t, recorder.of(EventChunkError))
+
+	// every worker keeps its connection for the whole test.
+	opened := recorder.of(EventConnectionOpened)
+	assert.NotEmpty(t, opened)
+	assert.LessOrEqual(t, len(opened), 2*2)
+}
@@ -0,0 +1,286 @@
+package speedtest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategy decides which server SelectServer picks.
+type Strategy string
+
+const (
+	// StrategyLatency picks the server with the lowest latency of the single
+	// ping of the fetched server list, like FindServer.
+	StrategyLatency Strategy = "latency"
+	// StrategyBestOf picks the server with the lowest latency of several pings.
+	StrategyBestOf Strategy = "best-of"
+	// StrategyMedian picks the server with the lowest median latency of several pings.
+	StrategyMedian Strategy = "median"
+	// StrategyNearest picks the server with the lowest distance.
+	StrategyNearest Strategy = "nearest"
+	// StrategyProbe ranks the servers like StrategyMedian and picks the one of
+	// the best ranked servers with the highest throughput in a short download.
+	StrategyProbe Strategy = "probe"
+)
+
+const (
+	// DefaultSelectionPings is the default number of pings of StrategyBestOf,
+	// StrategyMedian and StrategyProbe.
+	DefaultSelectionPings = 5
+	// DefaultSelectionTopK is the default number of servers StrategyProbe probes.
+	DefaultSelectionTopK = 3
+	// DefaultProbeDuration is the default duration of a StrategyProbe download.
+	DefaultProbeDuration = 2 * time.Second
+
+	selectionPingTimeout = 10 * time.Second
+	probeImageSize       = 1000
+)
+
+// Strategies are the supported selection strategies.
+var Strategies = []Strategy{StrategyLatency, StrategyBestOf, StrategyMedian, StrategyNearest, StrategyProbe}
+
+var (
+	// ErrUnknownStrategy is returned for a selection strategy that is not supported.
+	ErrUnknownStrategy = errors.New("unknown selection strategy")
+
+	errProbeFailed = errors.New("download probe failed")
+)
+
+// Selection configures SelectServer. Zero values select the lowest latency
+// server of all servers.
+type Selection struct {
+	Strategy      Strategy
+	Pings         int           // pings per server, defaults to DefaultSelectionPings
+	TopK          int           // servers probed by StrategyProbe, defaults to DefaultSelectionTopK
+	ProbeDuration time.Duration // duration of a probe, defaults to DefaultProbeDuration
+	Countries     []string      // only keep servers in these countries, case-insensitive
+	Sponsors      []string      // only keep servers whose sponsor contains one of these, case-insensitive
+	ExcludeIDs    []string      // never select these servers
+	MaxDistance   float64       // only keep servers at most this many km away, 0 disables the filter
+	MaxLatency    time.Duration // only keep reachable servers with at most this latency, 0 disables the filter
+}
+
+// Filter returns the servers matching the country, sponsor, exclusion, distance
+// and latency filters of the selection, in their original order. The latency
+// filter uses the latency of the server list.
+func (servers Servers) Filter(selection Selection) Servers {
+	filtered := Servers{}
+
+	for _, server := range servers {
+		if slices.Contains(selection.ExcludeIDs, server.ID) {
+			continue
+		}
+
+		if selection.MaxDistance > 0 && server.Distance > selection.MaxDistance {
+			continue
+		}
+
+		if selection.MaxLatency > 0 && (server.Latency <= 0 || server.Latency > selection.MaxLatency) {
+			continue
+		}
+
+		if len(selection.Countries) > 0 && !slices.ContainsFunc(selection.Countries, func(country string) bool {
+			return strings.EqualFold(strings.TrimSpace(country), server.Country)
+		}) {
+			continue
+		}
+
+		if len(selection.Sponsors) > 0 && !slices.ContainsFunc(selection.Sponsors, func(sponsor string) bool {
+			return strings.Contains(strings.ToLower(server.Sponsor), strings.ToLower(strings.TrimSpace(sponsor)))
+		}) {
+			continue
+		}
+
+		filtered = append(filtered, server)
+	}
+
+	return filtered
+}
+
+// SelectServer filters the servers and picks one with the strategy of the
+// selection. Ties are broken by distance and then by id, so the selection
+// only depends on the measurements. The latency based strategies replace the
+// latency of the measured servers with the measured value.
+func (s *Speedtest) SelectServer(ctx context.Context, servers Servers, selection Selection) (*Server, error) {
+	if s == nil {
+		return nil, errSpeedtestClientNil
+	}
+
+	candidates := servers.Filter(selection)
+	if len(candidates) == 0 {
+		return nil, ErrServerNotFound
+	}
+
+	pings := cmp.Or(selection.Pings, DefaultSelectionPings)
+
+	var ranked Servers
+
+	switch cmp.Or(selection.Strategy, StrategyLatency) {
+	case StrategyLatency:
+		ranked = rankByLatency(candidates)
+	case StrategyNearest:
+		ranked = slices.Clone(candidates)
+		slices.SortStableFunc(ranked, func(a, b *Server) int {
+			return cmp.Or(cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.ID, b.ID))
+		})
+	case StrategyBestOf:
+		s.measureLatencies(ctx, candidates, pings, slices.Min)
+		ranked = rankByLatency(candidates)
+	case StrategyMedian:
+		s.measureLatencies(ctx, candidates, pings, median)
+		ranked = rankByLatency(candidates)
+	case StrategyProbe:
+		s.measureLatencies(ctx, candidates, pings, median)
+		ranked = rankByLatency(candidates)
+
+		if len(ranked) > 0 {
+			return s.probeServers(ctx, ranked[:min(len(ranked), cmp.Or(selection.TopK, DefaultSelectionTopK))],
+				cmp.Or(selection.ProbeDuration, DefaultProbeDuration)), nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, selection.Strategy)
+	}
+
+	if len(ranked) == 0 {
+		return nil, ErrServerNotFound
+	}
+
+	return ranked[0], nil
+}
+
+// rankByLatency returns the reachable servers ordered by latency.
+func rankByLatency(servers Servers) Servers {
+	ranked := Servers{}
+
+	for _, server := range servers {
+		if server.Latency > 0 {
+			ranked = append(ranked, server)
+		}
+	}
+
+	slices.SortStableFunc(ranked, func(a, b *Server) int {
+		return cmp.Or(
+			cmp.Compare(a.Latency, b.Latency),
+			cmp.Compare(a.Distance, b.Distance),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+
+	return ranked
+}
+
+// measureLatencies pings every server several times concurrently and sets its
+// latency to the aggregate of the samples, or PingTimeout if all pings failed.
+func (s *Speedtest) measureLatencies(
+	ctx context.Context, servers Servers, pings int, aggregate func([]int64) int64,
+) {
+	pCtx, cancel := context.WithTimeout(ctx, selectionPingTimeout)
+	defer cancel()
+
+	var waitGroup sync.WaitGroup
+
+	for _, server := range servers {
+		waitGroup.Go(func() {
+			latencies, err := server.pingSamples(pCtx, s.config.PingMode, pings)
+			if err != nil || len(latencies) == 0 {
+				server.Latency = PingTimeout
+
+				return
+			}
+
+			server.Latency = time.Duration(aggregate(latencies))
+		})
+	}
+
+	waitGroup.Wait()
+}
+
+// probeServers downloads from every server for a short time, one after the
+// other so that the probes do not compete, and returns the one with the highest
+// rate. Without any successful probe it returns the first server.
+func (s *Speedtest) probeServers(ctx context.Context, servers Servers, duration time.Duration) *Server {
+	best := servers[0]
+
+	var bestRate ByteRate
+
+	for _, server := range servers {
+		rate, err := server.probeDownload(ctx, duration)
+		s.dbg.Printf("Probe %s: %s (err: %v)\n", server.ID, s.FormatRate(rate), err)
+
+		if err == nil && rate > bestRate {
+			best, bestRate = server, rate
+		}
+	}
+
+	return best
+}
+
+// probeDownload downloads a single file for at most the given duration and
+// returns the rate.
+func (s *Server) probeDownload(ctx context.Context, duration time.Duration) (ByteRate, error) {
+	err := s.upgradeHTTPS(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := s.downloadBaseURL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse download URL: %w", err)
+	}
+
+	pCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(withServerRequest(pCtx), http.MethodGet,
+		u.JoinPath(fmt.Sprintf("random%dx%d.jpg", probeImageSize, probeImageSize)).String(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create probe request: %w", err)
+	}
+
+	start := time.Now()
+
+	resp, err := s.Context.doer.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to perform probe request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: %s", errProbeFailed, resp.Status)
+	}
+
+	// a probe cut short by the deadline still measured the rate.
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil && pCtx.Err() == nil {
+		return 0, fmt.Errorf("failed to read probe response: %w", err)
+	}
+
+	elapsed := time.Since(start)
+	if n == 0 || elapsed <= 0 {
+		return 0, fmt.Errorf("%w: no data received", errProbeFailed)
+	}
+
+	return ByteRate(float64(n) / elapsed.Seconds()), nil
+}
+
+// median returns the median of the values.
+func median(values []int64) int64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
@@ -0,0 +1,154 @@
+package speedtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selectionServers() Servers {
+	return Servers{
+		{ID: "1", Country: "Japan", Sponsor: "Sudosan", Distance: 9, Latency: 30 * time.Millisecond},
+		{ID: "2", Country: "Japan", Sponsor: "Allied Telesis", Distance: 120, Latency: 10 * time.Millisecond},
+		{ID: "3", Country: "Korea", Sponsor: "KT", Distance: 900, Latency: 10 * time.Millisecond},
+		{ID: "4", Country: "Japan", Sponsor: "at2wn", Distance: 125, Latency: PingTimeout},
+	}
+}
+
+func serverIDs(servers Servers) []string {
+	ids := make([]string, 0, len(servers))
+	for _, server := range servers {
+		ids = append(ids, server.ID)
+	}
+
+	return ids
+}
+
+func TestServers_Filter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      []string
+	}{
+		{name: "no filter", want: []string{"1", "2", "3", "4"}},
+		{name: "country", selection: Selection{Countries: []string{" japan"}}, want: []string{"1", "2", "4"}},
+		{name: "countries", selection: Selection{Countries: []string{"Korea", "Japan"}}, want: []string{"1", "2", "3", "4"}},
+		{name: "sponsor", selection: Selection{Sponsors: []string{"SAN", "kt"}}, want: []string{"1", "3"}},
+		{name: "exclude", selection: Selection{ExcludeIDs: []string{"2", "3"}}, want: []string{"1", "4"}},
+		{
+			name:      "combined",
+			selection: Selection{Countries: []string{"Japan"}, Sponsors: []string{"a"}, ExcludeIDs: []string{"4"}},
+			want:      []string{"1", "2"},
+		},
+		{name: "max distance", selection: Selection{MaxDistance: 120}, want: []string{"1", "2"}},
+		{name: "max latency", selection: Selection{MaxLatency: 10 * time.Millisecond}, want: []string{"2", "3"}},
+		{name: "no match", selection: Selection{Countries: []string{"France"}}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, serverIDs(selectionServers().Filter(tt.selection)))
+		})
+	}
+}
+
+func TestSpeedtest_SelectServer(t *testing.T) {
+	t.Parallel()
+
+	client := New()
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      string
+		wantErr   error
+	}{
+		// equal latencies are decided by the distance.
+		{name: "latency", want: "2"},
+		{name: "nearest", selection: Selection{Strategy: StrategyNearest}, want: "1"},
+		{name: "filtered", selection: Selection{Strategy: StrategyNearest, Countries: []string{"Korea"}}, want: "3"},
+		{name: "excluded", selection: Selection{ExcludeIDs: []string{"2"}}, want: "3"},
+		{name: "none left", selection: Selection{Countries: []string{"France"}}, wantErr: ErrServerNotFound},
+		{name: "unreachable", selection: Selection{ExcludeIDs: []string{"1", "2", "3"}}, wantErr: ErrServerNotFound},
+		{name: "unknown strategy", selection: Selection{Strategy: "fastest"}, wantErr: ErrUnknownStrategy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := client.SelectServer(context.Background(), selectionServers(), tt.selection)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ID)
+		})
+	}
+
+	_, err := (*Speedtest)(nil).SelectServer(context.Background(), selectionServers(), Selection{})
+	require.Error(t, err)
+}
+
+func TestSpeedtest_SelectServer_Measured(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	client := New()
+
+	for _, strategy := range []Strategy{StrategyBestOf, StrategyMedian, StrategyProbe} {
+		t.Run(string(strategy), func(t *testing.T) {
+			t.Parallel()
+
+			servers := Servers{
+				{ID: "1", Host: "127.0.0.1:1", URL: "http://127.0.0.1:1/speedtest/upload.php", Context: client},
+				{ID: "2", Host: host, URL: "http://" + host + "/speedtest/upload.php", Context: client},
+			}
+
+			got, err := client.SelectServer(context.Background(), servers, Selection{
+				Strategy:      strategy,
+				Pings:         2,
+				ProbeDuration: 500 * time.Millisecond,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "2", got.ID)
+			assert.Positive(t, got.Latency)
+			assert.Equal(t, time.Duration(PingTimeout), servers[0].Latency)
+		})
+	}
+}
+
+func TestServer_probeDownload(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+	client := New()
+
+	server, err := client.CustomServer("http://" + host)
+	require.NoError(t, err)
+
+	rate, err := server.probeDownload(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Positive(t, rate)
+
+	server.URL = "http://127.0.0.1:1/speedtest/upload.php"
+
+	_, err = server.probeDownload(context.Background(), time.Second)
+	require.Error(t, err)
+}
+
+func Test_median(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(3), median([]int64{5, 3, 1}))
+	assert.Equal(t, int64(3), median([]int64{4, 1, 2, 8}))
+	assert.Equal(t, int64(7), median([]int64{7}))
+}
@@ -1,6 +1,7 @@
 package speedtest
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"encoding/xml"
@@ -9,6 +10,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,6 +24,9 @@ const (
 	speedTestServersURL            = "https://www.speedtest.net/api/js/servers"
 	speedTestServersAlternativeURL = "https://www.speedtest.net/speedtest-servers-static.php"
 	speedTestServersAdvanced       = "https://www.speedtest.net/api/ios-config.php"
+
+	customServerID   = "Custom"
+	customUploadPath = "/speedtest/upload.php"
 )
 
 type payloadType int
@@ -34,6 +39,8 @@ const (
 var (
 	// ErrServerNotFound is returned when no server is available or found.
 	ErrServerNotFound = errors.New("no server available or found")
+	// ErrUnknownSortKey is returned for a sort key that is not supported.
+	ErrUnknownSortKey = errors.New("unknown sort key")
 
 	errSpeedtestClientNil = errors.New("speedtest client is nil")
 	errHostEmpty          = errors.New("host cannot be empty")
@@ -42,24 +49,46 @@ var (
 
 // Server information.
 type Server struct {
-	URL          string          `json:"url"          xml:"url,attr"`
-	Lat          string          `json:"lat"          xml:"lat,attr"`
-	Lon          string          `json:"lon"          xml:"lon,attr"`
-	Name         string          `json:"name"         xml:"name,attr"`
-	Country      string          `json:"country"      xml:"country,attr"`
-	Sponsor      string          `json:"sponsor"      xml:"sponsor,attr"`
-	ID           string          `json:"id"           xml:"id,attr"`
-	Host         string          `json:"host"         xml:"host,attr"`
-	Distance     float64         `json:"distance"     xml:"-"`
-	Latency      time.Duration   `json:"latency"      xml:"-"`
-	MaxLatency   time.Duration   `json:"maxLatency"   xml:"-"`
-	MinLatency   time.Duration   `json:"minLatency"   xml:"-"`
-	Jitter       time.Duration   `json:"jitter"       xml:"-"`
-	DLSpeed      ByteRate        `json:"dlSpeed"      xml:"-"`
-	ULSpeed      ByteRate        `json:"ulSpeed"      xml:"-"`
-	TestDuration TestDuration    `json:"testDuration" xml:"-"`
-	PacketLoss   transport.PLoss `json:"packetLoss"   xml:"-"`
-	Context      *Speedtest      `json:"-"            xml:"-"`
+	URL             string          `json:"url"                       xml:"url,attr"`
+	Lat             string          `json:"lat"                       xml:"lat,attr"`
+	Lon             string          `json:"lon"                       xml:"lon,attr"`
+	Name            string          `json:"name"                      xml:"name,attr"`
+	Country         string          `json:"country"                   xml:"country,attr"`
+	Sponsor         string          `json:"sponsor"                   xml:"sponsor,attr"`
+	ID              string          `json:"id"                        xml:"id,attr"`
+	Host            string          `json:"host"                      xml:"host,attr"`
+	Distance        float64         `json:"distance"                  xml:"-"`
+	Latency         time.Duration   `json:"latency"                   xml:"-"`
+	MaxLatency      time.Duration   `json:"maxLatency"                xml:"-"`
+	MinLatency      time.Duration   `json:"minLatency"                xml:"-"`
+	Jitter          time.Duration   `json:"jitter"                    xml:"-"`
+	DLSpeed         ByteRate        `json:"dlSpeed"                   xml:"-"`
+	ULSpeed         ByteRate        `json:"ulSpeed"                   xml:"-"`
+	TestDuration    TestDuration    `json:"testDuration"              xml:"-"`
+	PacketLoss      transport.PLoss `json:"packetLoss"                xml:"-"`
+	Bufferbloat     *Bufferbloat    `json:"bufferbloat,omitempty"     xml:"-"`
+	TimeSeries      *TimeSeries     `json:"timeSeries,omitempty"      xml:"-"`
+	DLBudgetLimited bool            `json:"dlBudgetLimited,omitempty" xml:"-"`
+	ULBudgetLimited bool            `json:"ulBudgetLimited,omitempty" xml:"-"`
+	DownloadURL     string          `json:"downloadUrl,omitempty"     xml:"-"` // directory of the download files, defaults to the directory of URL
+	LatencyURL      string          `json:"latencyUrl,omitempty"      xml:"-"` // defaults to latency.txt in the directory of URL
+	IPFamily        IPFamily        `json:"ipFamily,omitempty"        xml:"-"` // family the tests were forced to, empty if either
+	Source          string          `json:"source,omitempty"          xml:"-"` // source interface or address the tests were bound to
+	Connection      *ConnectionInfo `json:"connection,omitempty"      xml:"-"`
+	PingPhases      *PhaseTimings   `json:"pingPhases,omitempty"      xml:"-"` // phases of the HTTP pings of PingTest
+	DownloadPhases  *PhaseTimings   `json:"downloadPhases,omitempty"  xml:"-"` // phases of the HTTP download requests
+	Error           string          `json:"error,omitempty"           xml:"-"` // error the tests failed with, the results measured before are kept
+	Context         *Speedtest      `json:"-"                         xml:"-"`
+
+	httpsChecked bool        // the URLs were checked for HTTPS by upgradeHTTPS
+	listedURLs   *serverURLs // test URLs the server was listed with, set when upgraded to HTTPS
+}
+
+// serverURLs are the test URLs of a server.
+type serverURLs struct {
+	url      string
+	download string
+	latency  string
 }
 
 // TestDuration holds the duration of different test phases.
@@ -73,39 +102,72 @@ type TestDuration struct {
 // CustomServer use defaultClient, given a URL string, return a new Server object, with as much
 // filled in as we can.
 func CustomServer(host string) (*Server, error) {
-	return defaultClient.CustomServer(host)
+	return defaultClient().CustomServer(host)
 }
 
 // CustomServer given a URL string, return a new Server object, with as much
 // filled in as we can.
 func (s *Speedtest) CustomServer(host string) (*Server, error) {
+	return s.NewCustomServer(CustomServerOptions{URL: host})
+}
+
+// CustomServerOptions describes a self-hosted server for NewCustomServer.
+// Only the URL is required, the paths replace its path.
+type CustomServerOptions struct {
+	URL          string
+	ID           string // defaults to "Custom"
+	Name         string // defaults to the host of the URL
+	UploadPath   string // defaults to /speedtest/upload.php
+	DownloadPath string // directory of the download files, defaults to the directory of UploadPath
+	LatencyPath  string // defaults to latency.txt in the directory of UploadPath
+}
+
+// NewCustomServer returns a new Server object for a self-hosted server, with
+// as much filled in as we can.
+func (s *Speedtest) NewCustomServer(options CustomServerOptions) (*Server, error) {
 	if s == nil {
 		return nil, errSpeedtestClientNil
 	}
 
-	if host == "" {
+	if options.URL == "" {
 		return nil, errHostEmpty
 	}
 
-	parsedURL, err := url.Parse(host)
+	parsedURL, err := url.Parse(options.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse host URL: %w", err)
 	}
 
-	parsedURL.Path = "/speedtest/upload.php"
-	parseHost := parsedURL.String()
-
-	return &Server{
-		ID:      "Custom",
+	server := &Server{
+		ID:      cmp.Or(options.ID, customServerID),
 		Lat:     "?",
 		Lon:     "?",
 		Country: "?",
-		URL:     parseHost,
-		Name:    parsedURL.Host,
+		URL:     withPath(parsedURL, cmp.Or(options.UploadPath, customUploadPath)),
+		Name:    cmp.Or(options.Name, parsedURL.Host),
 		Host:    parsedURL.Host,
 		Sponsor: "?",
 		Context: s,
-	}, nil
+	}
+
+	if len(options.DownloadPath) > 0 {
+		server.DownloadURL = withPath(parsedURL, options.DownloadPath)
+	}
+
+	if len(options.LatencyPath) > 0 {
+		server.LatencyURL = withPath(parsedURL, options.LatencyPath)
+	}
+
+	return server, nil
+}
+
+// withPath returns the URL with its path replaced.
+func withPath(u *url.URL, urlPath string) string {
+	replaced := *u
+	replaced.Path = "/" + strings.TrimPrefix(urlPath, "/")
+	replaced.RawPath = ""
+
+	return replaced.String()
 }
 
 // ServerList list of Server
@@ -119,6 +181,21 @@ type ServerList struct {
 // Servers for sorting servers.
 type Servers []*Server
 
+// SortKey orders the servers in Servers.Sort.
+type SortKey string
+
+const (
+	// SortByDistance orders the servers by distance.
+	SortByDistance SortKey = "distance"
+	// SortByLatency orders the servers by latency, unreachable servers last.
+	SortByLatency SortKey = "latency"
+	// SortByName orders the servers by name and then by sponsor.
+	SortByName SortKey = "name"
+)
+
+// SortKeys are the supported sort keys.
+var SortKeys = []SortKey{SortByDistance, SortByLatency, SortByName}
+
 // ByDistance for sorting servers.
 type ByDistance struct {
 	Servers
@@ -134,15 +211,51 @@ func (servers Servers) Available() *Servers {
 		}
 	}
 
-	for i := range len(retServer) - 1 {
-		for j := range len(retServer) - i - 1 {
-			if retServer[j].Latency > retServer[j+1].Latency {
-				retServer[j], retServer[j+1] = retServer[j+1], retServer[j]
-			}
+	slices.SortStableFunc(retServer, func(a, b *Server) int {
+		return cmp.Compare(a.Latency, b.Latency)
+	})
+
+	return &retServer
+}
+
+// Sort orders the servers in place by the key. Ties are broken by distance and
+// then by id, so the order is stable across runs.
+func (servers Servers) Sort(key SortKey) error {
+	var compare func(a, b *Server) int
+
+	switch key {
+	case SortByDistance:
+		compare = func(_, _ *Server) int { return 0 }
+	case SortByLatency:
+		compare = func(a, b *Server) int {
+			return cmp.Compare(sortLatency(a), sortLatency(b))
+		}
+	case SortByName:
+		compare = func(a, b *Server) int {
+			return cmp.Or(
+				cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+				cmp.Compare(strings.ToLower(a.Sponsor), strings.ToLower(b.Sponsor)),
+			)
 		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownSortKey, key)
 	}
 
-	return &retServer
+	slices.SortStableFunc(servers, func(a, b *Server) int {
+		return cmp.Or(compare(a, b), cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.ID, b.ID))
+	})
+
+	return nil
+}
+
+// sortLatency returns the latency of the server, or the maximum duration for
+// unreachable (negative) and unmeasured (zero) latencies.
+func sortLatency(server *Server) time.Duration {
+	if server.Latency <= 0 {
+		return math.MaxInt64
+	}
+
+	return server.Latency
 }
 
 // Len finds length of servers. For sorting servers.
@@ -174,6 +287,28 @@ func (b ByDistance) Less(i, j int) bool {
 	return b.Servers[i].Distance < b.Servers[j].Distance
 }
 
+// CloneServer returns a copy of the server without results, tested by this
+// client, e.g. to test the same server over another address family or from
+// another source interface.
+func (s *Speedtest) CloneServer(server *Server) *Server {
+	return &Server{
+		URL:         server.URL,
+		Lat:         server.Lat,
+		Lon:         server.Lon,
+		Name:        server.Name,
+		Country:     server.Country,
+		Sponsor:     server.Sponsor,
+		ID:          server.ID,
+		Host:        server.Host,
+		Distance:    server.Distance,
+		DownloadURL: server.DownloadURL,
+		LatencyURL:  server.LatencyURL,
+		IPFamily:    s.config.IPFamily,
+		Source:      s.config.Source,
+		Context:     s,
+	}
+}
+
 // FetchServerByID retrieves a server by given serverID.
 func (s *Speedtest) FetchServerByID(serverID string) (*Server, error) {
 	return s.FetchServerByIDContext(context.Background(), serverID)
@@ -181,7 +316,12 @@ func (s *Speedtest) FetchServerByID(serverID string) (*Server, error) {
 
 // FetchServerByID retrieves a server by given serverID.
 func FetchServerByID(serverID string) (*Server, error) {
-	return defaultClient.FetchServerByID(serverID)
+	return defaultClient().FetchServerByID(serverID)
+}
+
+// FetchServerByIDContext retrieves a server by given serverID, observing the given context.
+func FetchServerByIDContext(ctx context.Context, serverID string) (*Server, error) {
+	return defaultClient().FetchServerByIDContext(ctx, serverID)
 }
 
 // FetchServerByIDContext retrieves a server by given serverID, observing the given context.
@@ -244,27 +384,11 @@ func pingServers(ctx context.Context, servers Servers, pingMode Proto) {
 
 	pCtx, cancelFunc := context.WithTimeout(ctx, time.Second*4)
 
-	dbg.Println("Echo each server...")
-
 	for _, server := range servers {
 		waitGroup.Add(1)
 
 		go func(serverPtr *Server) {
-			var (
-				latency []int64
-				errPing error
-			)
-
-			switch pingMode {
-			case TCP:
-				latency, errPing = serverPtr.TCPPing(pCtx, 1, time.Millisecond, nil)
-			case ICMP:
-				latency, errPing = serverPtr.ICMPPing(pCtx, 4*time.Second, 1, time.Millisecond, nil)
-			case HTTP:
-				latency, errPing = serverPtr.HTTPPing(pCtx, 1, time.Millisecond, nil)
-			default:
-				latency, errPing = serverPtr.HTTPPing(pCtx, 1, time.Millisecond, nil)
-			}
+			latency, errPing := serverPtr.pingSamples(pCtx, pingMode, 1)
 
 			if errPing != nil || len(latency) < 1 {
 				serverPtr.Latency = PingTimeout
@@ -280,6 +404,20 @@ func pingServers(ctx context.Context, servers Servers, pingMode Proto) {
 	cancelFunc()
 }
 
+// pingSamples pings the server count times with the given mode. The servers are
+// ranked over their listed URLs, only the one selected for testing is upgraded
+// to HTTPS, so that no TLS probe eats into the ping timeout of the list.
+func (s *Server) pingSamples(ctx context.Context, pingMode Proto, count int) ([]int64, error) {
+	switch pingMode {
+	case TCP:
+		return s.TCPPing(ctx, count, time.Millisecond, nil)
+	case ICMP:
+		return s.ICMPPing(ctx, 4*time.Second, count, time.Millisecond, nil)
+	default:
+		return s.HTTPPing(ctx, count, time.Millisecond, nil)
+	}
+}
+
 // FetchServers retrieves a list of available servers.
 func (s *Speedtest) FetchServers() (Servers, error) {
 	return s.FetchServerListContext(context.Background())
@@ -287,7 +425,7 @@ func (s *Speedtest) FetchServers() (Servers, error) {
 
 // FetchServers retrieves a list of available servers.
 func FetchServers() (Servers, error) {
-	return defaultClient.FetchServers()
+	return defaultClient().FetchServers()
 }
 
 // buildServerListURL constructs the URL for fetching server list.
@@ -308,7 +446,7 @@ func (s *Speedtest) buildServerListURL() (*url.URL, error) {
 	}
 
 	parsedURL.RawQuery = query.Encode()
-	dbg.Printf("Retrieving servers: %s\n", parsedURL.String())
+	s.dbg.Printf("Retrieving servers: %s\n", parsedURL.String())
 
 	return parsedURL, nil
 }
@@ -381,7 +519,7 @@ func (s *Speedtest) decodeServerList(resp *http.Response, pType payloadType) (Se
 		return servers, errPayloadDecode
 	}
 
-	dbg.Printf("Servers Num: %d\n", len(servers))
+	s.dbg.Printf("Servers Num: %d\n", len(servers))
 
 	return servers, nil
 }
@@ -409,12 +547,25 @@ func (s *Speedtest) FetchServerListContext(ctx context.Context) (Servers, error)
 		return servers, err
 	}
 
+	return s.PrepareServers(ctx, servers)
+}
+
+// PrepareServers makes servers from another source than speedtest.net, like a
+// static list, usable like a fetched server list: it attaches the client,
+// measures the latency of every server, computes the distances if the user
+// information is known and sorts the servers by distance.
+func (s *Speedtest) PrepareServers(ctx context.Context, servers Servers) (Servers, error) {
+	if s == nil {
+		return servers, errSpeedtestClientNil
+	}
+
 	// set context for servers
 	for _, server := range servers {
 		server.Context = s
 	}
 
 	// ping servers
+	s.dbg.Println("Echo each server...")
 	pingServers(ctx, servers, s.config.PingMode)
 
 	// Calculate distance
@@ -422,11 +573,18 @@ func (s *Speedtest) FetchServerListContext(ctx context.Context) (Servers, error)
 	// we don't calculate the distance, instead we use the
 	// remote computing distance provided by Ookla as default.
 	if s.User != nil {
+		uLat, errLat := strconv.ParseFloat(s.User.Lat, 64)
+		uLon, errLon := strconv.ParseFloat(s.User.Lon, 64)
+
 		for _, server := range servers {
-			sLat, _ := strconv.ParseFloat(server.Lat, 64)
-			sLon, _ := strconv.ParseFloat(server.Lon, 64)
-			uLat, _ := strconv.ParseFloat(s.User.Lat, 64)
-			uLon, _ := strconv.ParseFloat(s.User.Lon, 64)
+			sLat, errServerLat := strconv.ParseFloat(server.Lat, 64)
+			sLon, errServerLon := strconv.ParseFloat(server.Lon, 64)
+
+			// keep the given distance of servers without a location.
+			if errors.Join(errLat, errLon, errServerLat, errServerLon) != nil {
+				continue
+			}
+
 			server.Distance = distance(sLat, sLon, uLat, uLon)
 		}
 	}
@@ -443,7 +601,7 @@ func (s *Speedtest) FetchServerListContext(ctx context.Context) (Servers, error)
 
 // FetchServerListContext retrieves a list of available servers, observing the given context.
 func FetchServerListContext(ctx context.Context) (Servers, error) {
-	return defaultClient.FetchServerListContext(ctx)
+	return defaultClient().FetchServerListContext(ctx)
 }
 
 func distance(lat1, lon1, lat2, lon2 float64) float64 {
@@ -564,6 +722,64 @@ func (s *Server) String() string {
 	return fmt.Sprintf("[%4s] %.2fkm %s (%s) by %s", s.ID, s.Distance, s.Name, s.Country, s.Sponsor)
 }
 
+// ResetResults clears the results of a previous run, so that a server tested
+// again reports only what the new run measured. The description of the server
+// and its test URLs are kept.
+func (s *Server) ResetResults() {
+	if s == nil {
+		return
+	}
+
+	s.Latency = 0
+	s.MaxLatency = 0
+	s.MinLatency = 0
+	s.Jitter = 0
+	s.DLSpeed = 0
+	s.ULSpeed = 0
+	s.TestDuration = TestDuration{}
+	s.PacketLoss = transport.PLoss{}
+	s.Bufferbloat = nil
+	s.TimeSeries = nil
+	s.DLBudgetLimited = false
+	s.ULBudgetLimited = false
+	s.Connection = nil
+	s.PingPhases = nil
+	s.DownloadPhases = nil
+	s.Error = ""
+}
+
+// Listing returns a copy of the server as it was listed, to be stored with a
+// server list: without the results of its tests and with the test URLs it had
+// before being upgraded to HTTPS. The copy must be pinged again before its
+// latency is used.
+func (s *Server) Listing() *Server {
+	if s == nil {
+		return nil
+	}
+
+	listing := &Server{
+		URL:         s.URL,
+		Lat:         s.Lat,
+		Lon:         s.Lon,
+		Name:        s.Name,
+		Country:     s.Country,
+		Sponsor:     s.Sponsor,
+		ID:          s.ID,
+		Host:        s.Host,
+		Distance:    s.Distance,
+		DownloadURL: s.DownloadURL,
+		LatencyURL:  s.LatencyURL,
+	}
+
+	if s.listedURLs != nil {
+		listing.URL = s.listedURLs.url
+		listing.DownloadURL = s.listedURLs.download
+		listing.LatencyURL = s.listedURLs.latency
+	}
+
+	return listing
+}
+
 // CheckResultValid checks that results are logical given UL and DL speeds.
 func (s *Server) CheckResultValid() bool {
 	if s == nil {
@@ -0,0 +1,8 @@
+// Package server provides a speedtest.net compatible test server.
+//
+// It implements the endpoints consumed by the speedtest client: HTTP downloads of
+// random{N}x{N}.jpg files, uploads to upload.php and latency.txt probes, the
+// line-based TCP protocol used for latency and packet loss measurements, and the
+// UDP datagrams sent by the packet loss sender. HTTP and the line protocol share a
+// single TCP port, in the same way as the public speedtest.net servers.
+package server
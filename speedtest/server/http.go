@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+const (
+	latencyFile = "latency.txt"
+	uploadFile  = "upload.php"
+
+	// maxImageSize mirrors the largest random image offered by speedtest.net servers.
+	maxImageSize = 4000
+	// bytesPerPixel approximates the size of the random JPEG images, e.g.
+	// random350x350.jpg is about 245KB on the public servers.
+	bytesPerPixel = 2
+
+	randomBlockSize = 1 << 20 // 1 MiB of random data repeated for downloads
+)
+
+// randomBlock is the incompressible payload repeated in download responses.
+var randomBlock = func() []byte {
+	block := make([]byte, randomBlockSize)
+	_, _ = rand.Read(block)
+
+	return block
+}()
+
+func (srv *Server) newHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		// The client derives every endpoint from the directory of the server URL,
+		// so only the file name decides which handler is used.
+		name := path.Base(r.URL.Path)
+
+		switch {
+		case name == latencyFile:
+			handleLatency(w, r)
+		case name == uploadFile:
+			handleUpload(w, r)
+		default:
+			size, ok := parseImageName(name)
+			if !ok {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			handleDownload(w, r, int64(size)*int64(size)*bytesPerPixel)
+		}
+	})
+}
+
+func handleLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(w, "test=test\n")
+}
+
+func handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	size, err := io.Copy(io.Discard, r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = fmt.Fprintf(w, "size=%d", size)
+}
+
+func handleDownload(w http.ResponseWriter, r *http.Request, size int64) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_ = writeRandom(w, size)
+}
+
+// writeRandom writes size bytes of random payload to the writer.
+func writeRandom(w io.Writer, size int64) error {
+	for size > 0 {
+		block := randomBlock
+		if size < int64(len(block)) {
+			block = block[:size]
+		}
+
+		n, err := w.Write(block)
+		if err != nil {
+			return fmt.Errorf("failed to write payload: %w", err)
+		}
+
+		size -= int64(n)
+	}
+
+	return nil
+}
+
+// parseImageName parses names like random350x350.jpg and returns the image side.
+func parseImageName(name string) (int, bool) {
+	var width, height int
+
+	n, err := fmt.Sscanf(name, "random%dx%d.jpg", &width, &height)
+	if err != nil || n != 2 || width != height || width <= 0 || width > maxImageSize {
+		return 0, false
+	}
+
+	if name != fmt.Sprintf("random%dx%d.jpg", width, height) {
+		return 0, false
+	}
+
+	return width, true
+}
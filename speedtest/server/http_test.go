@@ -0,0 +1,124 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Handler(t *testing.T) {
+	srv := httptest.NewServer(New(nil).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantLength int64
+	}{
+		{
+			name:       "latency",
+			method:     http.MethodGet,
+			path:       "/speedtest/latency.txt",
+			wantStatus: http.StatusOK,
+			wantBody:   "test=test\n",
+		},
+		{
+			name:       "upload",
+			method:     http.MethodPost,
+			path:       "/speedtest/upload.php",
+			body:       strings.Repeat("x", 1024),
+			wantStatus: http.StatusOK,
+			wantBody:   "size=1024",
+		},
+		{
+			name:       "upload with get",
+			method:     http.MethodGet,
+			path:       "/speedtest/upload.php",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "download",
+			method:     http.MethodGet,
+			path:       "/speedtest/random350x350.jpg",
+			wantStatus: http.StatusOK,
+			wantLength: 350 * 350 * bytesPerPixel,
+		},
+		{
+			name:       "download without prefix",
+			method:     http.MethodGet,
+			path:       "/random500x500.jpg",
+			wantStatus: http.StatusOK,
+			wantLength: 500 * 500 * bytesPerPixel,
+		},
+		{
+			name:       "unknown file",
+			method:     http.MethodGet,
+			path:       "/speedtest/index.html",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequestWithContext(
+				t.Context(),
+				tt.method,
+				srv.URL+tt.path,
+				strings.NewReader(tt.body),
+			)
+			require.NoError(t, err)
+
+			resp, err := srv.Client().Do(req)
+			require.NoError(t, err)
+
+			defer func() { _ = resp.Body.Close() }()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if len(tt.wantBody) > 0 {
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+
+			if tt.wantLength > 0 {
+				assert.Len(t, body, int(tt.wantLength))
+			}
+		})
+	}
+}
+
+func Test_parseImageName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     int
+		wantOK   bool
+	}{
+		{name: "valid", fileName: "random350x350.jpg", want: 350, wantOK: true},
+		{name: "largest", fileName: "random4000x4000.jpg", want: 4000, wantOK: true},
+		{name: "too large", fileName: "random4001x4001.jpg", wantOK: false},
+		{name: "not square", fileName: "random350x500.jpg", wantOK: false},
+		{name: "trailing data", fileName: "random350x350.jpg.bak", wantOK: false},
+		{name: "other file", fileName: "latency.txt", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := parseImageName(tt.fileName)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAddr is the address the speedtest.net servers listen on.
+	DefaultAddr = ":8080"
+	// DefaultVersion is the version string returned to HI commands.
+	DefaultVersion = "2.11 (speedtest-go)"
+
+	defaultIdleTimeout   = 60 * time.Second
+	defaultSniffTimeout  = 10 * time.Second
+	defaultShutdownDelay = 5 * time.Second
+	maxCommandLength     = 10 // longest command (INITPLOSS) plus a separator
+)
+
+// httpMethods are the request tokens that route a connection to the HTTP handler.
+var httpMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// ErrServerClosed is returned by Serve after the server has been shut down.
+var ErrServerClosed = errors.New("speedtest server closed")
+
+// Options configures the speedtest server.
+type Options struct {
+	Addr        string        // tcp address for HTTP and the line protocol
+	UDPAddr     string        // udp address for packet loss datagrams, defaults to Addr
+	Version     string        // version reported to HI commands
+	IdleTimeout time.Duration // idle timeout of line protocol connections
+}
+
+// Server is a speedtest.net compatible test server.
+type Server struct {
+	options *Options
+	handler http.Handler
+	loss    *lossRegistry
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	packets   []net.PacketConn
+	closed    bool
+}
+
+// New creates a new speedtest server with the given options.
+func New(options *Options) *Server {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if len(options.Addr) == 0 {
+		options.Addr = DefaultAddr
+	}
+
+	if len(options.UDPAddr) == 0 {
+		options.UDPAddr = options.Addr
+	}
+
+	if len(options.Version) == 0 {
+		options.Version = DefaultVersion
+	}
+
+	if options.IdleTimeout == 0 {
+		options.IdleTimeout = defaultIdleTimeout
+	}
+
+	srv := &Server{
+		options: options,
+		loss:    newLossRegistry(),
+	}
+	srv.handler = srv.newHandler()
+
+	return srv
+}
+
+// Handler returns the HTTP handler serving download, upload and latency requests.
+func (srv *Server) Handler() http.Handler {
+	return srv.handler
+}
+
+// ListenAndServe listens on the configured tcp and udp addresses and serves
+// requests until the given context is canceled.
+func (srv *Server) ListenAndServe(ctx context.Context) error {
+	listenConfig := &net.ListenConfig{}
+
+	listener, err := listenConfig.Listen(ctx, "tcp", srv.options.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on tcp %s: %w", srv.options.Addr, err)
+	}
+
+	packetConn, err := listenConfig.ListenPacket(ctx, "udp", srv.options.UDPAddr)
+	if err != nil {
+		_ = listener.Close()
+
+		return fmt.Errorf("failed to listen on udp %s: %w", srv.options.UDPAddr, err)
+	}
+
+	return srv.Serve(ctx, listener, packetConn)
+}
+
+// Serve accepts connections on the listener and datagrams on the packet conn
+// until the given context is canceled. packetConn may be nil, in which case
+// packet loss datagrams are not collected.
+func (srv *Server) Serve(
+	ctx context.Context,
+	listener net.Listener,
+	packetConn net.PacketConn,
+) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+
+		return ErrServerClosed
+	}
+
+	srv.listeners = append(srv.listeners, listener)
+	if packetConn != nil {
+		srv.packets = append(srv.packets, packetConn)
+	}
+	srv.mu.Unlock()
+
+	httpListener := newChanListener(listener.Addr())
+	httpServer := &http.Server{
+		Handler:           srv.handler,
+		ReadHeaderTimeout: defaultSniffTimeout,
+		IdleTimeout:       srv.options.IdleTimeout,
+	}
+
+	waitGroup := sync.WaitGroup{}
+
+	waitGroup.Go(func() {
+		_ = httpServer.Serve(httpListener)
+	})
+
+	if packetConn != nil {
+		waitGroup.Go(func() {
+			srv.serveUDP(packetConn)
+		})
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = srv.Close() })
+	defer stop()
+
+	err := srv.acceptLoop(listener, httpListener)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultShutdownDelay)
+	defer cancel()
+
+	_ = httpServer.Shutdown(shutdownCtx)
+	_ = srv.Close()
+
+	waitGroup.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// Close stops all listeners and packet conns served by the server.
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.closed = true
+
+	var errs []error
+
+	for _, listener := range srv.listeners {
+		err := listener.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, packetConn := range srv.packets {
+		err := packetConn.Close()
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	srv.listeners = nil
+	srv.packets = nil
+
+	return errors.Join(errs...)
+}
+
+func (srv *Server) acceptLoop(listener net.Listener, httpListener *chanListener) error {
+	defer func() { _ = httpListener.Close() }()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return ErrServerClosed
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		go srv.dispatch(conn, httpListener)
+	}
+}
+
+// dispatch sniffs the first token sent by the client and routes the connection to
+// either the HTTP server or the line protocol handler.
+func (srv *Server) dispatch(conn net.Conn, httpListener *chanListener) {
+	reader := bufio.NewReader(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(defaultSniffTimeout))
+	token, err := sniffToken(reader)
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		_ = conn.Close()
+
+		return
+	}
+
+	sniffed := &sniffedConn{Conn: conn, reader: reader}
+
+	if httpMethods[token] {
+		if !httpListener.deliver(sniffed) {
+			_ = conn.Close()
+		}
+
+		return
+	}
+
+	srv.serveLine(sniffed)
+}
+
+// sniffToken peeks the first space or newline terminated token without consuming it.
+func sniffToken(reader *bufio.Reader) (string, error) {
+	for size := 1; size <= maxCommandLength; size++ {
+		peeked, err := reader.Peek(size)
+		if err != nil {
+			return "", fmt.Errorf("failed to peek command: %w", err)
+		}
+
+		last := peeked[size-1]
+		if last == ' ' || last == '\n' || last == '\r' {
+			return string(peeked[:size-1]), nil
+		}
+	}
+
+	peeked, _ := reader.Peek(maxCommandLength)
+
+	return string(peeked), nil
+}
+
+// sniffedConn is a net.Conn whose first bytes have been buffered by a reader.
+type sniffedConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// chanListener is a net.Listener fed with connections by the dispatcher.
+type chanListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newChanListener(addr net.Addr) *chanListener {
+	return &chanListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *chanListener) deliver(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// Accept waits for and returns the next dispatched connection.
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener.
+func (l *chanListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+
+	return nil
+}
+
+// Addr returns the address of the underlying listener.
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+// startServer serves a test server on a random local port and returns its host.
+func startServer(t *testing.T) string {
+	t.Helper()
+
+	lc := &net.ListenConfig{}
+
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	packetConn, err := lc.ListenPacket(context.Background(), "udp", listener.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := New(nil)
+	done := make(chan error, 1)
+
+	go func() { done <- srv.Serve(ctx, listener, packetConn) }()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return listener.Addr().String()
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *Options
+		want    Options
+	}{
+		{
+			name:    "nil options",
+			options: nil,
+			want: Options{
+				Addr:        DefaultAddr,
+				UDPAddr:     DefaultAddr,
+				Version:     DefaultVersion,
+				IdleTimeout: defaultIdleTimeout,
+			},
+		},
+		{
+			name:    "udp address follows tcp address",
+			options: &Options{Addr: ":9090", Version: "1.0"},
+			want: Options{
+				Addr:        ":9090",
+				UDPAddr:     ":9090",
+				Version:     "1.0",
+				IdleTimeout: defaultIdleTimeout,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := New(tt.options)
+			require.NotNil(t, srv)
+			assert.Equal(t, tt.want, *srv.options)
+			assert.NotNil(t, srv.Handler())
+		})
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	t.Parallel()
+
+	host := startServer(t)
+	ctx := context.Background()
+
+	t.Run("line protocol", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := transport.NewClient(&net.Dialer{})
+		require.NoError(t, err)
+		require.NoError(t, client.Connect(ctx, host))
+
+		assert.Equal(t, DefaultVersion, client.Version())
+
+		latency, err := client.PingContext(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, latency, int64(0))
+
+		require.NoError(t, client.Disconnect())
+	})
+
+	t.Run("tcp transfers", func(t *testing.T) {
+		t.Parallel()
+
+		client, err := transport.NewClient(&net.Dialer{})
+		require.NoError(t, err)
+		require.NoError(t, client.Connect(ctx, host))
+
+		reader, err := client.Download(1 << 20)
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Len(t, data, 1<<20)
+		assert.Equal(t, byte('\n'), data[len(data)-1])
+
+		received, err := client.Upload(1<<20, rand.Reader)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1<<20), received)
+
+		// the connection remains usable after the transfers.
+		_, err = client.PingContext(ctx)
+		require.NoError(t, err)
+	})
+
+	t.Run("http on the same port", func(t *testing.T) {
+		t.Parallel()
+
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodGet,
+			"http://"+host+"/speedtest/latency.txt",
+			nil,
+		)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "test=test\n", string(body))
+	})
+
+	t.Run("packet loss", func(t *testing.T) {
+		t.Parallel()
+
+		sampler, err := transport.NewClient(&net.Dialer{})
+		require.NoError(t, err)
+		require.NoError(t, sampler.Connect(ctx, host))
+
+		sender, err := transport.NewPacketLossSender(sampler.ID(), &net.Dialer{})
+		require.NoError(t, err)
+		require.NoError(t, sender.Connect(ctx, host))
+		require.NoError(t, sampler.InitPacketLoss())
+
+		// skip order 2 to simulate a lost packet and repeat order 3 as a duplicate.
+		for _, order := range []int{0, 1, 3, 3, 4} {
+			require.NoError(t, sender.Send(order))
+		}
+
+		require.Eventually(t, func() bool {
+			pl, err := sampler.PacketLoss()
+
+			return err == nil && pl.Sent == 5
+		}, 5*time.Second, 20*time.Millisecond)
+
+		pl, err := sampler.PacketLoss()
+		require.NoError(t, err)
+		assert.Equal(t, transport.PLoss{Sent: 5, Dup: 1, Max: 4}, *pl)
+		assert.InDelta(t, 0.2, pl.Loss(), 0.0001)
+	})
+}
+
+func TestServer_ServeClosed(t *testing.T) {
+	t.Parallel()
+
+	srv := New(nil)
+	require.NoError(t, srv.Close())
+
+	lc := &net.ListenConfig{}
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	err = srv.Serve(context.Background(), listener, nil)
+	require.ErrorIs(t, err, ErrServerClosed)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	commandHi        = "HI"
+	commandPing      = "PING"
+	commandInitPLoss = "INITPLOSS"
+	commandPLoss     = "PLOSS"
+	commandQuit      = "QUIT"
+	commandDownload  = "DOWNLOAD"
+	commandUpload    = "UPLOAD"
+
+	// maxTransferSize limits the size of a single DOWNLOAD or UPLOAD command.
+	maxTransferSize = 1 << 30
+)
+
+var errTransferSize = errors.New("transfer size out of range")
+
+// serveLine handles the line-based speedtest.net TCP protocol on a connection.
+func (srv *Server) serveLine(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+
+	var clientID string
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(srv.options.IdleTimeout))
+
+		line, err := reader.ReadString('\n')
+
+		command, args := parseCommand(line)
+		if command == commandQuit {
+			return
+		}
+
+		if err != nil {
+			return
+		}
+
+		switch command {
+		case commandHi:
+			if len(args) == 0 {
+				err = writeLine(conn, "HELLO "+srv.options.Version)
+			} else {
+				// HI <uuid> registers the packet loss session without a reply.
+				clientID = strings.ToUpper(args)
+			}
+		case commandPing:
+			err = writeLine(conn, fmt.Sprintf("PONG %d", time.Now().UnixMilli()))
+		case commandInitPLoss:
+			if len(clientID) > 0 {
+				srv.loss.reset(clientID)
+			}
+		case commandPLoss:
+			sent, dup, maxIndex := srv.loss.stats(clientID)
+			err = writeLine(conn, fmt.Sprintf("PLOSS %d %d %d", sent, dup, maxIndex))
+		case commandDownload:
+			err = handleTCPDownload(conn, args)
+		case commandUpload:
+			err = handleTCPUpload(conn, reader, args, int64(len(line)))
+		default:
+			err = writeLine(conn, "ERROR unknown command")
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleTCPDownload answers DOWNLOAD <size> with "DOWNLOAD ", random payload and a
+// newline, size bytes in total.
+func handleTCPDownload(w io.Writer, args string) error {
+	prefix := commandDownload + " "
+
+	size, err := parseTransferSize(args, int64(len(prefix))+1)
+	if err != nil {
+		return writeLine(w, "ERROR "+err.Error())
+	}
+
+	_, err = io.WriteString(w, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to write download header: %w", err)
+	}
+
+	err = writeRandom(w, size-int64(len(prefix))-1)
+	if err != nil {
+		return err
+	}
+
+	return writeLine(w, "")
+}
+
+// handleTCPUpload consumes an UPLOAD <size> 0 payload, whose size includes the
+// header line, and acknowledges it with "OK <size> <elapsed milliseconds>".
+func handleTCPUpload(w io.Writer, reader io.Reader, args string, headerSize int64) error {
+	start := time.Now()
+
+	sizeArg, _, _ := strings.Cut(args, " ")
+
+	size, err := parseTransferSize(sizeArg, headerSize)
+	if err != nil {
+		return writeLine(w, "ERROR "+err.Error())
+	}
+
+	_, err = io.CopyN(io.Discard, reader, size-headerSize)
+	if err != nil {
+		return fmt.Errorf("failed to read upload payload: %w", err)
+	}
+
+	return writeLine(w, fmt.Sprintf("OK %d %d", size, time.Since(start).Milliseconds()))
+}
+
+func parseTransferSize(arg string, minSize int64) (int64, error) {
+	size, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", arg, err)
+	}
+
+	if size < minSize || size > maxTransferSize {
+		return 0, fmt.Errorf("%w: %d", errTransferSize, size)
+	}
+
+	return size, nil
+}
+
+// parseCommand splits a protocol line into its command and argument string.
+func parseCommand(line string) (string, string) {
+	line = strings.TrimRight(line, "\r\n")
+	command, args, _ := strings.Cut(line, " ")
+
+	return strings.ToUpper(command), strings.TrimSpace(args)
+}
+
+func writeLine(w io.Writer, line string) error {
+	_, err := io.WriteString(w, line+"\n")
+	if err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+
+	return nil
+}
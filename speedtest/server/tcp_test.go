@@ -0,0 +1,66 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantCommand string
+		wantArgs    string
+	}{
+		{name: "bare command", line: "HI\n", wantCommand: commandHi},
+		{
+			name:        "command with args",
+			line:        "PING 1700000000000\n",
+			wantCommand: commandPing,
+			wantArgs:    "1700000000000",
+		},
+		{name: "crlf", line: "PLOSS\r\n", wantCommand: commandPLoss},
+		{name: "lower case", line: "quit", wantCommand: commandQuit},
+		{name: "empty", line: "", wantCommand: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			command, args := parseCommand(tt.line)
+			assert.Equal(t, tt.wantCommand, command)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func Test_parseTransferSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     string
+		minSize int64
+		want    int64
+		wantErr bool
+	}{
+		{name: "valid", arg: "1000000", minSize: 10, want: 1000000},
+		{name: "too small", arg: "5", minSize: 10, wantErr: true},
+		{name: "too large", arg: "2147483648", minSize: 10, wantErr: true},
+		{name: "not a number", arg: "abc", minSize: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseTransferSize(tt.arg, tt.minSize)
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lossFieldCount    = 4
+	maxDatagramSize   = 512
+	lossSessionExpiry = 5 * time.Minute
+)
+
+var lossPrefix = []byte("LOSS")
+
+// serveUDP collects packet loss datagrams until the packet conn is closed.
+func (srv *Server) serveUDP(packetConn net.PacketConn) {
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, _, err := packetConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		id, order, ok := parseLossDatagram(buf[:n])
+		if ok {
+			srv.loss.record(id, order)
+		}
+	}
+}
+
+// parseLossDatagram parses datagrams in the form of "LOSS <nounce> <order> <uuid>".
+func parseLossDatagram(datagram []byte) (string, int, bool) {
+	fields := bytes.Fields(datagram)
+	if len(fields) != lossFieldCount || !bytes.Equal(fields[0], lossPrefix) {
+		return "", 0, false
+	}
+
+	order, err := strconv.Atoi(string(fields[2]))
+	if err != nil || order < 0 {
+		return "", 0, false
+	}
+
+	return strings.ToUpper(string(fields[3])), order, true
+}
+
+// lossSession holds the datagrams received from one packet loss sender.
+type lossSession struct {
+	sent     int
+	dup      int
+	maxIndex int
+	seen     map[int]struct{}
+	updated  time.Time
+}
+
+// lossRegistry tracks packet loss sessions by client id.
+type lossRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*lossSession
+}
+
+func newLossRegistry() *lossRegistry {
+	return &lossRegistry{sessions: make(map[string]*lossSession)}
+}
+
+func (lr *lossRegistry) reset(id string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	now := time.Now()
+	for key, session := range lr.sessions {
+		if now.Sub(session.updated) > lossSessionExpiry {
+			delete(lr.sessions, key)
+		}
+	}
+
+	lr.sessions[id] = &lossSession{seen: make(map[int]struct{}), updated: now}
+}
+
+func (lr *lossRegistry) record(id string, order int) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	session, ok := lr.sessions[id]
+	if !ok {
+		// datagrams of unknown senders are ignored until INITPLOSS is received.
+		return
+	}
+
+	session.sent++
+	session.updated = time.Now()
+
+	if _, dup := session.seen[order]; dup {
+		session.dup++
+	} else {
+		session.seen[order] = struct{}{}
+	}
+
+	if order > session.maxIndex {
+		session.maxIndex = order
+	}
+}
+
+func (lr *lossRegistry) stats(id string) (int, int, int) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	session, ok := lr.sessions[id]
+	if !ok {
+		return 0, 0, 0
+	}
+
+	return session.sent, session.dup, session.maxIndex
+}
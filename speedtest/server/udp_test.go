@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseLossDatagram(t *testing.T) {
+	tests := []struct {
+		name      string
+		datagram  string
+		wantID    string
+		wantOrder int
+		wantOK    bool
+	}{
+		{
+			name:      "valid datagram",
+			datagram:  "LOSS 12345 7 0f8fad5b-d9cb-469f-a165-70867728950e",
+			wantID:    "0F8FAD5B-D9CB-469F-A165-70867728950E",
+			wantOrder: 7,
+			wantOK:    true,
+		},
+		{name: "wrong prefix", datagram: "PING 12345 7 abc", wantOK: false},
+		{name: "missing fields", datagram: "LOSS 12345 7", wantOK: false},
+		{name: "invalid order", datagram: "LOSS 12345 # abc", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id, order, ok := parseLossDatagram([]byte(tt.datagram))
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantOrder, order)
+		})
+	}
+}
+
+func Test_lossRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := newLossRegistry()
+
+	registry.record("unknown", 1)
+	sent, dup, maxIndex := registry.stats("unknown")
+	assert.Equal(t, []int{0, 0, 0}, []int{sent, dup, maxIndex})
+
+	registry.reset("ID")
+
+	for _, order := range []int{0, 2, 2, 5} {
+		registry.record("ID", order)
+	}
+
+	sent, dup, maxIndex = registry.stats("ID")
+	assert.Equal(t, []int{4, 1, 5}, []int{sent, dup, maxIndex})
+
+	registry.reset("ID")
+	sent, dup, maxIndex = registry.stats("ID")
+	assert.Equal(t, []int{0, 0, 0}, []int{sent, dup, maxIndex})
+}
@@ -88,6 +88,70 @@ func TestSpeedtest_CustomServer(t *testing.T) {
 	}
 }
 
+func TestSpeedtest_NewCustomServer(t *testing.T) {
+	t.Parallel()
+
+	client := New()
+
+	server, err := client.NewCustomServer(CustomServerOptions{URL: "https://example.com:8443/ignored?key=1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Custom", server.ID)
+	assert.Equal(t, "example.com:8443", server.Name)
+	assert.Equal(t, "example.com:8443", server.Host)
+	assert.Equal(t, "https://example.com:8443/speedtest/upload.php?key=1", server.URL)
+	assert.Empty(t, server.DownloadURL)
+	assert.Empty(t, server.LatencyURL)
+	assert.Same(t, client, server.Context)
+
+	server, err = client.NewCustomServer(CustomServerOptions{
+		URL:          "http://example.com",
+		ID:           "lab",
+		Name:         "Lab",
+		UploadPath:   "up/post.php",
+		DownloadPath: "/files",
+		LatencyPath:  "/ping/latency.txt",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "lab", server.ID)
+	assert.Equal(t, "Lab", server.Name)
+	assert.Equal(t, "http://example.com/up/post.php", server.URL)
+	assert.Equal(t, "http://example.com/files", server.DownloadURL)
+	assert.Equal(t, "http://example.com/ping/latency.txt", server.LatencyURL)
+
+	_, err = (*Speedtest)(nil).NewCustomServer(CustomServerOptions{URL: "http://example.com"})
+	require.Error(t, err)
+
+	_, err = client.NewCustomServer(CustomServerOptions{})
+	require.Error(t, err)
+}
+
+func TestServer_CustomPaths(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	client := New()
+	client.SetCaptureTime(300 * time.Millisecond)
+
+	// the local server only looks at the file names, so other directories work.
+	server, err := client.NewCustomServer(CustomServerOptions{
+		URL:          "http://" + host,
+		UploadPath:   "/up/upload.php",
+		DownloadPath: "/dl",
+		LatencyPath:  "/probe/latency.txt",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, server.PingTest(nil))
+	assert.Positive(t, server.Latency)
+
+	require.NoError(t, server.DownloadTest())
+	assert.Positive(t, server.DLSpeed)
+
+	server.LatencyURL = "http://127.0.0.1:1/latency.txt"
+	require.Error(t, server.PingTest(nil))
+}
+
 func TestServers_Available(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -112,6 +176,43 @@ func TestServers_Available(t *testing.T) {
 	}
 }
 
+func TestServers_Sort(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key     SortKey
+		want    []string
+		wantErr error
+	}{
+		{key: SortByDistance, want: []string{"1", "2", "4", "3"}},
+		{key: SortByLatency, want: []string{"2", "3", "1", "4"}},
+		{key: SortByName, want: []string{"4", "3", "1", "2"}},
+		{key: "sponsor", wantErr: ErrUnknownSortKey},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			t.Parallel()
+
+			servers := Servers{
+				{ID: "3", Name: "Busan", Distance: 900, Latency: 10 * time.Millisecond},
+				{ID: "4", Name: "at2wn", Distance: 125, Latency: PingTimeout},
+				{ID: "1", Name: "Shizuoka", Sponsor: "B", Distance: 9, Latency: 30 * time.Millisecond},
+				{ID: "2", Name: "shizuoka", Sponsor: "c", Distance: 120, Latency: 10 * time.Millisecond},
+			}
+
+			err := servers.Sort(tt.key)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, serverIDs(servers))
+		})
+	}
+}
+
 func TestServers_Len(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -463,6 +564,42 @@ func TestFetchServerListContext(t *testing.T) {
 	}
 }
 
+func TestSpeedtest_PrepareServers(t *testing.T) {
+	t.Parallel()
+
+	host := startLocalServer(t)
+
+	_, err := (*Speedtest)(nil).PrepareServers(context.Background(), Servers{})
+	require.Error(t, err)
+
+	client := New()
+
+	_, err = client.PrepareServers(context.Background(), Servers{})
+	require.ErrorIs(t, err, ErrServerNotFound)
+
+	client.User = &User{Lat: "35.68", Lon: "139.76"}
+	servers := Servers{
+		{ID: "1", Host: host, URL: "http://" + host + "/speedtest/upload.php", Lat: "34.69", Lon: "135.50"},
+		{ID: "2", Host: host, URL: "http://" + host + "/speedtest/upload.php", Lat: "35.68", Lon: "139.76"},
+		{ID: "3", Host: "127.0.0.1:1", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "?", Lon: "?", Distance: 50},
+	}
+
+	got, err := client.PrepareServers(context.Background(), servers)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+
+	assert.Equal(t, []string{"2", "3", "1"}, []string{got[0].ID, got[1].ID, got[2].ID})
+	assert.InDelta(t, 0, got[0].Distance, 0.001)
+	assert.InDelta(t, 50, got[1].Distance, 0, "servers without a location keep their distance")
+	assert.InDelta(t, 400, got[2].Distance, 10)
+	assert.Positive(t, got[0].Latency)
+	assert.Equal(t, time.Duration(PingTimeout), got[1].Latency)
+
+	for _, server := range got {
+		assert.Same(t, client, server.Context)
+	}
+}
+
 func Test_distance(t *testing.T) {
 	type args struct {
 		lat1 float64
@@ -609,6 +746,73 @@ func TestServer_String(t *testing.T) {
 	}
 }
 
+func TestServer_ResetResults(t *testing.T) {
+	t.Parallel()
+
+	duration := time.Second
+	server := &Server{
+		ID:              "1",
+		URL:             "http://example.com/speedtest/upload.php",
+		Distance:        12.5,
+		Latency:         20 * time.Millisecond,
+		Jitter:          time.Millisecond,
+		DLSpeed:         1000,
+		ULSpeed:         -1,
+		TestDuration:    TestDuration{Download: &duration},
+		Bufferbloat:     &Bufferbloat{Grade: GradeA},
+		TimeSeries:      &TimeSeries{},
+		DLBudgetLimited: true,
+		Connection:      &ConnectionInfo{},
+		PingPhases:      &PhaseTimings{},
+		DownloadPhases:  &PhaseTimings{},
+		Error:           "download: connection refused",
+	}
+
+	server.ResetResults()
+
+	assert.Equal(t, &Server{
+		ID:       "1",
+		URL:      "http://example.com/speedtest/upload.php",
+		Distance: 12.5,
+	}, server)
+
+	assert.NotPanics(t, func() { (*Server)(nil).ResetResults() })
+}
+
+func TestServer_Listing(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{
+		ID:          "1",
+		URL:         "https://example.com/speedtest/upload.php",
+		DownloadURL: "https://example.com/speedtest/",
+		Host:        "example.com:8080",
+		Distance:    12.5,
+		Latency:     20 * time.Millisecond,
+		DLSpeed:     1000,
+		IPFamily:    IPFamilyIPv4,
+		Context:     New(),
+		listedURLs: &serverURLs{
+			url:      "http://example.com/speedtest/upload.php",
+			download: "http://example.com/speedtest/",
+		},
+	}
+
+	// the listing is stored without the results and the HTTPS upgrade.
+	assert.Equal(t, &Server{
+		ID:          "1",
+		URL:         "http://example.com/speedtest/upload.php",
+		DownloadURL: "http://example.com/speedtest/",
+		Host:        "example.com:8080",
+		Distance:    12.5,
+	}, server.Listing())
+	assert.Equal(t, 20*time.Millisecond, server.Latency)
+
+	server.listedURLs = nil
+	assert.Equal(t, "https://example.com/speedtest/upload.php", server.Listing().URL)
+	assert.Nil(t, (*Server)(nil).Listing())
+}
+
 func TestServer_CheckResultValid(t *testing.T) {
 	tests := []struct {
 		name string
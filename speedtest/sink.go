@@ -0,0 +1,510 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInfluxMeasurement is the measurement name used by InfluxSink.
+	DefaultInfluxMeasurement = "speedtest"
+
+	defaultWebhookTimeout = 30 * time.Second
+	bitsPerByte           = 8
+)
+
+// ErrWebhookStatus is returned when a webhook responds with a non-2xx status.
+var ErrWebhookStatus = errors.New("unexpected webhook response status")
+
+// csvHeader is the stable column order of CSVSink.
+var csvHeader = []string{
+	"timestamp",
+	"server_id",
+	"server_name",
+	"sponsor",
+	"country",
+	"host",
+	"distance_km",
+	"ip",
+	"isp",
+	"latency_ms",
+	"jitter_ms",
+	"min_latency_ms",
+	"max_latency_ms",
+	"download_bps",
+	"upload_bps",
+	"packet_loss_percent",
+}
+
+// Result is the outcome of testing a single server.
+type Result struct {
+	Timestamp time.Time
+	User      *User
+	Server    *Server
+}
+
+// NewResult creates a result of the given server measured now.
+func (s *Speedtest) NewResult(server *Server) *Result {
+	return &Result{Timestamp: time.Now(), User: s.User, Server: server}
+}
+
+// MarshalJSON encodes the result like the JSONL output.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(singleServerOutput{
+		Timestamp: outputTime(r.Timestamp),
+		UserInfo:  r.User,
+		Server:    r.Server,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result to JSON: %w", err)
+	}
+
+	return data, nil
+}
+
+// ResultSink receives the results of tested servers.
+type ResultSink interface {
+	// WriteResult delivers the result of a single server.
+	WriteResult(result *Result) error
+	// Close flushes pending results. It does not close the underlying writer.
+	Close() error
+}
+
+// MultiSink delivers results to several sinks.
+type MultiSink []ResultSink
+
+// WriteResult writes the result to every sink, even if some of them fail.
+func (m MultiSink) WriteResult(result *Result) error {
+	var errs []error
+
+	for _, sink := range m {
+		err := sink.WriteResult(result)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes every sink.
+func (m MultiSink) Close() error {
+	var errs []error
+
+	for _, sink := range m {
+		err := sink.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SinkObserver is an observer writing the results of the events to a sink.
+type SinkObserver struct {
+	sink ResultSink
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewSinkObserver creates an observer writing the results to the sink.
+func NewSinkObserver(sink ResultSink) *SinkObserver {
+	return &SinkObserver{sink: sink}
+}
+
+// OnEvent writes the result of an EventResult event to the sink. Other events
+// are ignored.
+func (o *SinkObserver) OnEvent(event Event) {
+	if event.Type != EventResult || event.Result == nil {
+		return
+	}
+
+	err := o.sink.WriteResult(event.Result)
+	if err != nil {
+		o.mu.Lock()
+		o.errs = append(o.errs, err)
+		o.mu.Unlock()
+	}
+}
+
+// Err returns the errors of the results written so far.
+func (o *SinkObserver) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return errors.Join(o.errs...)
+}
+
+// JSONSink collects the results and writes them as a single JSON document on Close,
+// in the same format as Speedtest.JSON.
+type JSONSink struct {
+	writer io.Writer
+
+	mu      sync.Mutex
+	user    *User
+	servers Servers
+}
+
+// NewJSONSink creates a sink writing a JSON document to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{writer: w}
+}
+
+// WriteResult adds the result to the document.
+func (j *JSONSink) WriteResult(result *Result) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if result.User != nil {
+		j.user = result.User
+	}
+
+	j.servers = append(j.servers, result.Server)
+
+	return nil
+}
+
+// Close writes the document.
+func (j *JSONSink) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := json.Marshal(fullOutput{
+		Timestamp: outputTime(time.Now()),
+		UserInfo:  j.user,
+		Servers:   j.servers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal speedtest results to JSON: %w", err)
+	}
+
+	_, err = j.writer.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+
+	return nil
+}
+
+// JSONLSink writes every result as a line of JSON, in the same format as Speedtest.JSONL.
+type JSONLSink struct {
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewJSONLSink creates a sink writing JSON lines to w.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{writer: w}
+}
+
+// WriteResult writes the result as a line of JSON.
+func (j *JSONLSink) WriteResult(result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result to JSONL: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.writer.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write JSONL: %w", err)
+	}
+
+	return nil
+}
+
+// Close does nothing, results are written immediately.
+func (j *JSONLSink) Close() error {
+	return nil
+}
+
+// CSVSink writes every result as a CSV row with a stable column order.
+type CSVSink struct {
+	writer *csv.Writer
+	header bool
+
+	mu sync.Mutex
+}
+
+// NewCSVSink creates a sink writing CSV rows to w. The header row is written
+// before the first result when header is true, set it to false when appending to
+// an existing file.
+func NewCSVSink(w io.Writer, header bool) *CSVSink {
+	return &CSVSink{writer: csv.NewWriter(w), header: header}
+}
+
+// WriteResult writes the result as a CSV row. The results of failed tests, see
+// Server.Error, are left out, the columns have no place for the error.
+func (c *CSVSink) WriteResult(result *Result) error {
+	if len(result.Server.Error) > 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.header {
+		c.header = false
+
+		err := c.writer.Write(csvHeader)
+		if err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+
+	server := result.Server
+
+	var ip, isp string
+	if result.User != nil {
+		ip, isp = result.User.IP, result.User.Isp
+	}
+
+	err := c.writer.Write([]string{
+		result.Timestamp.Format(time.RFC3339Nano),
+		server.ID,
+		server.Name,
+		server.Sponsor,
+		server.Country,
+		server.Host,
+		strconv.FormatFloat(server.Distance, 'f', 2, 64),
+		ip,
+		isp,
+		formatMillis(server.Latency),
+		formatMillis(server.Jitter),
+		formatMillis(server.MinLatency),
+		formatMillis(server.MaxLatency),
+		formatBitRate(server.DLSpeed),
+		formatBitRate(server.ULSpeed),
+		formatPacketLoss(server),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+
+	c.writer.Flush()
+
+	err = c.writer.Error()
+	if err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes pending rows.
+func (c *CSVSink) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writer.Flush()
+
+	err := c.writer.Error()
+	if err != nil {
+		return fmt.Errorf("failed to flush CSV: %w", err)
+	}
+
+	return nil
+}
+
+// InfluxSink writes every result as a point in the InfluxDB line protocol.
+// Rates are in bits per second, latencies in milliseconds and packet loss in percent.
+type InfluxSink struct {
+	writer      io.Writer
+	measurement string
+	mu          sync.Mutex
+}
+
+// NewInfluxSink creates a sink writing line protocol to w. An empty measurement
+// defaults to DefaultInfluxMeasurement.
+func NewInfluxSink(w io.Writer, measurement string) *InfluxSink {
+	if len(measurement) == 0 {
+		measurement = DefaultInfluxMeasurement
+	}
+
+	return &InfluxSink{writer: w, measurement: measurement}
+}
+
+// WriteResult writes the result as a single line. The results of failed tests,
+// see Server.Error, are left out, the fields have no place for the error.
+func (i *InfluxSink) WriteResult(result *Result) error {
+	if len(result.Server.Error) > 0 {
+		return nil
+	}
+
+	line := InfluxLine(i.measurement, result)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	_, err := io.WriteString(i.writer, line)
+	if err != nil {
+		return fmt.Errorf("failed to write line protocol: %w", err)
+	}
+
+	return nil
+}
+
+// Close does nothing, results are written immediately.
+func (i *InfluxSink) Close() error {
+	return nil
+}
+
+// InfluxLine encodes the result as a newline terminated line of the InfluxDB line protocol.
+func InfluxLine(measurement string, result *Result) string {
+	server := result.Server
+	line := &strings.Builder{}
+
+	line.WriteString(influxEscaper.measurement.Replace(measurement))
+
+	tags := [][2]string{
+		{"server_id", server.ID},
+		{"server_name", server.Name},
+		{"sponsor", server.Sponsor},
+		{"country", server.Country},
+		{"host", server.Host},
+		{"source", server.Source},
+	}
+	if result.User != nil {
+		tags = append(tags, [2]string{"isp", result.User.Isp})
+	}
+
+	for _, tag := range tags {
+		// empty tag values are rejected by InfluxDB.
+		if len(tag[1]) > 0 {
+			line.WriteString("," + tag[0] + "=" + influxEscaper.tag.Replace(tag[1]))
+		}
+	}
+
+	fields := []string{
+		"latency_ms=" + formatMillis(server.Latency),
+		"jitter_ms=" + formatMillis(server.Jitter),
+		"min_latency_ms=" + formatMillis(server.MinLatency),
+		"max_latency_ms=" + formatMillis(server.MaxLatency),
+		"distance_km=" + strconv.FormatFloat(server.Distance, 'f', -1, 64),
+	}
+
+	if server.DLSpeed > 0 {
+		fields = append(fields, "download_bps="+formatBitRate(server.DLSpeed))
+	}
+
+	if server.ULSpeed > 0 {
+		fields = append(fields, "upload_bps="+formatBitRate(server.ULSpeed))
+	}
+
+	if server.PacketLoss.Sent > 0 {
+		fields = append(fields, "packet_loss_percent="+formatPacketLoss(server))
+	}
+
+	line.WriteString(" " + strings.Join(fields, ",") + " ")
+	line.WriteString(strconv.FormatInt(result.Timestamp.UnixNano(), 10))
+	line.WriteString("\n")
+
+	return line.String()
+}
+
+// influxEscaper escapes the special characters of the line protocol.
+var influxEscaper = struct {
+	measurement *strings.Replacer
+	tag         *strings.Replacer
+}{
+	measurement: strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`),
+	tag:         strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`),
+}
+
+// WebhookSink posts every result as JSON, in the same format as Speedtest.JSONL.
+type WebhookSink struct {
+	ctx    context.Context //nolint:containedctx // results are posted from observers without a context
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting results to url. A nil client defaults
+// to an HTTP client with a 30 second timeout.
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	return NewWebhookSinkContext(context.Background(), url, client)
+}
+
+// NewWebhookSinkContext is like NewWebhookSink, but the requests are canceled
+// once the context is done.
+func NewWebhookSinkContext(ctx context.Context, url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+
+	return &WebhookSink{ctx: ctx, url: url, client: client}
+}
+
+// WriteResult posts the result to the webhook.
+func (w *WebhookSink) WriteResult(result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result for webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		w.ctx,
+		http.MethodPost,
+		w.url,
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", ErrWebhookStatus, resp.Status)
+	}
+
+	return nil
+}
+
+// Close does nothing, results are posted immediately.
+func (w *WebhookSink) Close() error {
+	return nil
+}
+
+func formatMillis(duration time.Duration) string {
+	return strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// formatBitRate formats the rate in bits per second, or empty when it was not measured.
+func formatBitRate(rate ByteRate) string {
+	if rate <= 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(float64(rate)*bitsPerByte, 'f', 0, 64)
+}
+
+// formatPacketLoss formats the packet loss in percent, or empty when it is not available.
+func formatPacketLoss(server *Server) string {
+	if server.PacketLoss.Sent == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(server.PacketLoss.LossPercent(), 'f', 2, 64)
+}
@@ -0,0 +1,257 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
+)
+
+var errSinkTest = errors.New("sink failed")
+
+func testResult() *Result {
+	return &Result{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		User:      &User{IP: "192.0.2.1", Isp: "Example ISP"},
+		Server: &Server{
+			ID:         "1234",
+			Name:       "Tokyo",
+			Sponsor:    "Acme, Inc",
+			Country:    "Japan",
+			Host:       "example.com:8080",
+			Distance:   12.5,
+			Latency:    20 * time.Millisecond,
+			Jitter:     1500 * time.Microsecond,
+			MinLatency: 18 * time.Millisecond,
+			MaxLatency: 25 * time.Millisecond,
+			DLSpeed:    12500000,
+			ULSpeed:    -1,
+			PacketLoss: transport.PLoss{Sent: 99, Dup: 0, Max: 99},
+		},
+	}
+}
+
+type failingSink struct {
+	closed bool
+}
+
+func (f *failingSink) WriteResult(*Result) error { return errSinkTest }
+
+func (f *failingSink) Close() error {
+	f.closed = true
+
+	return nil
+}
+
+func TestMultiSink(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	failing := &failingSink{}
+	sinks := MultiSink{failing, NewJSONLSink(buffer)}
+
+	require.ErrorIs(t, sinks.WriteResult(testResult()), errSinkTest)
+	assert.Equal(t, 1, bytes.Count(buffer.Bytes(), []byte("\n")))
+
+	require.NoError(t, sinks.Close())
+	assert.True(t, failing.closed)
+}
+
+func TestSinkObserver(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	observer := NewSinkObserver(MultiSink{&failingSink{}, NewJSONLSink(buffer)})
+
+	observer.OnEvent(Event{Type: EventPingSample})
+	observer.OnEvent(Event{Type: EventResult})
+	require.NoError(t, observer.Err())
+	assert.Empty(t, buffer.String())
+
+	observer.OnEvent(Event{Type: EventResult, Result: testResult()})
+	require.ErrorIs(t, observer.Err(), errSinkTest)
+	assert.Equal(t, 1, bytes.Count(buffer.Bytes(), []byte("\n")))
+}
+
+func TestJSONSink(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	sink := NewJSONSink(buffer)
+
+	// a failed server is kept with its error.
+	failed := testResult()
+	failed.Server.Error = "download: refused"
+
+	require.NoError(t, sink.WriteResult(testResult()))
+	require.NoError(t, sink.WriteResult(failed))
+	assert.Empty(t, buffer.String())
+	require.NoError(t, sink.Close())
+
+	var output struct {
+		UserInfo *User    `json:"userInfo"`
+		Servers  []Server `json:"servers"`
+	}
+
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+	assert.Equal(t, "Example ISP", output.UserInfo.Isp)
+	require.Len(t, output.Servers, 2)
+	assert.Empty(t, output.Servers[0].Error)
+	assert.Equal(t, "download: refused", output.Servers[1].Error)
+}
+
+func TestJSONLSink(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	sink := NewJSONLSink(buffer)
+
+	require.NoError(t, sink.WriteResult(testResult()))
+	require.NoError(t, sink.Close())
+
+	assert.JSONEq(t, `{
+		"timestamp": "2026-01-02 03:04:05.000",
+		"userInfo": {"ip": "192.0.2.1", "lat": "", "lon": "", "isp": "Example ISP"},
+		"server": {
+			"url": "", "lat": "", "lon": "", "name": "Tokyo", "country": "Japan",
+			"sponsor": "Acme, Inc", "id": "1234", "host": "example.com:8080",
+			"distance": 12.5, "latency": 20000000, "maxLatency": 25000000,
+			"minLatency": 18000000, "jitter": 1500000, "dlSpeed": 12500000, "ulSpeed": -1,
+			"testDuration": {"ping": null, "download": null, "upload": null, "total": null},
+			"packetLoss": {"sent": 99, "dup": 0, "max": 99}
+		}
+	}`, buffer.String())
+}
+
+func TestCSVSink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header bool
+		want   string
+	}{
+		{
+			name:   "with header",
+			header: true,
+			want: "timestamp,server_id,server_name,sponsor,country,host,distance_km,ip,isp," +
+				"latency_ms,jitter_ms,min_latency_ms,max_latency_ms,download_bps,upload_bps,packet_loss_percent\n" +
+				`2026-01-02T03:04:05Z,1234,Tokyo,"Acme, Inc",Japan,example.com:8080,12.50,192.0.2.1,Example ISP,` +
+				"20.000,1.500,18.000,25.000,100000000,,1.00\n",
+		},
+		{
+			name:   "without header",
+			header: false,
+			want: `2026-01-02T03:04:05Z,1234,Tokyo,"Acme, Inc",Japan,example.com:8080,12.50,192.0.2.1,Example ISP,` +
+				"20.000,1.500,18.000,25.000,100000000,,1.00\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buffer := &bytes.Buffer{}
+			sink := NewCSVSink(buffer, tt.header)
+
+			failed := testResult()
+			failed.Server.Error = "download: refused"
+
+			require.NoError(t, sink.WriteResult(failed))
+			require.NoError(t, sink.WriteResult(testResult()))
+			require.NoError(t, sink.Close())
+			assert.Equal(t, tt.want, buffer.String())
+		})
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	t.Parallel()
+
+	result := testResult()
+	assert.Equal(t,
+		`speedtest,server_id=1234,server_name=Tokyo,sponsor=Acme\,\ Inc,country=Japan,`+
+			`host=example.com:8080,isp=Example\ ISP `+
+			"latency_ms=20.000,jitter_ms=1.500,min_latency_ms=18.000,max_latency_ms=25.000,"+
+			"distance_km=12.5,download_bps=100000000,packet_loss_percent=1.00 1767323045000000000\n",
+		InfluxLine(DefaultInfluxMeasurement, result),
+	)
+
+	result.User = nil
+	result.Server.Name = ""
+	result.Server.PacketLoss = transport.PLoss{}
+	assert.Equal(t,
+		`net\ speed,server_id=1234,sponsor=Acme\,\ Inc,country=Japan,host=example.com:8080 `+
+			"latency_ms=20.000,jitter_ms=1.500,min_latency_ms=18.000,max_latency_ms=25.000,"+
+			"distance_km=12.5,download_bps=100000000 1767323045000000000\n",
+		InfluxLine("net speed", result),
+	)
+
+	result.Server.Source = "eth1"
+	assert.Equal(t,
+		`net\ speed,server_id=1234,sponsor=Acme\,\ Inc,country=Japan,host=example.com:8080,source=eth1 `+
+			"latency_ms=20.000,jitter_ms=1.500,min_latency_ms=18.000,max_latency_ms=25.000,"+
+			"distance_km=12.5,download_bps=100000000 1767323045000000000\n",
+		InfluxLine("net speed", result),
+	)
+}
+
+func TestInfluxSink(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	sink := NewInfluxSink(buffer, "")
+
+	failed := testResult()
+	failed.Server.Error = "download: refused"
+
+	require.NoError(t, sink.WriteResult(failed))
+	require.NoError(t, sink.WriteResult(testResult()))
+	require.NoError(t, sink.Close())
+	assert.Equal(t, InfluxLine(DefaultInfluxMeasurement, testResult()), buffer.String())
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	var received []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		received, _ = io.ReadAll(r.Body)
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL+"/hook", nil)
+	require.NoError(t, sink.WriteResult(testResult()))
+	require.NoError(t, sink.Close())
+
+	expected, err := json.Marshal(testResult())
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(received))
+
+	sink = NewWebhookSink(server.URL+"/fail", server.Client())
+	require.ErrorIs(t, sink.WriteResult(testResult()), ErrWebhookStatus)
+
+	// a hanging webhook is given up once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sink = NewWebhookSinkContext(ctx, server.URL+"/hook", nil)
+	require.ErrorIs(t, sink.WriteResult(testResult()), context.Canceled)
+}
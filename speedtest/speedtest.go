@@ -2,6 +2,7 @@ package speedtest
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -9,8 +10,11 @@ import (
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/nicholas-fedor/speedtest-go/speedtest/transport"
 )
 
 var (
@@ -46,23 +50,49 @@ type Speedtest struct {
 
 	doer      *http.Client
 	config    *UserConfig
+	dbg       *Debug          // debug logger of the client, shared with its data manager
+	serverT   *http.Transport // transport of the test servers with the TLS server name override
 	tcpDialer *net.Dialer
+	udpDialer *net.Dialer
 	ipDialer  *net.Dialer
+
+	transportDialer transport.Dialer // dialer of the TCP protocol, through the proxy if any
+
+	observers observers // observers of the events of the tests
 }
 
 // UserConfig holds configuration options for speedtest.
 type UserConfig struct {
 	T             *http.Transport
 	UserAgent     string
-	Proxy         string
+	Proxy         string // URL of an HTTP, HTTPS or SOCKS5 proxy, credentials as user info
 	Source        string
 	DNSBindSource bool
+	DNSServer     string // resolves the names with this DNS server, port 53 if none is given
 	DialerControl func(network, address string, c syscall.RawConn) error
+	IPFamily      IPFamily // forces all connections to one address family, either if empty; not applied through a proxy
 	Debug         bool
+	Unit          UnitType // unit of the rates formatted by FormatRate, auto-scaled bits if zero
 	PingMode      Proto
+	TransferMode  Proto // HTTP or TCP, the protocol used by download and upload tests
+
+	HTTPS                 HTTPSMode // upgrades the test URLs of the servers to HTTPS, off if empty
+	TLSCAFile             string    // PEM bundle of CAs trusted in addition to the system roots
+	TLSCertFile           string    // PEM client certificate, set together with TLSKeyFile
+	TLSKeyFile            string    // PEM private key of the client certificate
+	TLSServerName         string    // server name sent to and verified for the test servers, their host if empty
+	TLSInsecureSkipVerify bool      // skips the verification of the certificates, for lab servers only
 
 	SavingMode     bool
 	MaxConnections int
+	TimeSeries     bool // keep the per-interval samples of the transfer tests in Server.TimeSeries
+
+	MaxDuration time.Duration // maximum duration of a transfer test, 15s if zero
+	MinDuration time.Duration // a transfer test is not stopped earlier, even if the rate is stable
+	WarmUp      time.Duration // samples at the start of a transfer test excluded from the rate
+	StabilityCV float64       // coefficient of variation a transfer test is stopped below, 0.03 if zero
+
+	MaxDataVolume int64 // data budget of a transfer test in bytes, per direction, unlimited if zero
 
 	CityFlag     string
 	LocationFlag string
@@ -80,10 +110,40 @@ func parseAddr(addr string) (string, string) {
 	return "", addr // ignore address network prefix
 }
 
+// Validate checks the options that NewUserConfig skips with a warning: the
+// source address, the proxy and the TLS options.
+func (c *UserConfig) Validate() error {
+	if len(c.Source) > 0 {
+		_, address := parseAddr(c.Source)
+
+		_, err := net.ResolveIPAddr("ip", address)
+		if err != nil {
+			return fmt.Errorf("failed to parse the source address: %w", err)
+		}
+	}
+
+	if len(c.Proxy) > 0 {
+		_, err := parseProxy(c.Proxy)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := c.TLSConfig()
+
+	return err
+}
+
 // NewUserConfig sets the user configuration for the speedtest instance.
 func (s *Speedtest) NewUserConfig(userConfig *UserConfig) {
+	if s.dbg == nil {
+		s.dbg = NewDebug()
+	}
+
 	if userConfig.Debug {
-		dbg.Enable()
+		s.dbg.Enable()
+	} else {
+		s.dbg.Disable()
 	}
 
 	if userConfig.SavingMode {
@@ -92,12 +152,23 @@ func (s *Speedtest) NewUserConfig(userConfig *UserConfig) {
 
 	s.SetNThread(userConfig.MaxConnections)
 
+	if userConfig.MaxDuration > 0 {
+		s.SetCaptureTime(userConfig.MaxDuration)
+	}
+
+	if limiter, ok := s.Manager.(TestLimiter); ok {
+		limiter.SetMinDuration(userConfig.MinDuration)
+		limiter.SetWarmUp(userConfig.WarmUp)
+		limiter.SetStabilityThreshold(userConfig.StabilityCV)
+		limiter.SetMaxDataVolume(userConfig.MaxDataVolume)
+	}
+
 	if len(userConfig.CityFlag) > 0 {
 		var err error
 
 		userConfig.Location, err = GetLocation(userConfig.CityFlag)
 		if err != nil {
-			dbg.Printf("Warning: skipping command line arguments: --city. err: %v\n", err.Error())
+			s.dbg.Printf("Warning: skipping command line arguments: --city. err: %v\n", err.Error())
 		}
 	}
 
@@ -106,95 +177,68 @@ func (s *Speedtest) NewUserConfig(userConfig *UserConfig) {
 
 		userConfig.Location, err = ParseLocation(userConfig.CityFlag, userConfig.LocationFlag)
 		if err != nil {
-			dbg.Printf(
+			s.dbg.Printf(
 				"Warning: skipping command line arguments: --location. err: %v\n",
 				err.Error(),
 			)
 		}
 	}
 
-	var tcpSource net.Addr // If nil, a local address is automatically chosen.
-
-	var (
-		icmpSource net.Addr
-		proxy      = http.ProxyFromEnvironment
-	)
-
 	s.config = userConfig
 	if len(s.config.UserAgent) == 0 {
 		s.config.UserAgent = DefaultUserAgent
 	}
 
-	if len(userConfig.Source) == 0 {
-		return
-	}
+	s.setupDialers()
+}
 
-	_, address := parseAddr(userConfig.Source)
+// setupDialers creates the dialers and the HTTP transport of the client. The
+// source address, the resolver and the proxy apply independently of each other
+// to the connections of every test. Without any of them, the transport is
+// equivalent to http.DefaultTransport and records the connections of the tests.
+func (s *Speedtest) setupDialers() {
+	var source *net.IPAddr // If nil, a local address is automatically chosen.
 
-	addr0, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("[%s]:0", address))
-	if err == nil {
-		tcpSource = addr0
-	} else {
-		dbg.Printf("Warning: skipping parse the source address. err: %s\n", err.Error())
-	}
+	if len(s.config.Source) > 0 {
+		_, address := parseAddr(s.config.Source)
 
-	addr1, err := net.ResolveIPAddr("ip", address)
-	if err == nil {
-		icmpSource = addr1
-	} else {
-		dbg.Printf("Warning: skipping parse the source address. err: %s\n", err.Error())
-	}
-
-	if !userConfig.DNSBindSource {
-		return
-	}
-
-	net.DefaultResolver.Dial = func(ctx context.Context, network, dnsServer string) (net.Conn, error) {
-		dialer := &net.Dialer{
-			Timeout: 5 * time.Second,
-			LocalAddr: func(network string) net.Addr {
-				switch network {
-				case "udp", "udp4", "udp6":
-					return &net.UDPAddr{IP: net.ParseIP(address)}
-				case "tcp", "tcp4", "tcp6":
-					return &net.TCPAddr{IP: net.ParseIP(address)}
-				default:
-					return nil
-				}
-			}(network),
+		addr, err := net.ResolveIPAddr("ip", address)
+		if err == nil {
+			source = addr
+		} else {
+			s.dbg.Printf("Warning: skipping parse the source address. err: %s\n", err.Error())
 		}
-
-		return dialer.DialContext(ctx, network, dnsServer)
 	}
 
-	if len(userConfig.Proxy) > 0 {
-		parse, err := url.Parse(userConfig.Proxy)
+	resolver := newResolver(s.config.DNSServer, source, s.config.DNSBindSource)
+
+	s.tcpDialer = newDialer("tcp", source, resolver, s.config.DialerControl)
+	s.udpDialer = newDialer("udp", source, resolver, s.config.DialerControl)
+	s.ipDialer = newDialer("ip", source, resolver, s.config.DialerControl)
+	s.transportDialer = familyDialer{dialer: s.tcpDialer, family: s.config.IPFamily}
+	dialContext := s.transportDialer.DialContext
+
+	proxy := http.ProxyFromEnvironment
+
+	if len(s.config.Proxy) > 0 {
+		// an invalid proxy fails every connection rather than bypassing it.
+		proxyURL, err := parseProxy(s.config.Proxy)
 		if err != nil {
-			dbg.Printf("Warning: skipping parse the proxy host. err: %s\n", err.Error())
+			s.dbg.Printf("Warning: failed to parse the proxy. err: %s\n", err.Error())
+
+			proxy = func(_ *http.Request) (*url.URL, error) { return nil, err }
 		} else {
-			proxy = func(_ *http.Request) (*url.URL, error) {
-				return parse, nil
-			}
+			proxy = http.ProxyURL(proxyURL)
 		}
-	}
 
-	s.tcpDialer = &net.Dialer{
-		LocalAddr: tcpSource,
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		Control:   userConfig.DialerControl,
-	}
-
-	s.ipDialer = &net.Dialer{
-		LocalAddr: icmpSource,
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		Control:   userConfig.DialerControl,
+		// the family would apply to the address of the proxy, not of the servers.
+		s.transportDialer = newProxyDialer(proxyURL, err, s.tcpDialer)
+		dialContext = s.tcpDialer.DialContext
 	}
 
 	s.config.T = &http.Transport{
 		Proxy:                 proxy,
-		DialContext:           s.tcpDialer.DialContext,
+		DialContext:           dialContext,
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
@@ -202,9 +246,112 @@ func (s *Speedtest) NewUserConfig(userConfig *UserConfig) {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
+	tlsConfig, err := s.config.TLSConfig()
+	if err != nil {
+		s.dbg.Printf("Warning: skipping the TLS options. err: %s\n", err.Error())
+	} else {
+		s.config.T.TLSClientConfig = tlsConfig
+	}
+
+	// the server name override must not apply to speedtest.net itself.
+	s.serverT = nil
+
+	if len(s.config.TLSServerName) > 0 {
+		s.serverT = s.config.T.Clone()
+		if s.serverT.TLSClientConfig == nil {
+			s.serverT.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+
+		s.serverT.TLSClientConfig.ServerName = s.config.TLSServerName
+	}
+
+	// the default client is shared, clients with other dialers must not replace
+	// each other's transport.
+	if s.doer == http.DefaultClient {
+		s.doer = &http.Client{}
+	}
+
 	s.doer.Transport = s
 }
 
+// proxied reports whether the client sends its connections through a proxy.
+func (s *Speedtest) proxied() bool {
+	return len(s.config.Proxy) > 0
+}
+
+// newDialer returns a dialer of the network bound to the source address, if any.
+func newDialer(
+	network string,
+	source *net.IPAddr,
+	resolver *net.Resolver,
+	control func(network, address string, c syscall.RawConn) error,
+) *net.Dialer {
+	return &net.Dialer{
+		LocalAddr: localAddr(network, source),
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Resolver:  resolver,
+		Control:   control,
+	}
+}
+
+// localAddr returns the source address of the connections of the network, or
+// nil to let the system choose it.
+func localAddr(network string, source *net.IPAddr) net.Addr {
+	if source == nil {
+		return nil
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		return &net.UDPAddr{IP: source.IP, Zone: source.Zone}
+	case "tcp", "tcp4", "tcp6":
+		return &net.TCPAddr{IP: source.IP, Zone: source.Zone}
+	default:
+		return source
+	}
+}
+
+// newResolver returns the resolver of the dialers, which queries the DNS
+// server, if any, from the source address if bindSource is set. It returns nil,
+// the system resolver, if neither applies.
+func newResolver(dnsServer string, source *net.IPAddr, bindSource bool) *net.Resolver {
+	if !bindSource {
+		source = nil
+	}
+
+	if len(dnsServer) == 0 && source == nil {
+		return nil
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if len(dnsServer) > 0 {
+				address = dnsServerAddress(dnsServer)
+			}
+
+			dialer := &net.Dialer{
+				Timeout:   5 * time.Second,
+				LocalAddr: localAddr(network, source),
+			}
+
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// dnsServerAddress returns the address of the DNS server with port 53 if none
+// is given.
+func dnsServerAddress(dnsServer string) string {
+	_, _, err := net.SplitHostPort(dnsServer)
+	if err == nil {
+		return dnsServer
+	}
+
+	return net.JoinHostPort(strings.Trim(dnsServer, "[]"), "53")
+}
+
 // RoundTrip executes a single HTTP request using the speedtest client's round tripper.
 func (s *Speedtest) RoundTrip(req *http.Request) (*http.Response, error) {
 	if s == nil {
@@ -216,12 +363,27 @@ func (s *Speedtest) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 
 	req.Header.Add("User-Agent", s.config.UserAgent)
+	req = traceConnection(req)
 
-	resp, err := s.config.T.RoundTrip(req)
+	roundTripper := s.config.T
+	if s.serverT != nil && isServerRequest(req.Context()) {
+		roundTripper = s.serverT
+	}
+
+	resp, err := roundTripper.RoundTrip(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to round trip request: %w", err)
 	}
 
+	if info := connectionInfoFrom(req.Context()); info != nil {
+		var proxyURL *url.URL
+		if roundTripper.Proxy != nil {
+			proxyURL, _ = roundTripper.Proxy(req)
+		}
+
+		info.recordResponse(resp, proxyURL != nil)
+	}
+
 	return resp, nil
 }
 
@@ -242,20 +404,23 @@ func WithDoer(doer *http.Client) Option {
 func WithUserConfig(userConfig *UserConfig) Option {
 	return func(s *Speedtest) {
 		s.NewUserConfig(userConfig)
-		dbg.Printf("Source: %s\n", s.config.Source)
-		dbg.Printf("Proxy: %s\n", s.config.Proxy)
-		dbg.Printf("SavingMode: %v\n", s.config.SavingMode)
-		dbg.Printf("Keyword: %v\n", s.config.Keyword)
-		dbg.Printf("PingType: %v\n", s.config.PingMode)
-		dbg.Printf("OS: %s, ARCH: %s, NumCPU: %d\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+		s.dbg.Printf("Source: %s\n", s.config.Source)
+		s.dbg.Printf("Proxy: %s\n", s.config.Proxy)
+		s.dbg.Printf("SavingMode: %v\n", s.config.SavingMode)
+		s.dbg.Printf("Keyword: %v\n", s.config.Keyword)
+		s.dbg.Printf("PingType: %v\n", s.config.PingMode)
+		s.dbg.Printf("TransferType: %v\n", s.config.TransferMode)
+		s.dbg.Printf("OS: %s, ARCH: %s, NumCPU: %d\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
 	}
 }
 
 // New creates a new speedtest client.
 func New(opts ...Option) *Speedtest {
+	manager := NewDataManager()
 	s := &Speedtest{
 		doer:    http.DefaultClient,
-		Manager: NewDataManager(),
+		Manager: manager,
+		dbg:     manager.dbg,
 	}
 	// load default config
 	s.NewUserConfig(&UserConfig{UserAgent: DefaultUserAgent})
@@ -272,4 +437,7 @@ func Version() string {
 	return version
 }
 
-var defaultClient = New()
+// defaultClient is the client of the package level functions, created on
+// first use. The servers and user it fetches are shared by all callers, so
+// concurrent users should create clients of their own with New.
+var defaultClient = sync.OnceValue(func() *Speedtest { return New() })
@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"time"
+)
+
+// RateSample is the throughput of a single rate capture interval.
+type RateSample struct {
+	Time    time.Time     `json:"time"`
+	Elapsed time.Duration `json:"elapsed"` // since the start of the test
+	Bytes   int64         `json:"bytes"`   // bytes transferred within the interval
+	Rate    ByteRate      `json:"rate"`    // rate within the interval
+	EWMA    ByteRate      `json:"ewma"`    // moving average the final rate is taken from
+	WarmUp  bool          `json:"warmUp"`  // excluded from the final rate
+}
+
+// LatencySample is a latency measured while a transfer test is running.
+type LatencySample struct {
+	Time    time.Time     `json:"time"`
+	Elapsed time.Duration `json:"elapsed"` // since the start of the test
+	Latency time.Duration `json:"latency"`
+}
+
+// DirectionSeries is the time series of a download or upload test.
+type DirectionSeries struct {
+	Start     time.Time       `json:"start"`
+	Rates     []RateSample    `json:"rates"`
+	Latencies []LatencySample `json:"latencies,omitempty"`
+}
+
+// TimeSeries holds the samples collected during the transfer tests, see
+// UserConfig.TimeSeries.
+type TimeSeries struct {
+	Download *DirectionSeries `json:"download,omitempty"`
+	Upload   *DirectionSeries `json:"upload,omitempty"`
+}
+
+// Series returns the series of the given direction, or nil if it was not recorded.
+func (t *TimeSeries) Series(direction Direction) *DirectionSeries {
+	if t == nil {
+		return nil
+	}
+
+	switch direction {
+	case DirectionDownload:
+		return t.Download
+	case DirectionUpload:
+		return t.Upload
+	default:
+		return nil
+	}
+}
+
+// recordRateSamples keeps the rate samples of a finished test direction when
+// the time series is enabled.
+func (s *Server) recordRateSamples(testDirection *TestDirection) {
+	if s.Context == nil || s.Context.config == nil || !s.Context.config.TimeSeries {
+		return
+	}
+
+	if s.TimeSeries == nil {
+		s.TimeSeries = &TimeSeries{}
+	}
+
+	series := &DirectionSeries{
+		Start: testDirection.StartTime(),
+		Rates: testDirection.RateSamples(),
+	}
+
+	switch testDirection.Direction() {
+	case DirectionDownload:
+		s.TimeSeries.Download = series
+	case DirectionUpload:
+		s.TimeSeries.Upload = series
+	}
+}
+
+// recordLatencySamples adds the latencies measured under load during the
+// transfer test of the given direction to its time series. The elapsed time of
+// every sample is set relative to the start of the test. It does nothing if the
+// series of that direction was not recorded.
+func (s *Server) recordLatencySamples(direction Direction, samples []LatencySample) {
+	series := s.TimeSeries.Series(direction)
+	if series == nil {
+		return
+	}
+
+	series.Latencies = make([]LatencySample, 0, len(samples))
+	for _, sample := range samples {
+		sample.Elapsed = sample.Time.Sub(series.Start)
+		series.Latencies = append(series.Latencies, sample)
+	}
+}
@@ -0,0 +1,90 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_TimeSeries(t *testing.T) {
+	host := startLocalServer(t)
+
+	tests := []struct {
+		name       string
+		timeSeries bool
+	}{
+		{name: "enabled", timeSeries: true},
+		{name: "disabled", timeSeries: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := New(WithUserConfig(&UserConfig{TimeSeries: tt.timeSeries, MaxConnections: 2}))
+			client.SetCaptureTime(500 * time.Millisecond)
+
+			target, err := client.CustomServer("http://" + host)
+			require.NoError(t, err)
+
+			require.NoError(t, target.DownloadTest())
+			require.NoError(t, target.UploadTest())
+
+			if !tt.timeSeries {
+				assert.Nil(t, target.TimeSeries)
+
+				return
+			}
+
+			require.NotNil(t, target.TimeSeries)
+
+			for _, series := range []*DirectionSeries{target.TimeSeries.Download, target.TimeSeries.Upload} {
+				require.NotNil(t, series)
+				require.NotEmpty(t, series.Rates)
+
+				var total int64
+				for i, sample := range series.Rates {
+					total += sample.Bytes
+
+					assert.False(t, sample.Time.Before(series.Start))
+					if i > 0 {
+						assert.Greater(t, sample.Elapsed, series.Rates[i-1].Elapsed)
+					}
+				}
+
+				assert.Positive(t, total)
+			}
+
+			data, err := json.Marshal(target)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `"timeSeries":{"download":{"start":`)
+		})
+	}
+}
+
+func TestServer_recordLatencySamples(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	samples := []LatencySample{
+		{Time: start.Add(500 * time.Millisecond), Latency: 20 * time.Millisecond},
+		{Time: start.Add(time.Second), Latency: 30 * time.Millisecond},
+	}
+
+	server := &Server{}
+	server.recordLatencySamples(DirectionDownload, samples)
+	assert.Nil(t, server.TimeSeries)
+
+	server.TimeSeries = &TimeSeries{Download: &DirectionSeries{Start: start}}
+	server.recordLatencySamples(DirectionUpload, samples)
+	assert.Nil(t, server.TimeSeries.Upload)
+
+	server.recordLatencySamples(DirectionDownload, samples)
+	require.Len(t, server.TimeSeries.Download.Latencies, 2)
+	assert.Equal(t, 500*time.Millisecond, server.TimeSeries.Download.Latencies[0].Elapsed)
+	assert.Equal(t, time.Second, server.TimeSeries.Download.Latencies[1].Elapsed)
+	assert.Equal(t, 30*time.Millisecond, server.TimeSeries.Download.Latencies[1].Latency)
+	assert.Zero(t, samples[0].Elapsed, "the input must not be modified")
+}
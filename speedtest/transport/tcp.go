@@ -6,19 +6,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"time"
 )
 
 var (
-	pingPrefix = []byte{0x50, 0x49, 0x4e, 0x47, 0x20}
-	// downloadPrefix = []byte{0x44, 0x4F, 0x57, 0x4E, 0x4C, 0x4F, 0x41, 0x44, 0x20}
-	// uploadPrefix   = []byte{0x55, 0x50, 0x4C, 0x4F, 0x41, 0x44, 0x20}.
-	initPacket = []byte{0x49, 0x4e, 0x49, 0x54, 0x50, 0x4c, 0x4f, 0x53, 0x53}
-	packetLoss = []byte{0x50, 0x4c, 0x4f, 0x53, 0x53}
-	hiFormat   = []byte{0x48, 0x49}
-	quitFormat = []byte{0x51, 0x55, 0x49, 0x54}
+	pingPrefix     = []byte{0x50, 0x49, 0x4e, 0x47, 0x20}
+	downloadPrefix = []byte{0x44, 0x4F, 0x57, 0x4E, 0x4C, 0x4F, 0x41, 0x44, 0x20}
+	uploadPrefix   = []byte{0x55, 0x50, 0x4C, 0x4F, 0x41, 0x44, 0x20}
+	okPrefix       = []byte{0x4F, 0x4B, 0x20}
+	initPacket     = []byte{0x49, 0x4e, 0x49, 0x54, 0x50, 0x4c, 0x4f, 0x53, 0x53}
+	packetLoss     = []byte{0x50, 0x4c, 0x4f, 0x53, 0x53}
+	hiFormat       = []byte{0x48, 0x49}
+	quitFormat     = []byte{0x51, 0x55, 0x49, 0x54}
 )
 
 var (
@@ -34,6 +36,10 @@ var (
 	ErrUninitializedPacketLossInst = errors.New("uninitialized packet loss inst")
 	// ErrInvalidPacketLossResponse is returned when the packet loss response is invalid.
 	ErrInvalidPacketLossResponse = errors.New("invalid packet loss response")
+	// ErrInvalidTransferSize is returned when a download or upload size is too small.
+	ErrInvalidTransferSize = errors.New("invalid transfer size")
+	// ErrInvalidUploadResponse is returned when the upload response is invalid.
+	ErrInvalidUploadResponse = errors.New("invalid upload response")
 )
 
 func pingFormat(locTime int64) []byte {
@@ -47,13 +53,18 @@ type Client struct {
 	host    string
 	version string
 
-	dialer *net.Dialer
+	dialer Dialer
 
 	reader *bufio.Reader
 }
 
+// Dialer dials the connections of the clients, e.g. a *net.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // NewClient creates a new TCP client with the given dialer.
-func NewClient(dialer *net.Dialer) (*Client, error) {
+func NewClient(dialer Dialer) (*Client, error) {
 	uuid, err := generateUUID()
 	if err != nil {
 		return nil, err
@@ -85,6 +96,39 @@ func (client *Client) Connect(ctx context.Context, host string) error {
 	return nil
 }
 
+// LocalAddr returns the local address of the connection, or nil before Connect.
+func (client *Client) LocalAddr() net.Addr {
+	if client.conn == nil {
+		return nil
+	}
+
+	return client.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the connection, or nil before Connect.
+func (client *Client) RemoteAddr() net.Addr {
+	if client.conn == nil {
+		return nil
+	}
+
+	return client.conn.RemoteAddr()
+}
+
+// Close closes the underlying connection without sending QUIT.
+// It can be used to abort a transfer that is still in progress.
+func (client *Client) Close() error {
+	if client.conn == nil {
+		return ErrEmptyConn
+	}
+
+	err := client.conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close connection: %w", err)
+	}
+
+	return nil
+}
+
 // Disconnect closes the client connection.
 func (client *Client) Disconnect() error {
 	_, _ = client.conn.Write(quitFormat)
@@ -306,12 +350,65 @@ func (client *Client) PacketLoss() (*PLoss, error) {
 	}, nil
 }
 
-// Download performs a download test. Currently unimplemented.
-func (client *Client) Download() {
-	panic("Unimplemented method: Client.Download()")
+// Download requests size bytes from the server with the DOWNLOAD command.
+// The server answers with "DOWNLOAD " followed by random data and a newline,
+// size bytes in total. The returned reader yields exactly those bytes and must
+// be consumed before another command is sent on the connection.
+func (client *Client) Download(size int64) (io.Reader, error) {
+	if size < int64(len(downloadPrefix))+1 {
+		return nil, ErrInvalidTransferSize
+	}
+
+	err := client.Write(strconv.AppendInt(bytes.Clone(downloadPrefix), size, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.LimitReader(client.reader, size), nil
 }
 
-// Upload performs an upload test. Currently unimplemented.
-func (client *Client) Upload() {
-	panic("Unimplemented method: Client.Upload()")
+// Upload sends size bytes to the server with the UPLOAD command.
+// The size includes the "UPLOAD <size> 0" header line, the remaining bytes are
+// read from the payload and terminated with a newline. It returns the number of
+// bytes acknowledged by the server.
+func (client *Client) Upload(size int64, payload io.Reader) (int64, error) {
+	header := fmt.Appendf(bytes.Clone(uploadPrefix), "%d 0", size)
+
+	remaining := size - int64(len(header)) - 2 // header and trailing newlines
+	if remaining < 0 {
+		return 0, ErrInvalidTransferSize
+	}
+
+	err := client.Write(header)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = io.CopyN(client.conn, payload, remaining)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write upload payload: %w", err)
+	}
+
+	_, err = client.conn.Write([]byte{'\n'})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write upload payload: %w", err)
+	}
+
+	result, err := client.Read()
+	if err != nil {
+		return 0, err
+	}
+
+	// OK <size> <elapsed milliseconds>
+	splitResult := bytes.Fields(result)
+	if len(splitResult) < 2 || !bytes.HasPrefix(result, okPrefix) {
+		return 0, ErrInvalidUploadResponse
+	}
+
+	received, err := strconv.ParseInt(string(splitResult[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse upload size: %w", err)
+	}
+
+	return received, nil
 }
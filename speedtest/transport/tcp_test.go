@@ -3,7 +3,9 @@ package transport
 import (
 	"bufio"
 	"context"
+	"io"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -438,13 +440,109 @@ func TestClient_PacketLoss(t *testing.T) {
 }
 
 func TestClient_Download(t *testing.T) {
-	client := &Client{}
+	t.Parallel()
+
+	t.Run("invalid size", func(t *testing.T) {
+		t.Parallel()
+
+		client := &Client{}
+		_, err := client.Download(5)
+		require.ErrorIs(t, err, ErrInvalidTransferSize)
+	})
+
+	t.Run("download payload", func(t *testing.T) {
+		t.Parallel()
+
+		client1, client2 := net.Pipe()
+
+		t.Cleanup(func() { _ = client2.Close() })
+
+		client := &Client{
+			conn:   client1,
+			reader: bufio.NewReader(client1),
+		}
+
+		// Simulate server response
+		go func() {
+			command, _ := bufio.NewReader(client2).ReadString('\n')
+			if command != "DOWNLOAD 32\n" {
+				return
+			}
+
+			_, _ = client2.Write([]byte("DOWNLOAD " + strings.Repeat("x", 22) + "\nPONG"))
+		}()
+
+		reader, err := client.Download(32)
+		require.NoError(t, err)
 
-	assert.Panics(t, func() { client.Download() })
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Len(t, data, 32)
+		assert.True(t, strings.HasPrefix(string(data), "DOWNLOAD "))
+	})
 }
 
 func TestClient_Upload(t *testing.T) {
-	client := &Client{}
+	t.Parallel()
+
+	t.Run("invalid size", func(t *testing.T) {
+		t.Parallel()
+
+		client := &Client{}
+		_, err := client.Upload(10, strings.NewReader(""))
+		require.ErrorIs(t, err, ErrInvalidTransferSize)
+	})
+
+	t.Run("upload payload", func(t *testing.T) {
+		t.Parallel()
+
+		client1, client2 := net.Pipe()
+
+		t.Cleanup(func() { _ = client2.Close() })
+
+		client := &Client{
+			conn:   client1,
+			reader: bufio.NewReader(client1),
+		}
+
+		received := make(chan int, 1)
+
+		// Simulate server response
+		go func() {
+			reader := bufio.NewReader(client2)
+			header, _ := reader.ReadString('\n')
+			payload, _ := reader.ReadString('\n')
+			received <- len(header) + len(payload)
+
+			_, _ = client2.Write([]byte("OK 64 12\n"))
+		}()
 
-	assert.Panics(t, func() { client.Upload() })
+		got, err := client.Upload(64, strings.NewReader(strings.Repeat("a", 64)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(64), got)
+		assert.Equal(t, 64, <-received)
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		t.Parallel()
+
+		client1, client2 := net.Pipe()
+
+		t.Cleanup(func() { _ = client2.Close() })
+
+		client := &Client{
+			conn:   client1,
+			reader: bufio.NewReader(client1),
+		}
+
+		go func() {
+			reader := bufio.NewReader(client2)
+			_, _ = reader.ReadString('\n')
+			_, _ = reader.ReadString('\n')
+			_, _ = client2.Write([]byte("ERROR\n"))
+		}()
+
+		_, err := client.Upload(64, strings.NewReader(strings.Repeat("a", 64)))
+		require.ErrorIs(t, err, ErrInvalidUploadResponse)
+	})
 }
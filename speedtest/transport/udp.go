@@ -21,11 +21,11 @@ type PacketLossSender struct {
 	conn          net.Conn // UDP Conn
 	raw           []byte
 	host          string
-	dialer        *net.Dialer
+	dialer        Dialer
 }
 
 // NewPacketLossSender creates a new UDP packet loss sender.
-func NewPacketLossSender(uuid string, dialer *net.Dialer) (*PacketLossSender, error) {
+func NewPacketLossSender(uuid string, dialer Dialer) (*PacketLossSender, error) {
 	maxValue := int64(10000000000)
 	b := big.NewInt(maxValue)
 
@@ -2,6 +2,7 @@ package speedtest
 
 import (
 	"strconv"
+	"sync/atomic"
 )
 
 // UnitType represents the type of unit for byte rate formatting.
@@ -57,9 +58,20 @@ const (
 // ByteRate represents a byte rate value with formatting capabilities.
 type ByteRate float64
 
-var globalByteRateUnit UnitType
+// defaultUnit is the unit of ByteRate.String, set by the deprecated SetUnit.
+var defaultUnit atomic.Int64
 
+// String formats the byte rate in the unit set by the deprecated SetUnit,
+// auto-scaled bits by default. It ignores the unit of any client; the output of
+// a client formats its rates with Speedtest.FormatRate, or with Format and an
+// explicit unit.
 func (r ByteRate) String() string {
+	return r.Format(UnitType(defaultUnit.Load()))
+}
+
+// Format formats the byte rate in the unit, auto-scaled unless it is
+// UnitTypeDefaultMbps.
+func (r ByteRate) Format(unit UnitType) string {
 	if r == 0 {
 		return "0.00 Mbps"
 	}
@@ -68,16 +80,24 @@ func (r ByteRate) String() string {
 		return "N/A"
 	}
 
-	if globalByteRateUnit != UnitTypeDefaultMbps {
-		return r.Byte(globalByteRateUnit)
+	if unit != UnitTypeDefaultMbps {
+		return r.Byte(unit)
 	}
 
 	return strconv.FormatFloat(float64(r/125000.0), 'f', 2, 64) + " Mbps"
 }
 
-// SetUnit Set global output units.
+// SetUnit sets the unit of ByteRate.String for the whole process.
+//
+// Deprecated: the unit is shared by every client of the process. Set
+// UserConfig.Unit and use Speedtest.FormatRate, or ByteRate.Format, instead.
 func SetUnit(unit UnitType) {
-	globalByteRateUnit = unit
+	defaultUnit.Store(int64(unit))
+}
+
+// FormatRate formats the byte rate in the unit of the client configuration.
+func (s *Speedtest) FormatRate(rate ByteRate) string {
+	return rate.Format(s.config.Unit)
 }
 
 // Mbps returns the byte rate in megabits per second.
@@ -70,6 +70,35 @@ func TestSetUnit(t *testing.T) {
 	}
 }
 
+func TestByteRate_Format(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rate ByteRate
+		unit UnitType
+		want string
+	}{
+		{rate: 0, unit: UnitTypeBinaryBytes, want: "0.00 Mbps"},
+		{rate: -1, unit: UnitTypeDecimalBits, want: "N/A"},
+		{rate: 125000, unit: UnitTypeDecimalBits, want: "1000.00 Kbps"},
+		{rate: 125000, unit: UnitTypeDefaultMbps, want: "1.00 Mbps"},
+		{rate: 2 * MiB, unit: UnitTypeBinaryBytes, want: "2.00 MiB/s"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.rate.Format(tt.unit))
+	}
+}
+
+func TestSpeedtest_FormatRate(t *testing.T) {
+	t.Parallel()
+
+	mbps := New(WithUserConfig(&UserConfig{Unit: UnitTypeDefaultMbps}))
+	bytes := New(WithUserConfig(&UserConfig{Unit: UnitTypeDecimalBytes}))
+
+	assert.Equal(t, "8.00 Mbps", mbps.FormatRate(Megabyte))
+	assert.Equal(t, "1.00 MB/s", bytes.FormatRate(Megabyte))
+}
+
 func TestByteRate_Mbps(t *testing.T) {
 	tests := []struct {
 		name string
@@ -37,7 +37,7 @@ func (s *Speedtest) FetchUserInfo() (*User, error) {
 
 // FetchUserInfo returns information about caller determined by speedtest.net.
 func FetchUserInfo() (*User, error) {
-	return defaultClient.FetchUserInfo()
+	return defaultClient().FetchUserInfo()
 }
 
 // FetchUserInfoContext returns information about caller determined by speedtest.net, observing the given context.
@@ -46,7 +46,7 @@ func (s *Speedtest) FetchUserInfoContext(ctx context.Context) (*User, error) {
 		return nil, ErrInstanceNil
 	}
 
-	dbg.Printf("Retrieving user info: %s\n", speedTestConfigURL)
+	s.dbg.Printf("Retrieving user info: %s\n", speedTestConfigURL)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, speedTestConfigURL, nil)
 	if err != nil {
@@ -81,7 +81,7 @@ func (s *Speedtest) FetchUserInfoContext(ctx context.Context) (*User, error) {
 
 // FetchUserInfoContext returns information about caller determined by speedtest.net, observing the given context.
 func FetchUserInfoContext(ctx context.Context) (*User, error) {
-	return defaultClient.FetchUserInfoContext(ctx)
+	return defaultClient().FetchUserInfoContext(ctx)
 }
 
 // String representation of User.